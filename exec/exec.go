@@ -154,17 +154,43 @@ func New(ops ...CommanderOption) *Commander {
 	return cm
 }
 
-// ExecWithExitCode executes giving command associated within the command with os/exec.
+// ExecWithExitCode executes giving command associated within the command with os/exec,
+// returning its exit code. The command is run within a span like with Exec, which
+// also records the exit code.
 func (c *Commander) ExecWithExitCode(ctx context.Context, metric metrics.Metrics) (int, error) {
-	if err := c.Exec(ctx, metric); err != nil {
-		return getExitStatus(err), err
-	}
+	ctx, span := metrics.StartSpan(ctx, metric, "shell:exec")
+
+	err := c.exec(ctx, metric, span)
+	code := getExitStatus(err)
+
+	span.SetAttribute("exit_code", code)
+	endSpan(span, err)
 
-	return 0, nil
+	return code, err
 }
 
 // Exec executes giving command associated within the command with os/exec.
+// The command is run within a span, a child of any span the context carries,
+// which is propagated to the process through the TRACEPARENT environment variable.
 func (c *Commander) Exec(ctx context.Context, metric metrics.Metrics) error {
+	ctx, span := metrics.StartSpan(ctx, metric, "shell:exec")
+
+	err := c.exec(ctx, metric, span)
+	endSpan(span, err)
+
+	return err
+}
+
+// endSpan ends the giving span, marking it failed by the giving error if any.
+func endSpan(span *metrics.Span, err error) {
+	if err != nil {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// exec executes the command within the giving span.
+func (c *Commander) exec(ctx context.Context, metric metrics.Metrics, span *metrics.Span) error {
 	if c.Binary == "" {
 		c.Binary = "/bin/sh"
 	}
@@ -215,6 +241,9 @@ func (c *Commander) Exec(ctx context.Context, metric metrics.Metrics) error {
 		}
 	}
 
+	span.SetAttribute("command", strings.Join(execCommand, " "))
+	cmder.Env = append(cmder.Env, fmt.Sprintf("%s=%s", metrics.TraceParentEnv, span.Context().TraceParent()))
+
 	metric.Emit(metrics.Info("Executing native commands"), metrics.WithID("shell:exec"), metrics.WithFields(metrics.Field{
 		"command": strings.Join(execCommand, " "),
 		"envs":    c.Envs,
@@ -9,6 +9,7 @@ import (
 
 	"github.com/influx6/faux/exec"
 	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/metricstest"
 	"github.com/influx6/faux/tests"
 )
 
@@ -118,3 +119,39 @@ func TestWgetCommand(t *testing.T) {
 	tests.Passed("Should have succcesfully executed command")
 	tests.Info("Output: %+q", outs.Bytes())
 }
+
+func TestExecWithExitCodeSpan(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	m := recorder.Metrics()
+
+	ctx, parent := metrics.StartSpan(context.Background(), m, "parent")
+	defer parent.End()
+
+	cmd := exec.New(exec.Command("exit 3"), exec.Sync())
+	code, err := cmd.ExecWithExitCode(ctx, m)
+	if err == nil {
+		tests.Failed("Should have failed command with exit code")
+	}
+	tests.Passed("Should have failed command with exit code")
+
+	var span *metrics.Entry
+	for _, en := range recorder.Entries() {
+		if en.Type == metrics.SpanType && en.ID == "shell:exec" {
+			en := en
+			span = &en
+		}
+	}
+
+	if span == nil {
+		tests.Failed("Should have emitted span for command: %+v", recorder.Entries())
+	}
+
+	if traceID, _ := span.Field.Get("trace_id"); traceID != parent.Context().TraceID {
+		tests.Failed("Should have joined trace of parent span but got %+v", traceID)
+	}
+
+	if exitCode, _ := span.Field.Get("exit_code"); exitCode != code {
+		tests.Failed("Should have recorded exit code %d but got %+v", code, exitCode)
+	}
+	tests.Passed("Should have run command within child span")
+}
@@ -0,0 +1,673 @@
+package httputil
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// errors.
+var (
+	ErrInvalidBindTarget = errors.New("Bind target must be a pointer to a struct")
+)
+
+// struct tags used by Bind.
+const (
+	// ParamTag sets the name of the path parameter bound into a field.
+	ParamTag = "param"
+
+	// QueryTag sets the name of the query value bound into a field.
+	QueryTag = "query"
+
+	// FormTag sets the name of the form value or multipart file bound into a field.
+	FormTag = "form"
+
+	// TimeFormatTag sets the layout used to parse times bound into a field,
+	// defaults to time.RFC3339.
+	TimeFormatTag = "time_format"
+
+	// ValidateTag sets the comma separated rules validated against a field.
+	ValidateTag = "validate"
+)
+
+// Bind decodes the request into the struct pointed to by dst, before validating
+// it with Validate.
+//
+// Path parameters set through SetParam and query values are bound into fields
+// with a param or query tag, which the body can not set, while the body is
+// decoded according to its Content-Type: JSON and XML bodies through their standard packages and url
+// encoded or multipart forms into fields with a form tag, including
+// *multipart.FileHeader fields.
+//
+//	type CreateUser struct {
+//		Org   string   `param:"org" validate:"required"`
+//		Name  string   `json:"name" form:"name" validate:"required,min=2,max=40"`
+//		Role  string   `json:"role" form:"role" validate:"oneof=admin member"`
+//		Email string   `json:"email" form:"email" validate:"omitempty,regex=^[^@]+@[^@]+$"`
+//		Tags  []string `json:"tags" form:"tag"`
+//	}
+//
+// Malformed bodies return a HTTPError with a 400, unsupported content types
+// a HTTPError with a 415 and values failing conversion or validation a
+// FieldErrors, which is rendered as a 422.
+func (c *Context) Bind(dst interface{}) error {
+	target := reflect.ValueOf(dst)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+
+	var ferrs FieldErrors
+
+	// Fields bound from the path and query are kept from the body, so it can
+	// not swap the resource addressed by the url.
+	restore := keepTagged(target.Elem(), ParamTag, QueryTag)
+	if err := c.bindBody(target, &ferrs); err != nil {
+		return err
+	}
+	restore()
+
+	ferrs = bindValues(target.Elem(), ParamTag, func(name string) ([]string, bool) {
+		value, ok := c.params[name]
+		if !ok {
+			return nil, false
+		}
+		return []string{value}, true
+	}, ferrs)
+
+	query := c.QueryParams()
+	ferrs = bindValues(target.Elem(), QueryTag, func(name string) ([]string, bool) {
+		values, ok := query[name]
+		return values, ok
+	}, ferrs)
+
+	ferrs = validateStruct(target.Elem(), "", ferrs)
+	if len(ferrs) != 0 {
+		return ferrs
+	}
+
+	return nil
+}
+
+// bindBody decodes the request body into the target according to its content type.
+func (c *Context) bindBody(target reflect.Value, ferrs *FieldErrors) error {
+	req := c.request
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	ctype := req.Header.Get(HeaderContentType)
+	if ctype == "" {
+		return nil
+	}
+
+	mediatype, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return HTTPError{Code: http.StatusUnsupportedMediaType, Err: err}
+	}
+
+	switch {
+	case mediatype == MIMEApplicationJSON || strings.HasSuffix(mediatype, "+json"):
+		if err := json.NewDecoder(req.Body).Decode(target.Interface()); err != nil {
+			return HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+	case mediatype == MIMEApplicationXML || mediatype == MIMETextXML:
+		if err := xml.NewDecoder(req.Body).Decode(target.Interface()); err != nil {
+			return HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+	case mediatype == MIMEApplicationForm:
+		if err := req.ParseForm(); err != nil {
+			return HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+
+		*ferrs = bindValues(target.Elem(), FormTag, func(name string) ([]string, bool) {
+			values, ok := req.PostForm[name]
+			return values, ok
+		}, *ferrs)
+	case mediatype == MIMEMultipartForm:
+		form, err := c.MultipartForm()
+		if err != nil {
+			return HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+
+		*ferrs = bindValues(target.Elem(), FormTag, func(name string) ([]string, bool) {
+			values, ok := form.Value[name]
+			return values, ok
+		}, *ferrs)
+
+		bindFiles(target.Elem(), form)
+	default:
+		return HTTPError{Code: http.StatusUnsupportedMediaType, Err: fmt.Errorf("unsupported content type %q", mediatype)}
+	}
+
+	return nil
+}
+
+//=========================================================================================
+
+// FieldError defines the failure of a single struct field to convert or validate.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (f FieldError) Error() string {
+	return f.Field + " " + f.Message
+}
+
+// FieldErrors defines a list of FieldError returned by Bind and Validate.
+type FieldErrors []FieldError
+
+// Error implements the error interface.
+func (f FieldErrors) Error() string {
+	messages := make([]string, len(f))
+	for index, ferr := range f {
+		messages[index] = ferr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Render renders the field errors as a JSON object with a 422 status.
+func (f FieldErrors) Render(ctx *Context) error {
+	return ctx.JSON(http.StatusUnprocessableEntity, struct {
+		Status int          `json:"status"`
+		Errors []FieldError `json:"errors"`
+	}{
+		Status: http.StatusUnprocessableEntity,
+		Errors: f,
+	})
+}
+
+//=========================================================================================
+
+// bindValues sets every field with the giving tag to the values returned by
+// the lookup function for its name, recording conversion failures.
+func bindValues(target reflect.Value, tag string, lookup func(string) ([]string, bool), ferrs FieldErrors) FieldErrors {
+	tp := target.Type()
+	for index := 0; index < tp.NumField(); index++ {
+		field := tp.Field(index)
+		value := target.Field(index)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			ferrs = bindValues(value, tag, lookup, ferrs)
+			continue
+		}
+
+		name := field.Tag.Get(tag)
+		if name == "" || name == "-" {
+			continue
+		}
+
+		values, ok := lookup(name)
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		if err := setValue(value, values, field.Tag.Get(TimeFormatTag)); err != nil {
+			ferrs = append(ferrs, FieldError{
+				Field:   name,
+				Rule:    "type",
+				Param:   value.Type().String(),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	return ferrs
+}
+
+// keepTagged saves the fields of the target with any of the giving tags,
+// returning a function which restores them.
+func keepTagged(target reflect.Value, tags ...string) func() {
+	var fields, saved []reflect.Value
+
+	var walk func(reflect.Value)
+	walk = func(target reflect.Value) {
+		tp := target.Type()
+		for index := 0; index < tp.NumField(); index++ {
+			field := tp.Field(index)
+			value := target.Field(index)
+
+			if field.Anonymous && value.Kind() == reflect.Struct {
+				walk(value)
+				continue
+			}
+
+			if !value.CanSet() {
+				continue
+			}
+
+			for _, tag := range tags {
+				if name := field.Tag.Get(tag); name != "" && name != "-" {
+					copied := reflect.New(value.Type()).Elem()
+					copied.Set(value)
+
+					fields = append(fields, value)
+					saved = append(saved, copied)
+					break
+				}
+			}
+		}
+	}
+
+	walk(target)
+
+	return func() {
+		for index, field := range fields {
+			field.Set(saved[index])
+		}
+	}
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	unmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// bindFiles sets every *multipart.FileHeader or []*multipart.FileHeader field
+// with a form tag to the files of the form.
+func bindFiles(target reflect.Value, form *multipart.Form) {
+	tp := target.Type()
+	for index := 0; index < tp.NumField(); index++ {
+		field := tp.Field(index)
+		value := target.Field(index)
+
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			bindFiles(value, form)
+			continue
+		}
+
+		name := field.Tag.Get(FormTag)
+		files := form.File[name]
+		if name == "" || len(files) == 0 || !value.CanSet() {
+			continue
+		}
+
+		switch field.Type {
+		case fileHeaderType:
+			value.Set(reflect.ValueOf(files[0]))
+		case fileHeadersType:
+			value.Set(reflect.ValueOf(files))
+		}
+	}
+}
+
+// setValue converts the giving values into the type of the target.
+func setValue(target reflect.Value, values []string, layout string) error {
+	if !target.CanSet() {
+		return nil
+	}
+
+	switch target.Type() {
+	case fileHeaderType, fileHeadersType:
+		return nil
+	}
+
+	if target.Kind() == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(target.Type(), len(values), len(values))
+		for index, value := range values {
+			if err := setString(slice.Index(index), value, layout); err != nil {
+				return err
+			}
+		}
+
+		target.Set(slice)
+		return nil
+	}
+
+	return setString(target, values[0], layout)
+}
+
+// setString converts the giving value into the type of the target.
+func setString(target reflect.Value, value string, layout string) error {
+	if target.Kind() == reflect.Ptr {
+		item := reflect.New(target.Type().Elem())
+		if err := setString(item.Elem(), value, layout); err != nil {
+			return err
+		}
+
+		target.Set(item)
+		return nil
+	}
+
+	if target.CanAddr() && target.Addr().Type().Implements(unmarshalerType) && target.Type() != timeType {
+		if err := target.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("must be a valid %s", target.Type())
+		}
+		return nil
+	}
+
+	switch target.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+
+		parsed, err := time.Parse(layout, value)
+		if err != nil {
+			return fmt.Errorf("must be a time in the format %q", layout)
+		}
+
+		target.Set(reflect.ValueOf(parsed))
+		return nil
+	case durationType:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration")
+		}
+
+		target.SetInt(int64(parsed))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(value)
+	case reflect.Slice:
+		target.SetBytes([]byte(value))
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		target.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, target.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		target.SetFloat(parsed)
+	default:
+		return fmt.Errorf("can not be bound into %s", target.Type())
+	}
+
+	return nil
+}
+
+//=========================================================================================
+
+// Validate validates the fields of the struct pointed to by v against the
+// rules of their validate tag, returning a FieldErrors listing all failures.
+//
+// Rules are separated by commas, where a regex rule must come last as its
+// expression may contain commas:
+//
+//	required       fails on zero values and empty slices or maps.
+//	omitempty      skips the rules after it for zero values and empty slices or maps.
+//	min=N, max=N   bounds numbers by value and strings, slices and maps by length.
+//	oneof=a b c    requires the value to be one of the space separated options.
+//	regex=expr     requires strings to match the regular expression.
+//
+// Rules other than required are skipped for nil pointers, making pointer
+// fields optional. Nested structs are validated with their field names
+// prefixed by the parent's.
+func Validate(v interface{}) error {
+	target := reflect.ValueOf(v)
+	for target.Kind() == reflect.Ptr && !target.IsNil() {
+		target = target.Elem()
+	}
+
+	if target.Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+
+	if ferrs := validateStruct(target, "", nil); len(ferrs) != 0 {
+		return ferrs
+	}
+
+	return nil
+}
+
+func validateStruct(target reflect.Value, prefix string, ferrs FieldErrors) FieldErrors {
+	tp := target.Type()
+	for index := 0; index < tp.NumField(); index++ {
+		field := tp.Field(index)
+		value := target.Field(index)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+
+		if rules := field.Tag.Get(ValidateTag); rules != "" && rules != "-" {
+			ferrs = validateField(value, name, rules, ferrs)
+		}
+
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Struct && value.Type() != timeType {
+			if field.Anonymous {
+				ferrs = validateStruct(value, prefix, ferrs)
+				continue
+			}
+
+			ferrs = validateStruct(value, name+".", ferrs)
+		}
+	}
+
+	return ferrs
+}
+
+// fieldName returns the name of the field as known to clients.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", FormTag, QueryTag, ParamTag, "xml"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// splitRules returns the rules of a validate tag, keeping a regex rule whole.
+func splitRules(rules string) []string {
+	var list []string
+	for rules != "" {
+		if strings.HasPrefix(rules, "regex=") {
+			return append(list, rules)
+		}
+
+		at := strings.Index(rules, ",")
+		if at == -1 {
+			return append(list, rules)
+		}
+
+		list = append(list, rules[:at])
+		rules = rules[at+1:]
+	}
+	return list
+}
+
+func validateField(value reflect.Value, name string, rules string, ferrs FieldErrors) FieldErrors {
+	for _, rule := range splitRules(rules) {
+		rule = strings.TrimSpace(rule)
+
+		var param string
+		if at := strings.Index(rule, "="); at != -1 {
+			rule, param = rule[:at], rule[at+1:]
+		}
+
+		if rule == "omitempty" {
+			if isEmpty(value) {
+				return ferrs
+			}
+			continue
+		}
+
+		if rule == "required" {
+			if isEmpty(value) {
+				ferrs = append(ferrs, FieldError{Field: name, Rule: rule, Message: "is required"})
+			}
+			continue
+		}
+
+		target := value
+		for target.Kind() == reflect.Ptr {
+			if target.IsNil() {
+				break
+			}
+			target = target.Elem()
+		}
+
+		if target.Kind() == reflect.Ptr {
+			continue
+		}
+
+		if message, ok := checkRule(target, rule, param); !ok {
+			ferrs = append(ferrs, FieldError{Field: name, Rule: rule, Param: param, Message: message})
+		}
+	}
+
+	return ferrs
+}
+
+// checkRule returns false with a message if the value fails the giving rule.
+func checkRule(value reflect.Value, rule string, param string) (string, bool) {
+	switch rule {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Sprintf("has an invalid %s rule %q", rule, param), false
+		}
+
+		size, isLength, ok := measure(value)
+		if !ok {
+			return fmt.Sprintf("does not support the %s rule", rule), false
+		}
+
+		if (rule == "min" && size >= bound) || (rule == "max" && size <= bound) {
+			return "", true
+		}
+
+		limit := "at least"
+		if rule == "max" {
+			limit = "at most"
+		}
+
+		if isLength {
+			return fmt.Sprintf("must have a length of %s %s", limit, param), false
+		}
+
+		return fmt.Sprintf("must be %s %s", limit, param), false
+	case "oneof":
+		options := strings.Fields(param)
+		current := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if option == current {
+				return "", true
+			}
+		}
+
+		return fmt.Sprintf("must be one of %s", strings.Join(options, ", ")), false
+	case "regex":
+		if value.Kind() != reflect.String {
+			return "does not support the regex rule", false
+		}
+
+		rx, err := compileRule(param)
+		if err != nil {
+			return fmt.Sprintf("has an invalid regex rule %q", param), false
+		}
+
+		if !rx.MatchString(value.String()) {
+			return fmt.Sprintf("must match %s", param), false
+		}
+
+		return "", true
+	}
+
+	return fmt.Sprintf("has an unknown rule %q", rule), false
+}
+
+// measure returns the numeric value of numbers or the length of strings,
+// slices and maps.
+func measure(value reflect.Value) (float64, bool, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	}
+	return 0, false, false
+}
+
+// isEmpty returns true/false if the value is a zero value or an empty slice or map.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+
+	zero := reflect.Zero(value.Type()).Interface()
+	if !value.Type().Comparable() {
+		return reflect.DeepEqual(value.Interface(), zero)
+	}
+	return value.Interface() == zero
+}
+
+var rules = struct {
+	ml      sync.Mutex
+	regexps map[string]*regexp.Regexp
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// compileRule returns the compiled expression of a regex rule, caching it for reuse.
+func compileRule(expr string) (*regexp.Regexp, error) {
+	rules.ml.Lock()
+	defer rules.ml.Unlock()
+
+	if rx, ok := rules.regexps[expr]; ok {
+		return rx, nil
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	rules.regexps[expr] = rx
+	return rx, nil
+}
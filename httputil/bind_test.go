@@ -0,0 +1,186 @@
+package httputil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/tests"
+)
+
+type createUser struct {
+	Org     string                `param:"org" validate:"required"`
+	Verbose bool                  `query:"verbose"`
+	Name    string                `json:"name" form:"name" validate:"required,min=2,max=10"`
+	Role    string                `json:"role" form:"role" validate:"oneof=admin member"`
+	Email   string                `json:"email" form:"email" validate:"omitempty,regex=^[^@,]+@[^@]+$"`
+	Age     *int                  `json:"age" form:"age" validate:"min=18"`
+	Tags    []string              `json:"tags" form:"tag" validate:"max=2"`
+	Born    time.Time             `json:"born" form:"born" time_format:"2006-01-02"`
+	Avatar  *multipart.FileHeader `form:"avatar"`
+}
+
+func bindRouter(dst *createUser) *httputil.Router {
+	router := httputil.NewRouter()
+	router.Post("/orgs/:org/users", func(ctx *httputil.Context) error {
+		return ctx.Bind(dst)
+	})
+	return router
+}
+
+func TestBindJSON(t *testing.T) {
+	var user createUser
+	router := bindRouter(&user)
+
+	body := `{"name":"bob","role":"admin","email":"bob@example.com","age":21,"tags":["a"]}`
+	req := httptest.NewRequest("POST", "/orgs/acme/users?verbose=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || user.Org != "acme" || !user.Verbose || user.Name != "bob" || user.Age == nil || *user.Age != 21 {
+		tests.Failed("Should have bound params, query and json body: %d %s %#v", res.Code, res.Body.String(), user)
+	}
+	tests.Passed("Should have bound params, query and json body")
+}
+
+func TestBindMultipart(t *testing.T) {
+	var user createUser
+	router := bindRouter(&user)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "alice")
+	writer.WriteField("role", "member")
+	writer.WriteField("tag", "a")
+	writer.WriteField("tag", "b")
+	writer.WriteField("age", "30")
+	writer.WriteField("born", "1990-04-01")
+
+	part, _ := writer.CreateFormFile("avatar", "avatar.png")
+	part.Write([]byte("png"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/orgs/acme/users", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		tests.Failed("Should have bound multipart form: %d %s", res.Code, res.Body.String())
+	}
+	tests.Passed("Should have bound multipart form")
+
+	if len(user.Tags) != 2 || *user.Age != 30 || user.Born.Year() != 1990 || user.Avatar == nil || user.Avatar.Filename != "avatar.png" {
+		tests.Failed("Should have converted form values and files: %#v", user)
+	}
+	tests.Passed("Should have converted form values and files")
+}
+
+func TestBindValidation(t *testing.T) {
+	var user createUser
+	router := bindRouter(&user)
+
+	form := "name=b&role=owner&email=nope&age=12&tag=a&tag=b&tag=c&born=yesterday"
+	req := httptest.NewRequest("POST", "/orgs/acme/users", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusUnprocessableEntity {
+		tests.Failed("Should have responded with 422: %d %s", res.Code, res.Body.String())
+	}
+	tests.Passed("Should have responded with 422")
+
+	var reply struct {
+		Errors []httputil.FieldError `json:"errors"`
+	}
+
+	if err := json.Unmarshal(res.Body.Bytes(), &reply); err != nil {
+		tests.Failed("Should have rendered field errors as json: %+q", err)
+	}
+	tests.Passed("Should have rendered field errors as json")
+
+	rules := map[string]string{}
+	for _, ferr := range reply.Errors {
+		rules[ferr.Field] = ferr.Rule
+	}
+
+	expected := map[string]string{"born": "type", "name": "min", "role": "oneof", "email": "regex", "age": "min", "tags": "max"}
+	for field, rule := range expected {
+		if rules[field] != rule {
+			tests.Failed("Should have failed %q with rule %q: %#v", field, rule, reply.Errors)
+		}
+	}
+	tests.Passed("Should have failed fields with their rules")
+
+	req = httptest.NewRequest("POST", "/orgs/acme/users", strings.NewReader("name"))
+	req.Header.Set("Content-Type", "text/csv")
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusUnsupportedMediaType {
+		tests.Failed("Should have responded with 415: %d", res.Code)
+	}
+	tests.Passed("Should have responded with 415")
+}
+
+func TestBindParamsFromRouteOnly(t *testing.T) {
+	var user createUser
+	router := bindRouter(&user)
+
+	router.Post("/users", func(ctx *httputil.Context) error {
+		return ctx.Bind(&user)
+	})
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path+"?org=evil", strings.NewReader("org=evil&name=bob&role=admin"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	sendJSON := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"org":"evil","Org":"evil","verbose":true,"name":"bob","role":"admin"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := send("/orgs/acme/users"); res.Code != http.StatusOK || user.Org != "acme" {
+		tests.Failed("Should have bound path param over query and form values: %d %#v", res.Code, user)
+	}
+	tests.Passed("Should have bound path param over query and form values")
+
+	user = createUser{}
+	if res := send("/users"); res.Code != http.StatusUnprocessableEntity || user.Org != "" {
+		tests.Failed("Should have refused param set through query and form values: %d %#v", res.Code, user)
+	}
+	tests.Passed("Should have refused param set through query and form values")
+
+	user = createUser{}
+	if res := sendJSON("/orgs/acme/users"); res.Code != http.StatusOK || user.Org != "acme" || user.Verbose {
+		tests.Failed("Should have bound path param over json body: %d %#v", res.Code, user)
+	}
+	tests.Passed("Should have bound path param over json body")
+
+	user = createUser{}
+	if res := sendJSON("/users"); res.Code != http.StatusUnprocessableEntity || user.Org != "" {
+		tests.Failed("Should have refused param set through json body: %d %#v", res.Code, user)
+	}
+	tests.Passed("Should have refused param set through json body")
+}
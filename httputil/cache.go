@@ -0,0 +1,306 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errors.
+var (
+	ErrPreconditionFailed = errors.New("Precondition Failed")
+)
+
+// ETagMode defines how entity tags are generated for responses.
+type ETagMode int
+
+// entity tag modes.
+const (
+	NoETag ETagMode = iota
+	StrongETag
+	WeakETag
+)
+
+// ETagMW returns a Middleware which has Context.Blob and the methods built on it
+// generate an entity tag from the hash of the payload, and Context.File from
+// the modification time and size of the file, for successful GET and HEAD
+// responses which have none set. Requests whose If-None-Match or
+// If-Modified-Since match are answered with a 304.
+func ETagMW(mode ETagMode) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			ctx.etagMode = mode
+			return next(ctx)
+		}
+	}
+}
+
+// SetETag sets the ETag header of the response to the giving tag, quoting it
+// if needed.
+func (c *Context) SetETag(tag string, weak bool) {
+	tag = quoteETag(tag)
+
+	if weak && !strings.HasPrefix(tag, "W/") {
+		tag = "W/" + tag
+	}
+
+	c.SetHeader(HeaderETag, tag)
+}
+
+// SetLastModified sets the Last-Modified header of the response to the giving time.
+func (c *Context) SetLastModified(modified time.Time) {
+	c.SetHeader(HeaderLastModified, modified.UTC().Format(http.TimeFormat))
+}
+
+// Fresh returns true/false if the client holds a fresh copy of the response,
+// going by the ETag and Last-Modified headers already set on the response
+// against the If-None-Match and If-Modified-Since headers of a GET or HEAD
+// request.
+func (c *Context) Fresh() bool {
+	if c.request == nil || c.response == nil {
+		return false
+	}
+
+	if c.request.Method != http.MethodGet && c.request.Method != http.MethodHead {
+		return false
+	}
+
+	header := c.response.Header()
+
+	if match := c.request.Header.Get(HeaderIfNoneMatch); match != "" {
+		return matchETag(match, header.Get(HeaderETag), false)
+	}
+
+	since, err := http.ParseTime(c.request.Header.Get(HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(header.Get(HeaderLastModified))
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// Preconditions checks the If-Match, If-Unmodified-Since and If-None-Match
+// headers of requests with unsafe methods against the giving current entity tag
+// and modification time of the resource, returning a HTTPError with a 412 if
+// they fail. The entity tag is quoted if needed as with SetETag. Handlers
+// should call it before applying changes, so clients do not overwrite changes
+// they have not seen.
+func (c *Context) Preconditions(etag string, modified time.Time) error {
+	switch c.request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	if etag != "" {
+		etag = quoteETag(etag)
+	}
+
+	header := c.request.Header
+
+	if match := header.Get(HeaderIfMatch); match != "" {
+		if !matchETag(match, etag, true) {
+			return HTTPError{Code: http.StatusPreconditionFailed, Err: ErrPreconditionFailed}
+		}
+	} else if since, err := http.ParseTime(header.Get(HeaderIfUnmodifiedSince)); err == nil && !modified.IsZero() {
+		if modified.Truncate(time.Second).After(since) {
+			return HTTPError{Code: http.StatusPreconditionFailed, Err: ErrPreconditionFailed}
+		}
+	}
+
+	if match := header.Get(HeaderIfNoneMatch); match != "" && matchETag(match, etag, false) {
+		return HTTPError{Code: http.StatusPreconditionFailed, Err: ErrPreconditionFailed}
+	}
+
+	return nil
+}
+
+// writeFresh generates the entity tag of the giving payload if required, then
+// writes a 304 if the client holds a fresh copy, returning true/false if it did.
+func (c *Context) writeFresh(code int, b []byte) bool {
+	if code != http.StatusOK {
+		return false
+	}
+
+	if c.etagMode != NoETag && c.response.Header().Get(HeaderETag) == "" {
+		switch c.request.Method {
+		case http.MethodGet, http.MethodHead:
+			hash := fnv.New64a()
+			hash.Write(b)
+			c.SetETag(fmt.Sprintf("%x-%x", len(b), hash.Sum64()), c.etagMode == WeakETag)
+		}
+	}
+
+	if !c.Fresh() {
+		return false
+	}
+
+	header := c.response.Header()
+	header.Del(HeaderContentType)
+	header.Del(HeaderContentLength)
+
+	c.response.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// quoteETag quotes the giving entity tag unless it is already quoted, either
+// as a strong or weak tag.
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+
+	return strconv.Quote(tag)
+}
+
+// matchETag returns true/false if the giving entity tag is within the list of
+// an If-Match or If-None-Match header, comparing weakly unless strong is true.
+func matchETag(list string, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for list != "" {
+		list = strings.TrimLeft(list, " \t,")
+
+		weak := strings.HasPrefix(list, "W/")
+		if weak {
+			list = list[2:]
+		}
+
+		if !strings.HasPrefix(list, `"`) {
+			return false
+		}
+
+		end := strings.Index(list[1:], `"`)
+		if end == -1 {
+			return false
+		}
+
+		if tag := list[:end+2]; tag == etag && !(strong && weak) {
+			return true
+		}
+
+		list = list[end+2:]
+	}
+
+	return false
+}
+
+//=========================================================================================
+
+// CacheControl defines a Cache-Control policy for responses.
+type CacheControl struct {
+	// Public sets responses to be cacheable by shared caches.
+	Public bool
+
+	// Private sets responses to be cacheable only by the client.
+	Private bool
+
+	// NoCache sets cached responses to be revalidated before use.
+	NoCache bool
+
+	// NoStore sets responses to not be cached at all.
+	NoStore bool
+
+	// MustRevalidate sets stale responses to be revalidated before use.
+	MustRevalidate bool
+
+	// Immutable sets responses to never change while fresh.
+	Immutable bool
+
+	// MaxAge sets how long responses are fresh.
+	MaxAge time.Duration
+
+	// SharedMaxAge sets how long responses are fresh within shared caches.
+	SharedMaxAge time.Duration
+
+	// StaleWhileRevalidate sets how long stale responses may be used while
+	// they are revalidated in the background.
+	StaleWhileRevalidate time.Duration
+}
+
+// String returns the Cache-Control header value of the policy.
+func (cc CacheControl) String() string {
+	var directives []string
+
+	if cc.Public {
+		directives = append(directives, "public")
+	}
+
+	if cc.Private {
+		directives = append(directives, "private")
+	}
+
+	if cc.NoCache {
+		directives = append(directives, "no-cache")
+	}
+
+	if cc.NoStore {
+		directives = append(directives, "no-store")
+	}
+
+	if cc.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.Itoa(int(cc.MaxAge/time.Second)))
+	}
+
+	if cc.SharedMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.Itoa(int(cc.SharedMaxAge/time.Second)))
+	}
+
+	if cc.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+
+	if cc.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+strconv.Itoa(int(cc.StaleWhileRevalidate/time.Second)))
+	}
+
+	if cc.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// SetCacheControl sets the Cache-Control header of the response to the giving policy.
+func (c *Context) SetCacheControl(policy CacheControl) {
+	c.SetHeader(HeaderCacheControl, policy.String())
+}
+
+// CacheMW returns a Middleware which sets the Cache-Control header of responses
+// to the giving policy, for use per route or group. Handlers may still override
+// it, like for errors which should not be cached.
+//
+//	router.Get("/assets/*", assets, httputil.CacheMW(httputil.CacheControl{
+//		Public: true,
+//		MaxAge: 24 * time.Hour,
+//	}))
+func CacheMW(policy CacheControl) Middleware {
+	value := policy.String()
+
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			ctx.SetHeader(HeaderCacheControl, value)
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package httputil_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/tests"
+)
+
+func TestETag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httputil-cache")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "data.txt")
+	if err := ioutil.WriteFile(file, []byte("file content"), 0644); err != nil {
+		tests.Failed("Should have written file: %+q", err)
+	}
+
+	router := httputil.NewRouter()
+	router.Use(httputil.ETagMW(httputil.StrongETag))
+
+	router.Get("/users", func(ctx *httputil.Context) error {
+		return ctx.JSON(http.StatusOK, []string{"bob", "alice"})
+	})
+
+	router.Get("/file", func(ctx *httputil.Context) error {
+		return ctx.File(file)
+	})
+
+	router.Get("/weak", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "weak")
+	}, httputil.ETagMW(httputil.WeakETag))
+
+	send := func(path string, header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("/users", "", "")
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		tests.Failed("Should have generated strong ETag: %+q", res.Header())
+	}
+	tests.Passed("Should have generated strong ETag")
+
+	res = send("/users", "If-None-Match", `"other", `+etag)
+	if res.Code != http.StatusNotModified || res.Body.Len() != 0 {
+		tests.Failed("Should have answered matching If-None-Match with 304 but got %d", res.Code)
+	}
+
+	if res.Header().Get("ETag") != etag || res.Header().Get("Content-Type") != "" {
+		tests.Failed("Should have kept ETag and dropped Content-Type: %+q", res.Header())
+	}
+	tests.Passed("Should have answered matching If-None-Match with 304")
+
+	if res := send("/users", "If-None-Match", "W/"+etag); res.Code != http.StatusNotModified {
+		tests.Failed("Should have compared If-None-Match weakly but got %d", res.Code)
+	}
+	tests.Passed("Should have compared If-None-Match weakly")
+
+	if res := send("/users", "If-None-Match", `"other"`); res.Code != http.StatusOK {
+		tests.Failed("Should have answered stale copy with 200 but got %d", res.Code)
+	}
+	tests.Passed("Should have answered stale copy with 200")
+
+	if res := send("/weak", "", ""); !strings.HasPrefix(res.Header().Get("ETag"), `W/"`) {
+		tests.Failed("Should have generated weak ETag: %+q", res.Header())
+	}
+	tests.Passed("Should have generated weak ETag")
+
+	res = send("/file", "", "")
+	etag = res.Header().Get("ETag")
+	if res.Code != http.StatusOK || etag == "" || res.Header().Get("Last-Modified") == "" {
+		tests.Failed("Should have set validators for file: %+q", res.Header())
+	}
+
+	if res := send("/file", "If-None-Match", etag); res.Code != http.StatusNotModified {
+		tests.Failed("Should have answered file If-None-Match with 304 but got %d", res.Code)
+	}
+
+	if res := send("/file", "If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); res.Code != http.StatusNotModified {
+		tests.Failed("Should have answered file If-Modified-Since with 304 but got %d", res.Code)
+	}
+	tests.Passed("Should have answered file conditional requests with 304")
+}
+
+func TestLastModified(t *testing.T) {
+	modified := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	handler := httputil.ServeHandler(func(ctx *httputil.Context) error {
+		ctx.SetLastModified(modified)
+		return ctx.String(http.StatusOK, "content")
+	})
+
+	send := func(since time.Time) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	if code := send(modified); code != http.StatusNotModified {
+		tests.Failed("Should have answered unmodified resource with 304 but got %d", code)
+	}
+
+	if code := send(modified.Add(-time.Minute)); code != http.StatusOK {
+		tests.Failed("Should have answered modified resource with 200 but got %d", code)
+	}
+	tests.Passed("Should have honoured If-Modified-Since")
+}
+
+func TestPreconditions(t *testing.T) {
+	current := "v2"
+	modified := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	handler := httputil.ServeHandler(func(ctx *httputil.Context) error {
+		if err := ctx.Preconditions(current, modified); err != nil {
+			return err
+		}
+		return ctx.NoContent(http.StatusNoContent)
+	})
+
+	send := func(method string, header string, value string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set(header, value)
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	if code := send("PUT", "If-Match", `"v2"`); code != http.StatusNoContent {
+		tests.Failed("Should have allowed matching If-Match but got %d", code)
+	}
+
+	if code := send("PUT", "If-Match", `"v1"`); code != http.StatusPreconditionFailed {
+		tests.Failed("Should have refused stale If-Match with 412 but got %d", code)
+	}
+
+	if code := send("PUT", "If-Match", `W/"v2"`); code != http.StatusPreconditionFailed {
+		tests.Failed("Should have compared If-Match strongly but got %d", code)
+	}
+
+	if code := send("DELETE", "If-Match", "*"); code != http.StatusNoContent {
+		tests.Failed("Should have allowed If-Match of any but got %d", code)
+	}
+
+	if code := send("GET", "If-Match", `"v1"`); code != http.StatusNoContent {
+		tests.Failed("Should have ignored preconditions of safe methods but got %d", code)
+	}
+	tests.Passed("Should have honoured If-Match for unsafe methods")
+
+	if code := send("PATCH", "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)); code != http.StatusPreconditionFailed {
+		tests.Failed("Should have refused If-Unmodified-Since with 412 but got %d", code)
+	}
+
+	if code := send("POST", "If-None-Match", "*"); code != http.StatusPreconditionFailed {
+		tests.Failed("Should have refused If-None-Match of existing resource with 412 but got %d", code)
+	}
+	tests.Passed("Should have honoured If-Unmodified-Since and If-None-Match for unsafe methods")
+
+	current = `"v2"`
+	if code := send("PUT", "If-Match", `"v2"`); code != http.StatusNoContent {
+		tests.Failed("Should have allowed matching If-Match against quoted tag but got %d", code)
+	}
+
+	current = `W/"v2"`
+	if code := send("PUT", "If-Match", `"v2"`); code != http.StatusPreconditionFailed {
+		tests.Failed("Should have refused If-Match against weak tag but got %d", code)
+	}
+	tests.Passed("Should have quoted entity tags as SetETag does")
+}
+
+func TestCacheControl(t *testing.T) {
+	policy := httputil.CacheControl{
+		Public:               true,
+		MaxAge:               time.Hour,
+		StaleWhileRevalidate: time.Minute,
+	}
+
+	if value := policy.String(); value != "public, max-age=3600, stale-while-revalidate=60" {
+		tests.Failed("Should have built Cache-Control value but got %q", value)
+	}
+	tests.Passed("Should have built Cache-Control value")
+
+	router := httputil.NewRouter()
+	router.Get("/static", func(ctx *httputil.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	}, httputil.CacheMW(httputil.CacheControl{NoStore: true}))
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("GET", "/static", nil))
+
+	if res.Header().Get("Cache-Control") != "no-store" {
+		tests.Failed("Should have set Cache-Control for route: %+q", res.Header())
+	}
+	tests.Passed("Should have set Cache-Control for route")
+}
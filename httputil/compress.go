@@ -0,0 +1,385 @@
+package httputil
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// supported content encodings, in order of preference.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// CompressConfig defines the configuration used by the compression middleware.
+type CompressConfig struct {
+	// Level sets the compression level from 1 to 9, defaults to the default
+	// level of compress/gzip.
+	Level int
+
+	// MinSize sets the size in bytes below which responses are sent as is,
+	// defaults to 1024.
+	MinSize int
+
+	// ContentTypes sets the media types compressed, either exactly like
+	// application/json or by type like text/*. Defaults to text, json,
+	// javascript, xml and svg types.
+	ContentTypes []string
+}
+
+// defaultCompressTypes defines the media types compressed by default.
+var defaultCompressTypes = []string{
+	"text/*",
+	MIMEApplicationJSON,
+	MIMEApplicationJavaScript,
+	MIMEApplicationXML,
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// Compress returns a Middleware which compresses responses with gzip or deflate
+// as negotiated through the Accept-Encoding header of the request.
+//
+// Responses are buffered till MinSize bytes are written, those ending below it
+// or with a media type not within ContentTypes are sent as is. Responses which
+// are flushed early are compressed if their media type is allowed, so streams
+// keep working. Responses already carrying a Content-Encoding and partial
+// responses, whose ranges refer to the uncompressed body, are left alone.
+func Compress(config CompressConfig) Middleware {
+	if config.Level < gzip.BestSpeed || config.Level > gzip.BestCompression {
+		config.Level = gzip.DefaultCompression
+	}
+
+	if config.MinSize <= 0 {
+		config.MinSize = 1024
+	}
+
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = defaultCompressTypes
+	}
+
+	types := make(map[string]bool, len(config.ContentTypes))
+	for _, item := range config.ContentTypes {
+		types[strings.ToLower(item)] = true
+	}
+
+	allowed := func(contentType string) bool {
+		media, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return false
+		}
+
+		if types[media] {
+			return true
+		}
+
+		at := strings.Index(media, "/")
+		return at != -1 && types[media[:at]+"/*"]
+	}
+
+	gzips := sync.Pool{New: func() interface{} {
+		zw, _ := gzip.NewWriterLevel(nil, config.Level)
+		return zw
+	}}
+
+	zlibs := sync.Pool{New: func() interface{} {
+		zw, _ := zlib.NewWriterLevel(nil, config.Level)
+		return zw
+	}}
+
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			if !hasToken(ctx.response.Header()[HeaderVary], HeaderAcceptEncoding) {
+				ctx.AddHeader(HeaderVary, HeaderAcceptEncoding)
+			}
+
+			encoding := NegotiateEncoding(ctx.GetHeader(HeaderAcceptEncoding), EncodingGzip, EncodingDeflate)
+			if encoding == "" || ctx.request.Method == http.MethodHead {
+				return next(ctx)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: ctx.response.Writer,
+				encoding:       encoding,
+				minSize:        config.MinSize,
+				allowed:        allowed,
+			}
+
+			switch encoding {
+			case EncodingGzip:
+				cw.pool = &gzips
+			case EncodingDeflate:
+				cw.pool = &zlibs
+			}
+
+			ctx.response.Writer = cw
+			defer func() {
+				cw.Close()
+				ctx.response.Writer = cw.ResponseWriter
+			}()
+
+			return next(ctx)
+		}
+	}
+}
+
+// NegotiateEncoding returns the first of the giving offered content encodings
+// with the highest quality within the Accept-Encoding header value, or an empty
+// string if none is acceptable.
+func NegotiateEncoding(accept string, offers ...string) string {
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") && !strings.HasPrefix(param, "Q=") {
+				continue
+			}
+
+			if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				quality = value
+			}
+		}
+
+		qualities[coding] = quality
+	}
+
+	var best string
+	var bestQuality float64
+
+	for _, offer := range offers {
+		quality, ok := qualities[strings.ToLower(offer)]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+
+		if ok && quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+
+	return best
+}
+
+// hasToken returns true/false if any of the comma separated header values holds
+// the giving token.
+func hasToken(values []string, token string) bool {
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//=========================================================================================
+
+// compressor defines the writers of compress/gzip and compress/zlib.
+type compressor interface {
+	Write([]byte) (int, error)
+	Flush() error
+	Close() error
+	Reset(w io.Writer)
+}
+
+// compressWriter implements the http.ResponseWriter interface, compressing the
+// output once it decides the response is worth it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	allowed  func(string) bool
+	pool     *sync.Pool
+
+	buf         []byte
+	code        int
+	wroteHeader bool
+	decided     bool
+	hijacked    bool
+	zw          compressor
+}
+
+// WriteHeader implements the http.ResponseWriter interface, holding the status
+// till the response is decided upon.
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader || c.decided {
+		return
+	}
+
+	c.code = code
+	c.wroteHeader = true
+
+	// Responses without a body have nothing to compress.
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		c.decide(false)
+	}
+}
+
+// Write implements the http.ResponseWriter interface.
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		c.buf = append(c.buf, b...)
+		if len(c.buf) < c.minSize {
+			return len(b), nil
+		}
+
+		if err := c.decide(false); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if c.zw != nil {
+		return c.zw.Write(b)
+	}
+
+	return c.ResponseWriter.Write(b)
+}
+
+// decide writes the held status and buffered output, compressing them if the
+// response is allowed to. Flushed responses are compressed regardless of size.
+func (c *compressWriter) decide(flushed bool) error {
+	c.decided = true
+
+	if !c.wroteHeader {
+		c.code = http.StatusOK
+	}
+
+	header := c.ResponseWriter.Header()
+	if header.Get(HeaderContentType) == "" && len(c.buf) != 0 {
+		header.Set(HeaderContentType, http.DetectContentType(c.buf))
+	}
+
+	compress := c.code >= http.StatusOK &&
+		c.code != http.StatusNoContent &&
+		c.code != http.StatusNotModified &&
+		c.code != http.StatusPartialContent &&
+		header.Get(HeaderContentRange) == "" &&
+		header.Get(HeaderContentEncoding) == "" &&
+		(flushed || len(c.buf) >= c.minSize) &&
+		c.allowed(header.Get(HeaderContentType))
+
+	if compress {
+		header.Del(HeaderContentLength)
+		header.Set(HeaderContentEncoding, c.encoding)
+
+		// Compressed bodies differ in bytes from the original, hence strong
+		// entity tags are weakened.
+		if etag := header.Get(HeaderETag); strings.HasPrefix(etag, `"`) {
+			header.Set(HeaderETag, "W/"+etag)
+		}
+
+		c.zw = c.pool.Get().(compressor)
+		c.zw.Reset(c.ResponseWriter)
+	}
+
+	c.ResponseWriter.WriteHeader(c.code)
+
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if c.zw != nil {
+		_, err = c.zw.Write(c.buf)
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf)
+	}
+
+	c.buf = nil
+	return err
+}
+
+// Close writes out any held output and ends the compressed stream.
+func (c *compressWriter) Close() error {
+	if c.hijacked {
+		return nil
+	}
+
+	if !c.decided {
+		if !c.wroteHeader && len(c.buf) == 0 {
+			return nil
+		}
+
+		if err := c.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if c.zw == nil {
+		return nil
+	}
+
+	err := c.zw.Close()
+	c.zw.Reset(nil)
+	c.pool.Put(c.zw)
+	c.zw = nil
+	return err
+}
+
+// Flush implements the http.Flusher interface.
+func (c *compressWriter) Flush() {
+	if !c.decided && c.decide(true) != nil {
+		return
+	}
+
+	if c.zw != nil {
+		c.zw.Flush()
+	}
+
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface.
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrNoHijack
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		c.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+// Push implements the http.Pusher interface.
+func (c *compressWriter) Push(target string, ops *http.PushOptions) error {
+	if pusher, ok := c.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, ops)
+	}
+	return ErrNoPush
+}
+
+// CloseNotify implements the http.CloseNotifier interface.
+func (c *compressWriter) CloseNotify() <-chan bool {
+	if notifier, ok := c.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
@@ -0,0 +1,155 @@
+package httputil_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/tests"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "gzip, deflate, br", expected: "gzip"},
+		{accept: "deflate, gzip;q=0.5", expected: "deflate"},
+		{accept: "gzip;q=0, deflate;q=0.1", expected: "deflate"},
+		{accept: "gzip;q=0", expected: ""},
+		{accept: "*", expected: "gzip"},
+		{accept: "br, *;q=0.2, gzip;q=0", expected: "deflate"},
+		{accept: "identity", expected: ""},
+		{accept: "", expected: ""},
+	}
+
+	for _, item := range cases {
+		if got := httputil.NegotiateEncoding(item.accept, "gzip", "deflate"); got != item.expected {
+			tests.Failed("Should have negotiated %q for %q but got %q", item.expected, item.accept, got)
+		}
+	}
+	tests.Passed("Should have negotiated encodings by quality")
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compress me please ", 100)
+
+	router := httputil.NewRouter()
+	router.Use(httputil.Compress(httputil.CompressConfig{MinSize: 256}))
+
+	router.Get("/large", func(ctx *httputil.Context) error {
+		ctx.SetHeader("ETag", `"v1"`)
+		return ctx.String(http.StatusOK, body)
+	})
+
+	router.Get("/small", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "tiny")
+	})
+
+	router.Get("/binary", func(ctx *httputil.Context) error {
+		return ctx.Blob(http.StatusOK, "image/png", []byte(body))
+	})
+
+	router.Get("/partial", func(ctx *httputil.Context) error {
+		ctx.SetHeader("Content-Range", "bytes 0-1899/3800")
+		return ctx.String(http.StatusPartialContent, body)
+	})
+
+	router.Get("/stream", func(ctx *httputil.Context) error {
+		ctx.SetHeader("Content-Type", "text/plain")
+		ctx.Response().Write([]byte("first"))
+		ctx.Response().Flush()
+		ctx.Response().Write([]byte(" second"))
+		return nil
+	})
+
+	send := func(path string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("/large", "gzip, deflate, br")
+	if res.Header().Get("Content-Encoding") != "gzip" {
+		tests.Failed("Should have compressed response with gzip: %+q", res.Header())
+	}
+
+	if res.Header().Get("Vary") != "Accept-Encoding" {
+		tests.Failed("Should have set Vary header: %+q", res.Header())
+	}
+
+	if res.Header().Get("ETag") != `W/"v1"` {
+		tests.Failed("Should have weakened ETag: %+q", res.Header())
+	}
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		tests.Failed("Should have read gzip body: %+q", err)
+	}
+
+	if data, _ := ioutil.ReadAll(zr); string(data) != body {
+		tests.Failed("Should have matched original body")
+	}
+	tests.Passed("Should have compressed response with gzip")
+
+	res = send("/large", "deflate")
+	zlr, err := zlib.NewReader(res.Body)
+	if res.Header().Get("Content-Encoding") != "deflate" || err != nil {
+		tests.Failed("Should have compressed response with deflate: %+q", err)
+	}
+
+	if data, _ := ioutil.ReadAll(zlr); string(data) != body {
+		tests.Failed("Should have matched original body")
+	}
+	tests.Passed("Should have compressed response with deflate")
+
+	res = send("/large", "")
+	if res.Header().Get("Content-Encoding") != "" || res.Body.String() != body {
+		tests.Failed("Should have sent response as is without Accept-Encoding")
+	}
+
+	if res.Header().Get("Vary") != "Accept-Encoding" {
+		tests.Failed("Should have set Vary header for uncompressed response: %+q", res.Header())
+	}
+	tests.Passed("Should have sent response as is without Accept-Encoding")
+
+	if res := send("/small", "gzip"); res.Header().Get("Content-Encoding") != "" || res.Body.String() != "tiny" {
+		tests.Failed("Should have sent response below minimum size as is")
+	}
+	tests.Passed("Should have sent response below minimum size as is")
+
+	if res := send("/binary", "gzip"); res.Header().Get("Content-Encoding") != "" || res.Body.String() != body {
+		tests.Failed("Should have sent disallowed content type as is")
+	}
+	tests.Passed("Should have sent disallowed content type as is")
+
+	if res := send("/partial", "gzip"); res.Code != http.StatusPartialContent || res.Header().Get("Content-Encoding") != "" || res.Body.String() != body {
+		tests.Failed("Should have sent partial response as is: %+q", res.Header())
+	}
+	tests.Passed("Should have sent partial response as is")
+
+	res = send("/stream", "gzip")
+	if !res.Flushed || res.Header().Get("Content-Encoding") != "gzip" {
+		tests.Failed("Should have flushed compressed stream: %+q", res.Header())
+	}
+
+	zr, err = gzip.NewReader(res.Body)
+	if err != nil {
+		tests.Failed("Should have read gzip body: %+q", err)
+	}
+
+	if data, _ := ioutil.ReadAll(zr); string(data) != "first second" {
+		tests.Failed("Should have matched streamed body but got %q", data)
+	}
+	tests.Passed("Should have flushed compressed stream")
+}
@@ -17,6 +17,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/influx6/faux/bag"
 	"github.com/influx6/faux/metrics"
@@ -25,6 +26,13 @@ import (
 
 const (
 	defaultMemory = 64 << 20 // 64 MB
+
+	// CSRFTokenKey defines the key used to store the CSRF token of a request.
+	CSRFTokenKey = "httputil.csrf.token"
+
+	// CSRFFieldKey defines the key used to store the form field name the CSRF
+	// token is expected in.
+	CSRFFieldKey = "httputil.csrf.field"
 )
 
 // Render defines a giving type which exposes a Render method for
@@ -50,7 +58,7 @@ func Apply(c *Context, ops ...Options) *Context {
 // SetValueBag sets the ValueBag of the giving context.
 func SetValueBag(vbag bag.ValueBag) Options {
 	return func(c *Context) {
-		c.ValueBag = vbag
+		c.setBag(vbag)
 	}
 }
 
@@ -92,11 +100,12 @@ func SetResponse(r *Response) Options {
 	}
 }
 
-// SetRequest returns a option function to set the request of a Context.
+// SetRequest returns a option function to set the request of a Context. The
+// form of the request is loaded into the ValueBag once a value is first read
+// from it.
 func SetRequest(r *http.Request) Options {
 	return func(c *Context) {
 		c.request = r
-		c.InitForms()
 	}
 }
 
@@ -129,18 +138,22 @@ type Context struct {
 	request         *http.Request
 	metrics         metrics.Metrics
 	flash           map[string][]string
+	params          map[string]string
+	formsLoaded     bool
 	notfoundHandler Handler
+	etagMode        ETagMode
 }
 
 // NewContext returns a new Context with the Options slice applied.
 func NewContext(ops ...Options) *Context {
 	c := &Context{
-		metrics:  metrics.New(),
-		id:       uuid.NewV4().String(),
-		flash:    make(map[string][]string),
-		ValueBag: bag.NewValueBag(),
+		metrics: metrics.New(),
+		id:      uuid.NewV4().String(),
+		flash:   make(map[string][]string),
 	}
 
+	c.setBag(bag.NewValueBag())
+
 	for _, op := range ops {
 		if op == nil {
 			continue
@@ -163,6 +176,16 @@ func (c *Context) Bag() bag.ValueBag {
 	return c.ValueBag
 }
 
+// setBag sets the ValueBag of the Context, wrapped to load the form of the
+// request on first read.
+func (c *Context) setBag(vbag bag.ValueBag) {
+	if fb, ok := vbag.(*formBag); ok {
+		vbag = fb.ValueBag
+	}
+
+	c.ValueBag = &formBag{ValueBag: vbag, ctx: c}
+}
+
 // Metrics returns metric logger for giving context.
 func (c *Context) Metrics() metrics.Metrics {
 	return c.metrics
@@ -294,6 +317,24 @@ func (c *Context) Path() string {
 	return c.path
 }
 
+// Param returns the value of the path parameter of the giving name, as set by
+// the router from the matched route. Unlike the ValueBag, which also carries
+// query and form values, path parameters can not be set by clients.
+func (c *Context) Param(name string) string {
+	return c.params[name]
+}
+
+// SetParam sets the value of the path parameter of the giving name, also
+// storing it into the ValueBag.
+func (c *Context) SetParam(name string, value string) {
+	if c.params == nil {
+		c.params = make(map[string]string)
+	}
+
+	c.params[name] = value
+	c.Bag().Set(name, value)
+}
+
 // QueryParam finds the giving value for the giving name in the querie set.
 func (c *Context) QueryParam(name string) string {
 	if c.query == nil {
@@ -492,18 +533,18 @@ func (c *Context) XMLPretty(code int, i interface{}, indent string) (err error)
 
 // XMLBlob renders giving xml as response with proper mime type.
 func (c *Context) XMLBlob(code int, b []byte) (err error) {
-	c.response.Header().Set(HeaderContentType, MIMEApplicationXMLCharsetUTF8)
-	c.response.WriteHeader(code)
-	if _, err = c.response.Write([]byte(xml.Header)); err != nil {
-		return
-	}
-	_, err = c.response.Write(b)
-	return
+	return c.Blob(code, MIMEApplicationXMLCharsetUTF8, append([]byte(xml.Header), b...))
 }
 
 // Blob write giving byte slice as response with proper mime type.
+// Successful responses are answered with a 304 if the client holds a fresh
+// copy by their ETag or Last-Modified headers. See ETagMW.
 func (c *Context) Blob(code int, contentType string, b []byte) (err error) {
 	c.response.Header().Set(HeaderContentType, contentType)
+	if c.writeFresh(code, b) {
+		return nil
+	}
+
 	c.response.WriteHeader(code)
 	_, err = c.response.Write(b)
 	return
@@ -517,7 +558,8 @@ func (c *Context) Stream(code int, contentType string, r io.Reader) (err error)
 	return
 }
 
-// File streams file content into response.
+// File streams file content into response, handling conditional and range
+// requests by its modification time and ETag header. See ETagMW.
 func (c *Context) File(file string) (err error) {
 	f, err := os.Open(file)
 	if err != nil {
@@ -540,6 +582,10 @@ func (c *Context) File(file string) (err error) {
 		}
 	}
 
+	if c.etagMode != NoETag && c.response.Header().Get(HeaderETag) == "" {
+		c.SetETag(fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()), c.etagMode == WeakETag)
+	}
+
 	http.ServeContent(c.Response(), c.Request(), fi.Name(), fi.ModTime(), f)
 	return
 }
@@ -591,6 +637,29 @@ func (c *Context) Flash(name string) []string {
 	return messages
 }
 
+// CSRFToken returns the CSRF token issued for the request by a CSRF middleware,
+// or an empty string if none was issued.
+func (c *Context) CSRFToken() string {
+	if c == nil || c.ValueBag == nil {
+		return ""
+	}
+
+	token, _ := c.ValueBag.Get(CSRFTokenKey).(string)
+	return token
+}
+
+// CSRFField returns a hidden form input carrying the CSRF token issued for the
+// request, or an empty string if none was issued.
+func (c *Context) CSRFField() string {
+	token := c.CSRFToken()
+	if token == "" {
+		return ""
+	}
+
+	name, _ := c.ValueBag.Get(CSRFFieldKey).(string)
+	return fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, htemplate.HTMLEscapeString(name), htemplate.HTMLEscapeString(token))
+}
+
 // NotFound writes calls the giving response against the NotFound handler
 // if present, else uses a http.StatusMovedPermanently status code.
 func (c *Context) NotFound() error {
@@ -628,18 +697,27 @@ func (c *Context) Redirect(code int, url string) error {
 }
 
 // InitForms will call the appropriate function to parse the necessary form values
-// within the giving request context.
+// within the giving request context, adding them into the ValueBag without
+// replacing route parameters. Forms are only loaded once, either when a value
+// is first read from the ValueBag or by the Router within all Middleware of a
+// route, so those like BodyLimitMW apply to form bodies.
 func (c *Context) InitForms() error {
-	if c.request == nil {
+	if c.request == nil || c.formsLoaded {
 		return nil
 	}
 
+	c.formsLoaded = true
+
 	values, err := c.FormParams()
 	if err != nil {
 		return err
 	}
 
 	for key, val := range values {
+		if _, ok := c.params[key]; ok {
+			continue
+		}
+
 		if len(val) == 1 {
 			c.Bag().Set(key, val[0])
 			continue
@@ -656,15 +734,99 @@ func (c *Context) Reset(r *http.Request, w http.ResponseWriter) {
 	c.request = r
 	c.query = nil
 	c.notfoundHandler = nil
+	c.etagMode = NoETag
 	c.metrics = metrics.New()
-	c.ValueBag = bag.NewValueBag()
+	c.setBag(bag.NewValueBag())
 	c.id = uuid.NewV4().String()
 	c.response = &Response{Writer: w}
 	c.flash = make(map[string][]string)
+	c.params = nil
+	c.formsLoaded = false
+}
+
+//=========================================================================================
+
+// formBag implements the bag.ValueBag interface, loading the form of the
+// request of its Context into the ValueBag it wraps before values are read.
+type formBag struct {
+	bag.ValueBag
+	ctx *Context
+}
+
+// WithValue returns a copy of the wrapped ValueBag with the key and value
+// added, without loading the form.
+func (f *formBag) WithValue(key interface{}, value interface{}) bag.ValueBag {
+	return f.ValueBag.WithValue(key, value)
+}
+
+// Get returns the value of the giving key.
+func (f *formBag) Get(key interface{}) interface{} {
+	f.ctx.InitForms()
+	return f.ValueBag.Get(key)
+}
+
+// GetString returns the string value of the giving key.
+func (f *formBag) GetString(key interface{}) string {
+	f.ctx.InitForms()
+	return f.ValueBag.GetString(key)
+}
+
+// GetBool returns the bool value of the giving key.
+func (f *formBag) GetBool(key interface{}) bool {
+	f.ctx.InitForms()
+	return f.ValueBag.GetBool(key)
+}
+
+// GetInt returns the int value of the giving key.
+func (f *formBag) GetInt(key interface{}) int {
+	f.ctx.InitForms()
+	return f.ValueBag.GetInt(key)
+}
+
+// GetInt8 returns the int8 value of the giving key.
+func (f *formBag) GetInt8(key interface{}) int8 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetInt8(key)
+}
+
+// GetInt16 returns the int16 value of the giving key.
+func (f *formBag) GetInt16(key interface{}) int16 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetInt16(key)
+}
+
+// GetInt32 returns the int32 value of the giving key.
+func (f *formBag) GetInt32(key interface{}) int32 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetInt32(key)
+}
 
-	c.InitForms()
+// GetInt64 returns the int64 value of the giving key.
+func (f *formBag) GetInt64(key interface{}) int64 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetInt64(key)
 }
 
+// GetFloat32 returns the float32 value of the giving key.
+func (f *formBag) GetFloat32(key interface{}) float32 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetFloat32(key)
+}
+
+// GetFloat64 returns the float64 value of the giving key.
+func (f *formBag) GetFloat64(key interface{}) float64 {
+	f.ctx.InitForms()
+	return f.ValueBag.GetFloat64(key)
+}
+
+// GetDuration returns the time.Duration value of the giving key.
+func (f *formBag) GetDuration(key interface{}) time.Duration {
+	f.ctx.InitForms()
+	return f.ValueBag.GetDuration(key)
+}
+
+//=========================================================================================
+
 func (c *Context) contentDisposition(file, name, dispositionType string) (err error) {
 	c.response.Header().Set(HeaderContentDisposition, fmt.Sprintf("%s; filename=%s", dispositionType, name))
 	c.File(file)
@@ -0,0 +1,177 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errors.
+var (
+	ErrCORSAnyOriginCredentials = errors.New("CORS can not allow credentials for any origin")
+)
+
+// CORSConfig defines the configuration used by the CORS middleware.
+type CORSConfig struct {
+	// AllowOrigins sets the origins allowed to make requests, either exactly
+	// like https://app.example.com, with a wildcard like https://*.example.com
+	// or all origins with *.
+	AllowOrigins []string
+
+	// AllowOriginFunc sets a function deciding if an origin is allowed, used
+	// for origins not matched by AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowMethods sets the methods allowed for requests, defaults to GET, HEAD,
+	// POST, PUT, PATCH and DELETE.
+	AllowMethods []string
+
+	// AllowHeaders sets the headers allowed for requests, the headers requested
+	// by preflight requests are allowed if empty.
+	AllowHeaders []string
+
+	// ExposeHeaders sets the response headers made available to clients.
+	ExposeHeaders []string
+
+	// AllowCredentials sets cookies and authorization headers to be allowed,
+	// the origin is then always echoed instead of *. It can not be combined
+	// with the * origin, as that would grant every site credentialed access.
+	AllowCredentials bool
+
+	// MaxAge sets how long clients may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// CORS returns a Middleware which implements Cross-Origin Resource Sharing for
+// the giving config. Preflight requests are answered with a 204 without calling
+// the next Handler, while other requests from allowed origins get their CORS
+// headers before calling it. It panics with ErrCORSAnyOriginCredentials if the
+// config allows credentials for all origins.
+//
+// As the Router answers OPTIONS requests for unregistered methods itself, the
+// middleware should wrap the Router to handle preflight requests:
+//
+//	handler := httputil.ServeHandler(httputil.CORS(config)(router.Serve))
+func CORS(config CORSConfig) Middleware {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = []string{
+			http.MethodGet,
+			http.MethodHead,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		}
+	}
+
+	var anyOrigin bool
+	var exact []string
+	var wildcards [][2]string
+
+	for _, origin := range config.AllowOrigins {
+		origin = strings.ToLower(origin)
+
+		switch {
+		case origin == "*":
+			anyOrigin = true
+		case strings.Contains(origin, "*"):
+			at := strings.Index(origin, "*")
+			wildcards = append(wildcards, [2]string{origin[:at], origin[at+1:]})
+		default:
+			exact = append(exact, origin)
+		}
+	}
+
+	if anyOrigin && config.AllowCredentials {
+		panic(ErrCORSAnyOriginCredentials)
+	}
+
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+
+		lower := strings.ToLower(origin)
+		for _, item := range exact {
+			if item == lower {
+				return true
+			}
+		}
+
+		for _, item := range wildcards {
+			if len(lower) > len(item[0])+len(item[1]) && strings.HasPrefix(lower, item[0]) && strings.HasSuffix(lower, item[1]) {
+				return true
+			}
+		}
+
+		return config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+	}
+
+	// Responses only differ by origin when the origin is echoed back.
+	varies := !anyOrigin || config.AllowCredentials || config.AllowOriginFunc != nil
+
+	methods := strings.Join(config.AllowMethods, ", ")
+	headers := strings.Join(config.AllowHeaders, ", ")
+	expose := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			origin := ctx.GetHeader(HeaderOrigin)
+			preflight := ctx.Request().Method == http.MethodOptions && ctx.GetHeader(HeaderAccessControlRequestMethod) != ""
+
+			if varies {
+				ctx.AddHeader(HeaderVary, HeaderOrigin)
+			}
+
+			if preflight {
+				ctx.AddHeader(HeaderVary, HeaderAccessControlRequestMethod)
+				ctx.AddHeader(HeaderVary, HeaderAccessControlRequestHeaders)
+			}
+
+			if origin == "" {
+				return next(ctx)
+			}
+
+			if !allowed(origin) {
+				if preflight {
+					return ctx.NoContent(http.StatusNoContent)
+				}
+				return next(ctx)
+			}
+
+			if !varies {
+				ctx.SetHeader(HeaderAccessControlAllowOrigin, "*")
+			} else {
+				ctx.SetHeader(HeaderAccessControlAllowOrigin, origin)
+			}
+
+			if config.AllowCredentials {
+				ctx.SetHeader(HeaderAccessControlAllowCredentials, "true")
+			}
+
+			if !preflight {
+				if expose != "" {
+					ctx.SetHeader(HeaderAccessControlExposeHeaders, expose)
+				}
+				return next(ctx)
+			}
+
+			ctx.SetHeader(HeaderAccessControlAllowMethods, methods)
+
+			if headers != "" {
+				ctx.SetHeader(HeaderAccessControlAllowHeaders, headers)
+			} else if requested := ctx.GetHeader(HeaderAccessControlRequestHeaders); requested != "" {
+				ctx.SetHeader(HeaderAccessControlAllowHeaders, requested)
+			}
+
+			if config.MaxAge > 0 {
+				ctx.SetHeader(HeaderAccessControlMaxAge, maxAge)
+			}
+
+			return ctx.NoContent(http.StatusNoContent)
+		}
+	}
+}
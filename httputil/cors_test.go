@@ -0,0 +1,92 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/tests"
+)
+
+func TestCORS(t *testing.T) {
+	var calls int
+	handler := httputil.ServeHandler(httputil.CORS(httputil.CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+		MaxAge:           10 * time.Minute,
+	})(func(ctx *httputil.Context) error {
+		calls++
+		return ctx.NoContent(http.StatusOK)
+	}))
+
+	send := func(method string, origin string, requestMethod string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+			req.Header.Set("Access-Control-Request-Headers", "X-Token")
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("OPTIONS", "https://api.example.org", "PUT")
+	if res.Code != http.StatusNoContent || calls != 0 {
+		tests.Failed("Should have answered preflight without calling handler: %d %d", res.Code, calls)
+	}
+	tests.Passed("Should have answered preflight without calling handler")
+
+	header := res.Header()
+	if header.Get("Access-Control-Allow-Origin") != "https://api.example.org" ||
+		header.Get("Access-Control-Allow-Credentials") != "true" ||
+		header.Get("Access-Control-Allow-Headers") != "X-Token" ||
+		header.Get("Access-Control-Max-Age") != "600" ||
+		!strings.Contains(header.Get("Access-Control-Allow-Methods"), "PUT") ||
+		header.Get("Vary") != "Origin" {
+		tests.Failed("Should have set preflight headers: %#v", header)
+	}
+	tests.Passed("Should have set preflight headers")
+
+	res = send("GET", "http://localhost:3000", "")
+	if res.Code != http.StatusOK || calls != 1 || res.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" || res.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		tests.Failed("Should have allowed origin through function: %#v", res.Header())
+	}
+	tests.Passed("Should have allowed origin through function")
+
+	res = send("GET", "https://evil.com", "")
+	if res.Code != http.StatusOK || res.Header().Get("Access-Control-Allow-Origin") != "" || res.Header().Get("Vary") != "Origin" {
+		tests.Failed("Should have served disallowed origin without CORS headers: %#v", res.Header())
+	}
+	tests.Passed("Should have served disallowed origin without CORS headers")
+
+	any := httputil.ServeHandler(httputil.CORS(httputil.CORSConfig{AllowOrigins: []string{"*"}})(httputil.OKRequest))
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://anything.com")
+	any.ServeHTTP(res, req)
+
+	if res.Header().Get("Access-Control-Allow-Origin") != "*" || res.Header().Get("Vary") != "" {
+		tests.Failed("Should have allowed any origin without Vary: %#v", res.Header())
+	}
+	tests.Passed("Should have allowed any origin without Vary")
+}
+
+func TestCORSRefusesAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recovered := recover(); recovered != httputil.ErrCORSAnyOriginCredentials {
+			tests.Failed("Should have panicked for credentials with any origin but got %+v", recovered)
+		}
+		tests.Passed("Should have panicked for credentials with any origin")
+	}()
+
+	httputil.CORS(httputil.CORSConfig{AllowOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})
+}
@@ -0,0 +1,348 @@
+// Package csrf provides a httputil middleware protecting against cross-site
+// request forgery, by issuing a token per session which must be sent back with
+// every request of an unsafe method.
+//
+// Tokens are kept within the Session when the sessions middleware runs before
+// it, else within a signed cookie. The token of every request is available to
+// templates rendered with Context.HTMLTemplate through the csrfToken and
+// csrfField functions:
+//
+//	<form method="POST" action="/transfer">
+//		{{ csrfField }}
+//	</form>
+//
+// As template functions must be known at parse time, templates should be parsed
+// with httputil.HTMLContextFunctions(nil) as their functions.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/httputil/sessions"
+)
+
+// tokenSize defines the size in bytes of tokens.
+const tokenSize = 32
+
+// SessionKey defines the key used to store the token within a Session.
+const SessionKey = "csrf.token"
+
+// errors.
+var (
+	ErrNoSigningKey = errors.New("CSRF signing key is required without sessions")
+	ErrInvalidToken = errors.New("CSRF token is missing or invalid")
+	ErrBadOrigin    = errors.New("CSRF origin does not match")
+	ErrNoReferer    = errors.New("CSRF referer is required for secure requests")
+)
+
+// Config defines the configuration used by the CSRF middleware.
+type Config struct {
+	// SigningKey sets the key used to sign the token cookie with HMAC-SHA256,
+	// it is required unless tokens are kept within sessions.
+	SigningKey []byte
+
+	// CookieName sets the name of the token cookie, defaults to "csrf".
+	CookieName string
+
+	// Path sets the path of the token cookie, defaults to "/".
+	Path string
+
+	// Domain sets the domain of the token cookie.
+	Domain string
+
+	// Secure sets the token cookie to be sent only over https.
+	Secure bool
+
+	// HeaderName sets the header the token is read from, defaults to "X-CSRF-Token".
+	HeaderName string
+
+	// FieldName sets the form or multipart field the token is read from,
+	// defaults to "csrf_token".
+	FieldName string
+
+	// TrustedOrigins sets origins like https://app.example.com allowed besides
+	// the origin of the request itself.
+	TrustedOrigins []string
+
+	// Failure sets the Handler called when a request fails verification, with
+	// the reason available through Reason. Defaults to returning a HTTPError
+	// with a 403.
+	Failure httputil.Handler
+}
+
+// reasonKey defines the key used to store the failure reason within the Context.
+const reasonKey = "httputil.csrf.reason"
+
+// Reason returns the error a request failed verification with, for use within
+// a Failure handler.
+func Reason(ctx *httputil.Context) error {
+	err, _ := ctx.Bag().Get(reasonKey).(error)
+	return err
+}
+
+// Token returns the masked token of the giving Context. Masked tokens differ on
+// every request, preventing the token from being recovered through compression.
+func Token(ctx *httputil.Context) string {
+	return ctx.CSRFToken()
+}
+
+// New returns a new CSRF middleware for the giving Config. Requests with safe
+// methods are issued a token, while all others must match the origin of the
+// request or a trusted origin and carry the token.
+func New(config Config) (httputil.Middleware, error) {
+	if config.CookieName == "" {
+		config.CookieName = "csrf"
+	}
+
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+
+	if config.FieldName == "" {
+		config.FieldName = "csrf_token"
+	}
+
+	trusted := make([]string, len(config.TrustedOrigins))
+	for index, origin := range config.TrustedOrigins {
+		trusted[index] = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	}
+	config.TrustedOrigins = trusted
+
+	g := &guard{config: config}
+
+	return func(next httputil.Handler) httputil.Handler {
+		return func(ctx *httputil.Context) error {
+			session := sessions.Get(ctx)
+			if session == nil && len(config.SigningKey) == 0 {
+				return ErrNoSigningKey
+			}
+
+			token, err := g.token(ctx, session)
+			if err != nil {
+				return err
+			}
+
+			masked, err := mask(token)
+			if err != nil {
+				return err
+			}
+
+			ctx.Bag().Set(httputil.CSRFTokenKey, masked)
+			ctx.Bag().Set(httputil.CSRFFieldKey, config.FieldName)
+			ctx.AddHeader(httputil.HeaderVary, "Cookie")
+
+			if !isSafe(ctx.Request().Method) {
+				if err := g.verify(ctx, token); err != nil {
+					if config.Failure != nil {
+						ctx.Bag().Set(reasonKey, err)
+						return config.Failure(ctx)
+					}
+
+					return httputil.HTTPError{Code: http.StatusForbidden, Err: err}
+				}
+			}
+
+			return next(ctx)
+		}
+	}, nil
+}
+
+type guard struct {
+	config Config
+}
+
+// token returns the unmasked token of the session or cookie, issuing a new
+// token if none exists.
+func (g *guard) token(ctx *httputil.Context, session *sessions.Session) ([]byte, error) {
+	if session != nil {
+		if token, err := b64.DecodeString(session.GetString(SessionKey)); err == nil && len(token) == tokenSize {
+			return token, nil
+		}
+	} else if cookie, err := ctx.Cookie(g.config.CookieName); err == nil {
+		if token, ok := g.decode(cookie.Value); ok {
+			return token, nil
+		}
+	}
+
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	if session != nil {
+		session.Set(SessionKey, b64.EncodeToString(token))
+		return token, nil
+	}
+
+	ctx.SetCookie(&http.Cookie{
+		Name:     g.config.CookieName,
+		Value:    g.encode(token),
+		Path:     g.config.Path,
+		Domain:   g.config.Domain,
+		Secure:   g.config.Secure,
+		HttpOnly: true,
+	})
+
+	return token, nil
+}
+
+// verify checks the origin of the request and the token it carries.
+func (g *guard) verify(ctx *httputil.Context, token []byte) error {
+	if err := g.checkOrigin(ctx); err != nil {
+		return err
+	}
+
+	sent, ok := unmask(g.sentToken(ctx))
+	if !ok || subtle.ConstantTimeCompare(sent, token) != 1 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// sentToken returns the token sent through the header, form or multipart field.
+func (g *guard) sentToken(ctx *httputil.Context) string {
+	if token := ctx.GetHeader(g.config.HeaderName); token != "" {
+		return token
+	}
+
+	// Forms are yet to be loaded when the guard runs before the route Handler.
+	ctx.InitForms()
+
+	req := ctx.Request()
+	if token := req.PostForm.Get(g.config.FieldName); token != "" {
+		return token
+	}
+
+	if req.MultipartForm != nil {
+		if values := req.MultipartForm.Value[g.config.FieldName]; len(values) != 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+// checkOrigin requires the Origin or else Referer header to match the origin of
+// the request or a trusted origin. Requests with neither are allowed over http,
+// as some clients strip both, but refused over https.
+func (g *guard) checkOrigin(ctx *httputil.Context) error {
+	source := ctx.GetHeader(httputil.HeaderOrigin)
+	if source == "" || source == "null" {
+		source = ctx.GetHeader("Referer")
+	}
+
+	if source == "" {
+		if ctx.Scheme() == "https" {
+			return ErrNoReferer
+		}
+		return nil
+	}
+
+	parsed, err := url.Parse(source)
+	if err != nil || parsed.Host == "" {
+		return ErrBadOrigin
+	}
+
+	origin := strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+	if origin == strings.ToLower(ctx.Scheme()+"://"+ctx.Request().Host) {
+		return nil
+	}
+
+	for _, trusted := range g.config.TrustedOrigins {
+		if origin == trusted {
+			return nil
+		}
+	}
+
+	return ErrBadOrigin
+}
+
+//=========================================================================================
+
+var b64 = base64.RawURLEncoding
+
+// encode returns the token signed for the cookie in the form token.signature.
+func (g *guard) encode(token []byte) string {
+	payload := b64.EncodeToString(token)
+	return payload + "." + b64.EncodeToString(g.sign(payload))
+}
+
+// decode verifies the signature of the cookie value, returning its token.
+func (g *guard) decode(value string) ([]byte, bool) {
+	at := strings.LastIndex(value, ".")
+	if at == -1 {
+		return nil, false
+	}
+
+	signature, err := b64.DecodeString(value[at+1:])
+	if err != nil || !hmac.Equal(signature, g.sign(value[:at])) {
+		return nil, false
+	}
+
+	token, err := b64.DecodeString(value[:at])
+	if err != nil || len(token) != tokenSize {
+		return nil, false
+	}
+
+	return token, true
+}
+
+func (g *guard) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, g.config.SigningKey)
+	mac.Write([]byte(g.config.CookieName + "|" + payload))
+	return mac.Sum(nil)
+}
+
+// mask returns the token xored with a random pad, prefixed by the pad.
+func mask(token []byte) (string, error) {
+	pad := make([]byte, tokenSize)
+	if _, err := rand.Read(pad); err != nil {
+		return "", err
+	}
+
+	masked := make([]byte, tokenSize*2)
+	copy(masked, pad)
+	for index := range token {
+		masked[tokenSize+index] = pad[index] ^ token[index]
+	}
+
+	return b64.EncodeToString(masked), nil
+}
+
+// unmask returns the token of a masked token.
+func unmask(masked string) ([]byte, bool) {
+	data, err := b64.DecodeString(masked)
+	if err != nil || len(data) != tokenSize*2 {
+		return nil, false
+	}
+
+	token := make([]byte, tokenSize)
+	for index := range token {
+		token[index] = data[index] ^ data[tokenSize+index]
+	}
+
+	return token, true
+}
+
+// isSafe returns true/false if the method is not expected to change state.
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
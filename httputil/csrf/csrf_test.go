@@ -0,0 +1,236 @@
+package csrf_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/httputil/csrf"
+	"github.com/influx6/faux/httputil/sessions"
+	"github.com/influx6/faux/tests"
+)
+
+var signingKey = []byte("signing-key-for-csrf-tests")
+
+func newRouter(config csrf.Config, mws ...httputil.Middleware) *httputil.Router {
+	csrfMW, err := csrf.New(config)
+	if err != nil {
+		tests.Failed("Should have created csrf middleware: %+q", err)
+	}
+
+	router := httputil.NewRouter()
+	router.Use(append(mws, csrfMW)...)
+
+	router.Get("/form", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, csrf.Token(ctx))
+	})
+
+	router.Post("/transfer", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "transferred")
+	})
+
+	return router
+}
+
+// client keeps the cookies set by responses, sending them with every request.
+type client struct {
+	router  *httputil.Router
+	cookies map[string]*http.Cookie
+}
+
+func (c *client) send(req *http.Request) *httptest.ResponseRecorder {
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+
+	res := httptest.NewRecorder()
+	c.router.ServeHTTP(res, req)
+
+	for _, cookie := range (&http.Response{Header: res.Header()}).Cookies() {
+		c.cookies[cookie.Name] = cookie
+	}
+
+	return res
+}
+
+func (c *client) token() string {
+	res := c.send(httptest.NewRequest("GET", "/form", nil))
+	if res.Code != http.StatusOK || res.Body.Len() == 0 {
+		tests.Failed("Should have issued token: %d", res.Code)
+	}
+	return res.Body.String()
+}
+
+func (c *client) post(body io.Reader, contentType string, header map[string]string) int {
+	req := httptest.NewRequest("POST", "/transfer", body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	return c.send(req).Code
+}
+
+func newClient(router *httputil.Router) *client {
+	return &client{router: router, cookies: make(map[string]*http.Cookie)}
+}
+
+func TestCookieTokens(t *testing.T) {
+	c := newClient(newRouter(csrf.Config{SigningKey: signingKey}))
+
+	first := c.token()
+	cookie := c.cookies["csrf"]
+	if cookie == nil || !cookie.HttpOnly {
+		tests.Failed("Should have issued token cookie: %#v", cookie)
+	}
+	tests.Passed("Should have issued token cookie")
+
+	second := c.token()
+	if first == second || c.cookies["csrf"].Value != cookie.Value {
+		tests.Failed("Should have masked the same token differently per request")
+	}
+	tests.Passed("Should have masked the same token differently per request")
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": first}); code != http.StatusOK {
+		tests.Failed("Should have accepted token through header but got %d", code)
+	}
+	tests.Passed("Should have accepted token through header")
+
+	form := url.Values{"csrf_token": {second}, "amount": {"10"}}.Encode()
+	if code := c.post(strings.NewReader(form), "application/x-www-form-urlencoded", nil); code != http.StatusOK {
+		tests.Failed("Should have accepted token through form field but got %d", code)
+	}
+	tests.Passed("Should have accepted token through form field")
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("csrf_token", c.token())
+	writer.Close()
+
+	if code := c.post(&body, writer.FormDataContentType(), nil); code != http.StatusOK {
+		tests.Failed("Should have accepted token through multipart field but got %d", code)
+	}
+	tests.Passed("Should have accepted token through multipart field")
+}
+
+func TestRejectedRequests(t *testing.T) {
+	c := newClient(newRouter(csrf.Config{SigningKey: signingKey, TrustedOrigins: []string{"https://app.example.com/"}}))
+	token := c.token()
+
+	if code := c.post(nil, "", nil); code != http.StatusForbidden {
+		tests.Failed("Should have refused missing token with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused missing token with 403")
+
+	tampered := []byte(token)
+	tampered[len(tampered)-1] ^= 1
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": string(tampered)}); code != http.StatusForbidden {
+		tests.Failed("Should have refused tampered token with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused tampered token with 403")
+
+	forged := newClient(c.router)
+	forged.token()
+	if code := forged.post(nil, "", map[string]string{"X-CSRF-Token": token}); code != http.StatusForbidden {
+		tests.Failed("Should have refused token of another cookie with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused token of another cookie with 403")
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token, "Origin": "https://evil.com"}); code != http.StatusForbidden {
+		tests.Failed("Should have refused bad origin with 403 but got %d", code)
+	}
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token, "Referer": "http://evil.com/page"}); code != http.StatusForbidden {
+		tests.Failed("Should have refused bad referer with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused bad origin and referer with 403")
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token, "Origin": "http://example.com"}); code != http.StatusOK {
+		tests.Failed("Should have accepted same origin but got %d", code)
+	}
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token, "Origin": "https://app.example.com"}); code != http.StatusOK {
+		tests.Failed("Should have accepted trusted origin but got %d", code)
+	}
+	tests.Passed("Should have accepted same and trusted origins")
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token, "X-Forwarded-Proto": "https"}); code != http.StatusForbidden {
+		tests.Failed("Should have refused secure request without referer with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused secure request without referer with 403")
+}
+
+func TestSafeMethodsAndFailure(t *testing.T) {
+	var reason error
+	router := newRouter(csrf.Config{
+		SigningKey: signingKey,
+		Failure: func(ctx *httputil.Context) error {
+			reason = csrf.Reason(ctx)
+			return ctx.String(http.StatusTeapot, "refused")
+		},
+	})
+
+	router.Get("/transfer", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "listed")
+	})
+
+	c := newClient(router)
+	for _, method := range []string{"GET", "HEAD", "OPTIONS"} {
+		res := c.send(httptest.NewRequest(method, "/transfer", nil))
+		if res.Code == http.StatusForbidden || res.Code == http.StatusTeapot {
+			tests.Failed("Should have skipped check for %s but got %d", method, res.Code)
+		}
+	}
+	tests.Passed("Should have skipped check for safe methods")
+
+	if code := c.post(nil, "", map[string]string{"Origin": "https://evil.com"}); code != http.StatusTeapot || reason != csrf.ErrBadOrigin {
+		tests.Failed("Should have called failure handler with reason: %d %+q", code, reason)
+	}
+	tests.Passed("Should have called failure handler with reason")
+}
+
+func TestSessionTokens(t *testing.T) {
+	sessionMW, err := sessions.New(sessions.Config{SigningKey: signingKey, Store: sessions.NewMemoryStore()})
+	if err != nil {
+		tests.Failed("Should have created session middleware: %+q", err)
+	}
+
+	c := newClient(newRouter(csrf.Config{}, sessionMW))
+	token := c.token()
+
+	if c.cookies["csrf"] != nil || c.cookies["session"] == nil {
+		tests.Failed("Should have kept token within session instead of cookie: %#v", c.cookies)
+	}
+	tests.Passed("Should have kept token within session instead of cookie")
+
+	if code := c.post(nil, "", map[string]string{"X-CSRF-Token": token}); code != http.StatusOK {
+		tests.Failed("Should have accepted token of session but got %d", code)
+	}
+	tests.Passed("Should have accepted token of session")
+
+	other := newClient(c.router)
+	other.token()
+	if code := other.post(nil, "", map[string]string{"X-CSRF-Token": token}); code != http.StatusForbidden {
+		tests.Failed("Should have refused token of another session with 403 but got %d", code)
+	}
+	tests.Passed("Should have refused token of another session with 403")
+
+	if _, err := csrf.New(csrf.Config{}); err != nil {
+		tests.Failed("Should have created middleware without signing key: %+q", err)
+	}
+
+	plain := newClient(newRouter(csrf.Config{}))
+	if res := plain.send(httptest.NewRequest("GET", "/form", nil)); res.Code == http.StatusOK {
+		tests.Failed("Should have failed without sessions or signing key")
+	}
+	tests.Passed("Should have failed without sessions or signing key")
+}
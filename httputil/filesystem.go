@@ -48,15 +48,18 @@ func GzipServe(fs filesystem.FileSystem, gzipped bool) Handler {
 
 		mime := GetFileMimeType(stat.Name())
 		ctx.AddHeader("Content-Type", mime)
+		ctx.AddHeader(HeaderVary, HeaderAcceptEncoding)
 
-		if ctx.HasHeader("Accept-Encoding", "gzip") && gzipped {
+		acceptsGzip := NegotiateEncoding(ctx.GetHeader(HeaderAcceptEncoding), EncodingGzip) != ""
+
+		if acceptsGzip && gzipped {
 			ctx.SetHeader("Content-Encoding", "gzip")
 			defer ctx.Status(http.StatusOK)
 			http.ServeContent(ctx.Response(), ctx.Request(), stat.Name(), stat.ModTime(), file)
 			return nil
 		}
 
-		if ctx.HasHeader("Accept-Encoding", "gzip") && !gzipped {
+		if acceptsGzip && !gzipped {
 			ctx.SetHeader("Content-Encoding", "gzip")
 
 			gwriter := gzip.NewWriter(ctx.Response())
@@ -72,7 +75,7 @@ func GzipServe(fs filesystem.FileSystem, gzipped bool) Handler {
 			return nil
 		}
 
-		if !ctx.HasHeader("Accept-Encoding", "gzip") && gzipped {
+		if !acceptsGzip && gzipped {
 			gzreader, err := gzip.NewReader(file)
 			if err != nil {
 				return err
@@ -132,15 +135,18 @@ func HTTPGzipServe(fs http.FileSystem, gzipped bool) Handler {
 
 		mime := GetFileMimeType(stat.Name())
 		ctx.AddHeader("Content-Type", mime)
+		ctx.AddHeader(HeaderVary, HeaderAcceptEncoding)
+
+		acceptsGzip := NegotiateEncoding(ctx.GetHeader(HeaderAcceptEncoding), EncodingGzip) != ""
 
-		if ctx.HasHeader("Accept-Encoding", "gzip") && gzipped {
+		if acceptsGzip && gzipped {
 			ctx.SetHeader("Content-Encoding", "gzip")
 			defer ctx.Status(http.StatusOK)
 			http.ServeContent(ctx.Response(), ctx.Request(), stat.Name(), stat.ModTime(), file)
 			return nil
 		}
 
-		if ctx.HasHeader("Accept-Encoding", "gzip") && !gzipped {
+		if acceptsGzip && !gzipped {
 			ctx.SetHeader("Content-Encoding", "gzip")
 
 			gwriter := gzip.NewWriter(ctx.Response())
@@ -156,7 +162,7 @@ func HTTPGzipServe(fs http.FileSystem, gzipped bool) Handler {
 			return nil
 		}
 
-		if !ctx.HasHeader("Accept-Encoding", "gzip") && gzipped {
+		if !acceptsGzip && gzipped {
 			gzreader, err := gzip.NewReader(file)
 			if err != nil {
 				return err
@@ -0,0 +1,284 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/panics"
+)
+
+// errors.
+var (
+	ErrPanic        = errors.New("Internal Server Error")
+	ErrTimeout      = errors.New("Service Unavailable: request timed out")
+	ErrBodyTooLarge = errors.New("Request Entity Too Large")
+)
+
+// PanicTag defines the tag set on metric entries reported for recovered panics.
+const PanicTag = "httputil:panic"
+
+// TimeoutKey defines the key under which TimeoutMW stores the timeout given to
+// the next Handler within its ValueBag.
+const TimeoutKey = "httputil:timeout"
+
+// handlerPanic carries a panic recovered within another goroutine with the
+// stack of that goroutine.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// RecoverMW returns a Middleware which recovers panics of the next Handler,
+// reporting them through the Metrics of the Context and returning a HTTPError
+// with a 500 if the response was not yet written.
+func RecoverMW(next Handler) Handler {
+	return func(ctx *Context) (err error) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			stack := panics.Stack()
+			if hp, ok := recovered.(handlerPanic); ok {
+				recovered, stack = hp.value, hp.stack
+			}
+
+			fields := metrics.Field{
+				"request_id": ctx.ID(),
+				"method":     ctx.Request().Method,
+				"path":       ctx.Path(),
+			}
+
+			ctx.Metrics().Emit(panics.PanicEntry(PanicTag, recovered, stack, fields))
+
+			if ctx.Response().Committed {
+				err = nil
+				return
+			}
+
+			err = HTTPError{Code: http.StatusInternalServerError, Err: ErrPanic}
+		}()
+
+		return next(ctx)
+	}
+}
+
+//=========================================================================================
+
+// TimeoutMW returns a Middleware which gives the next Handler a deadline after
+// the giving timeout through Context.Context(), returning a HTTPError with a
+// 503 if it has not completed by then.
+//
+// The next Handler runs in its own goroutine with its output buffered till it
+// completes, hence streaming, Flush and Hijack are not available to it. It is
+// given its own copy of the ValueBag, flash messages and params, which are only
+// carried back into the Context if it completes in time. Writes after the
+// deadline fail with http.ErrHandlerTimeout. Panics are carried back into the
+// calling goroutine for RecoverMW.
+func TimeoutMW(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			deadline, cancel := context.WithTimeout(ctx.request.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+
+			inner := *ctx
+			inner.setBag(ctx.ValueBag.WithValue(TimeoutKey, timeout))
+			inner.request = ctx.request.WithContext(deadline)
+			inner.response = &Response{Writer: tw}
+			inner.flash = make(map[string][]string, len(ctx.flash))
+			inner.params = make(map[string]string, len(ctx.params))
+
+			for name, messages := range ctx.flash {
+				inner.flash[name] = append([]string(nil), messages...)
+			}
+
+			for name, value := range ctx.params {
+				inner.params[name] = value
+			}
+
+			done := make(chan error, 1)
+			panicked := make(chan handlerPanic, 1)
+
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						panicked <- handlerPanic{value: recovered, stack: panics.Stack()}
+					}
+				}()
+
+				done <- next(&inner)
+			}()
+
+			select {
+			case err := <-done:
+				ctx.setBag(inner.ValueBag)
+				ctx.flash = inner.flash
+				ctx.params = inner.params
+				ctx.formsLoaded = inner.formsLoaded
+				ctx.request.Form = inner.request.Form
+				ctx.request.PostForm = inner.request.PostForm
+				ctx.request.MultipartForm = inner.request.MultipartForm
+
+				tw.ml.Lock()
+				defer tw.ml.Unlock()
+
+				if ferr := tw.flush(ctx.response); ferr != nil {
+					return ferr
+				}
+
+				return err
+			case hp := <-panicked:
+				panic(hp)
+			case <-deadline.Done():
+				tw.ml.Lock()
+				tw.timedOut = true
+				tw.ml.Unlock()
+
+				if deadline.Err() != context.DeadlineExceeded {
+					// The client went away, there is nobody to answer.
+					return nil
+				}
+
+				return HTTPError{Code: http.StatusServiceUnavailable, Err: ErrTimeout}
+			}
+		}
+	}
+}
+
+// timeoutWriter implements the http.ResponseWriter interface, buffering the
+// output of a Handler running under TimeoutMW.
+type timeoutWriter struct {
+	ml          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+// Header implements the http.ResponseWriter interface.
+func (t *timeoutWriter) Header() http.Header {
+	return t.header
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (t *timeoutWriter) WriteHeader(code int) {
+	t.ml.Lock()
+	defer t.ml.Unlock()
+
+	if t.timedOut || t.wroteHeader {
+		return
+	}
+
+	t.code = code
+	t.wroteHeader = true
+}
+
+// Write implements the http.ResponseWriter interface.
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.ml.Lock()
+	defer t.ml.Unlock()
+
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !t.wroteHeader {
+		t.code = http.StatusOK
+		t.wroteHeader = true
+	}
+
+	return t.buf.Write(b)
+}
+
+// flush writes the buffered output into the giving Response, if any was written.
+func (t *timeoutWriter) flush(res *Response) error {
+	if !t.wroteHeader {
+		return nil
+	}
+
+	header := res.Header()
+	for key, values := range t.header {
+		header[key] = values
+	}
+
+	res.WriteHeader(t.code)
+	_, err := res.Write(t.buf.Bytes())
+	return err
+}
+
+//=========================================================================================
+
+// BodyLimitMW returns a Middleware which limits the request body to the giving
+// number of bytes, returning a HTTPError with a 413 when it is exceeded.
+//
+// Requests declaring a larger Content-Length are refused before the next Handler
+// is called, while others have their body cut off once the limit is read, with
+// reads failing with ErrBodyTooLarge. Url encoded and multipart forms are only
+// parsed once the ValueBag is first read or by the Router within all Middleware
+// of a route, so form bodies are limited as well.
+func BodyLimitMW(limit int64) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) error {
+			req := ctx.Request()
+			if req.ContentLength > limit {
+				return HTTPError{Code: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+			}
+
+			if req.Body == nil || req.Body == http.NoBody {
+				return next(ctx)
+			}
+
+			body := &limitedBody{ReadCloser: req.Body, remaining: limit}
+			req.Body = body
+
+			err := next(ctx)
+			if body.exceeded && !ctx.Response().Committed {
+				return HTTPError{Code: http.StatusRequestEntityTooLarge, Err: ErrBodyTooLarge}
+			}
+
+			return err
+		}
+	}
+}
+
+// limitedBody implements the io.ReadCloser interface, failing reads past the
+// remaining bytes.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+// Read implements the io.Reader interface.
+func (l *limitedBody) Read(b []byte) (int, error) {
+	if l.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+
+	// Read one byte past the limit to tell a body of exactly the limit apart
+	// from a larger one.
+	if int64(len(b)) > l.remaining+1 {
+		b = b[:l.remaining+1]
+	}
+
+	n, err := l.ReadCloser.Read(b)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, ErrBodyTooLarge
+	}
+
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,219 @@
+package httputil_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/metrics/metricstest"
+	"github.com/influx6/faux/tests"
+)
+
+func TestRecoverMW(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	router := httputil.NewRouter(httputil.SetMetrics(recorder.Metrics()))
+	router.Use(httputil.RecoverMW)
+
+	router.Get("/panic", func(ctx *httputil.Context) error {
+		panic("broken handler")
+	})
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("GET", "/panic", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		tests.Failed("Should have answered panic with 500 but got %d", res.Code)
+	}
+
+	recorder.Assert(t).Emitted(metricstest.Match().ID(httputil.PanicTag))
+	tests.Passed("Should have recovered panic and answered with 500")
+}
+
+func TestTimeoutMW(t *testing.T) {
+	late := make(chan struct{})
+
+	router := httputil.NewRouter()
+	router.Use(httputil.RecoverMW, httputil.TimeoutMW(50*time.Millisecond))
+
+	router.Get("/fast", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, ctx.Bag().Get(httputil.TimeoutKey).(time.Duration).String())
+	})
+
+	router.Get("/slow", func(ctx *httputil.Context) error {
+		<-ctx.Context().Done()
+		defer close(late)
+
+		return ctx.String(http.StatusOK, "too late")
+	})
+
+	router.Get("/panic", func(ctx *httputil.Context) error {
+		panic("broken handler")
+	})
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("GET", "/fast", nil))
+
+	if res.Code != http.StatusOK || res.Body.String() != "50ms" {
+		tests.Failed("Should have written response within deadline: %d %q", res.Code, res.Body.String())
+	}
+	tests.Passed("Should have written response within deadline")
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("GET", "/slow", nil))
+
+	if res.Code != http.StatusServiceUnavailable || strings.Contains(res.Body.String(), "too late") {
+		tests.Failed("Should have answered slow handler with 503: %d %q", res.Code, res.Body.String())
+	}
+
+	select {
+	case <-late:
+	case <-time.After(time.Second):
+		tests.Failed("Should have let slow handler finish")
+	}
+	tests.Passed("Should have answered slow handler with 503")
+
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("GET", "/panic", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		tests.Failed("Should have carried panic into RecoverMW but got %d", res.Code)
+	}
+	tests.Passed("Should have carried panic into RecoverMW")
+}
+
+func TestTimeoutMWContextState(t *testing.T) {
+	late := make(chan struct{})
+	seen := make(chan string, 2)
+
+	router := httputil.NewRouter()
+	router.Use(func(next httputil.Handler) httputil.Handler {
+		return func(ctx *httputil.Context) error {
+			err := next(ctx)
+			seen <- ctx.Bag().GetString("user") + ":" + strings.Join(ctx.Flash("notice"), ",")
+			return err
+		}
+	}, httputil.TimeoutMW(50*time.Millisecond))
+
+	router.Get("/fast", func(ctx *httputil.Context) error {
+		ctx.Bag().Set("user", "bob")
+		ctx.SetFlash("notice", "saved")
+		return ctx.NoContent(http.StatusNoContent)
+	})
+
+	router.Get("/slow", func(ctx *httputil.Context) error {
+		<-ctx.Context().Done()
+
+		ctx.Bag().Set("user", "late")
+		ctx.SetFlash("notice", "late")
+		close(late)
+
+		return nil
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	if state := <-seen; state != "bob:saved" {
+		tests.Failed("Should have carried state of completed handler back but got %q", state)
+	}
+	tests.Passed("Should have carried state of completed handler back")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-late
+
+	if state := <-seen; state != ":" {
+		tests.Failed("Should have discarded state of timed out handler but got %q", state)
+	}
+	tests.Passed("Should have discarded state of timed out handler")
+}
+
+func TestBodyLimitMW(t *testing.T) {
+	router := httputil.NewRouter()
+	router.Use(httputil.BodyLimitMW(16))
+
+	router.Post("/form", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, ctx.Bag().GetString("name"))
+	})
+
+	router.Post("/raw", func(ctx *httputil.Context) error {
+		body, err := ioutil.ReadAll(ctx.Request().Body)
+		if err != nil {
+			return err
+		}
+
+		return ctx.String(http.StatusOK, string(body))
+	})
+
+	send := func(path string, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if chunked {
+			req.ContentLength = -1
+		}
+
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := send("/form", "name=bob", true); res.Code != http.StatusOK || res.Body.String() != "bob" {
+		tests.Failed("Should have parsed form within limit: %d %q", res.Code, res.Body.String())
+	}
+	tests.Passed("Should have parsed form within limit")
+
+	large := "name=" + strings.Repeat("a", 64)
+
+	if res := send("/form", large, false); res.Code != http.StatusRequestEntityTooLarge {
+		tests.Failed("Should have refused form with large Content-Length with 413 but got %d", res.Code)
+	}
+	tests.Passed("Should have refused form with large Content-Length with 413")
+
+	if res := send("/form", large, true); res.Code != http.StatusRequestEntityTooLarge {
+		tests.Failed("Should have refused large chunked form with 413 but got %d", res.Code)
+	}
+	tests.Passed("Should have refused large chunked form with 413")
+
+	if res := send("/raw", large, true); res.Code != http.StatusRequestEntityTooLarge {
+		tests.Failed("Should have refused large chunked body with 413 but got %d", res.Code)
+	}
+	tests.Passed("Should have refused large chunked body with 413")
+}
+
+func TestServeHandlerForms(t *testing.T) {
+	handler := httputil.ServeHandler(func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, ctx.Bag().GetString("name")+":"+ctx.Bag().GetString("role"))
+	})
+
+	req := httptest.NewRequest("POST", "/?name=bob", strings.NewReader("role=admin"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || res.Body.String() != "bob:admin" {
+		tests.Failed("Should have read query and form values from bag: %d %q", res.Code, res.Body.String())
+	}
+	tests.Passed("Should have read query and form values from bag")
+
+	limited := httputil.ServeHandler(httputil.BodyLimitMW(16)(func(ctx *httputil.Context) error {
+		if err := ctx.InitForms(); err != nil {
+			return err
+		}
+
+		return ctx.String(http.StatusOK, ctx.Bag().GetString("name"))
+	}))
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("name="+strings.Repeat("a", 64)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+
+	res = httptest.NewRecorder()
+	limited.ServeHTTP(res, req)
+
+	if res.Code != http.StatusRequestEntityTooLarge {
+		tests.Failed("Should have limited form body of ServeHandler with 413 but got %d", res.Code)
+	}
+	tests.Passed("Should have limited form body of ServeHandler with 413")
+}
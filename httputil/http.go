@@ -3,7 +3,6 @@ package httputil
 import (
 	"encoding/base64"
 	"errors"
-	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
@@ -33,16 +32,6 @@ func (h HTTPError) Error() string {
 	return h.Err.Error()
 }
 
-// Gzip Compression
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
-}
-
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
-}
-
 // handlerImpl implements http.Handler interface.
 type handlerImpl struct {
 	Handler
@@ -51,21 +40,25 @@ type handlerImpl struct {
 // ServeHTTP implements http.Handler.ServeHttp method.
 func (h handlerImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := NewContext(SetRequest(r), SetResponseWriter(w))
-	if err := ctx.InitForms(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
 	defer ctx.ClearFlashMessages()
 
 	if err := h.Handler(ctx); err != nil {
-		if httperr, ok := err.(HTTPError); ok {
-			http.Error(w, httperr.Error(), httperr.Code)
-			return
-		}
+		WriteError(ctx, err)
+	}
+}
 
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// WriteError writes the giving error returned from a Handler into the response,
+// rendering FieldErrors as a 422, HTTPError with its code and others as a 400.
+func WriteError(ctx *Context, err error) {
+	switch herr := err.(type) {
+	case FieldErrors:
+		if rerr := herr.Render(ctx); rerr != nil {
+			http.Error(ctx.Response(), herr.Error(), http.StatusUnprocessableEntity)
+		}
+	case HTTPError:
+		http.Error(ctx.Response(), herr.Error(), herr.Code)
+	default:
+		http.Error(ctx.Response(), err.Error(), http.StatusBadRequest)
 	}
 }
 
@@ -0,0 +1,166 @@
+package httptesting
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+)
+
+// EventReader reads server-sent events from an event stream.
+type EventReader struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+}
+
+// NewEventReader returns a new instance of a EventReader reading from the
+// giving event stream.
+func NewEventReader(r io.ReadCloser) *EventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	scanner.Split(scanLines)
+
+	return &EventReader{body: r, scanner: scanner}
+}
+
+// Subscribe connects to the event stream at the giving url, sending the giving
+// last event id to resume from if not empty. The stream is closed when the
+// giving context is done or the EventReader is closed.
+func Subscribe(ctx context.Context, url string, lastEventID string) (*EventReader, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(httputil.HeaderAccept, httputil.MIMETextEventStream)
+	if lastEventID != "" {
+		req.Header.Set(httputil.HeaderLastEventID, lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Event stream responded with status %d", res.StatusCode)
+	}
+
+	if !strings.HasPrefix(res.Header.Get(httputil.HeaderContentType), httputil.MIMETextEventStream) {
+		res.Body.Close()
+		return nil, fmt.Errorf("Event stream responded with content type %q", res.Header.Get(httputil.HeaderContentType))
+	}
+
+	return NewEventReader(res.Body), nil
+}
+
+// LastEventID returns the id of the last event read.
+func (e *EventReader) LastEventID() string {
+	return e.lastID
+}
+
+// Retry returns the reconnection time last sent by the server.
+func (e *EventReader) Retry() time.Duration {
+	return e.retry
+}
+
+// Close closes the event stream.
+func (e *EventReader) Close() error {
+	return e.body.Close()
+}
+
+// Next returns the next event of the stream, skipping comments. It returns
+// io.EOF once the stream ends.
+func (e *EventReader) Next() (httputil.Event, error) {
+	var event httputil.Event
+	var data []string
+	var hasData bool
+
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+
+		if line == "" {
+			if !hasData {
+				event = httputil.Event{}
+				continue
+			}
+
+			event.ID = e.lastID
+			event.Data = strings.Join(data, "\n")
+			return event, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if at := strings.Index(line, ":"); at != -1 {
+			field, value = line[:at], strings.TrimPrefix(line[at+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				e.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.retry = time.Duration(ms) * time.Millisecond
+				event.Retry = e.retry
+			}
+		}
+	}
+
+	if err := e.scanner.Err(); err != nil {
+		return httputil.Event{}, err
+	}
+
+	return httputil.Event{}, io.EOF
+}
+
+// scanLines implements bufio.SplitFunc, splitting lines ending with any of
+// \r\n, \r or \n as event streams allow.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if at := bytes.IndexAny(data, "\r\n"); at != -1 {
+		if data[at] == '\n' {
+			return at + 1, data[:at], nil
+		}
+
+		// A \r at the end of the buffer may yet be followed by \n.
+		if at+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+
+		if at+1 < len(data) && data[at+1] == '\n' {
+			return at + 2, data[:at], nil
+		}
+
+		return at + 1, data[:at], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
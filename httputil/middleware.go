@@ -134,7 +134,7 @@ func GorillaMuxHandler(next Handler) Handler {
 // into the context.
 func GorillaMuxVars(ctx *Context) error {
 	for k, v := range mux.Vars(ctx.Request()) {
-		ctx.Bag().Set(k, v)
+		ctx.SetParam(k, v)
 	}
 	return nil
 }
@@ -324,7 +324,9 @@ func (ec FnErrorCondition) Match(err error) bool {
 }
 
 // LogMW defines a log middleware function which wraps a Handler
-// and logs what request and response was sent incoming.
+// and logs what request and response was sent incoming. The request is
+// served within a metrics.Span which joins any trace received through the
+// traceparent header.
 func LogMW(next Handler) Handler {
 	return func(ctx *Context) error {
 		m := ctx.Metrics()
@@ -338,10 +340,23 @@ func LogMW(next Handler) Handler {
 		var err error
 		req := ctx.Request()
 		res := ctx.Response()
+
+		spanCtx, span := metrics.StartSpan(metrics.ExtractTraceParent(req.Context(), req.Header), m, "http:request")
+		span.SetAttributes(metrics.Field{
+			"method": req.Method,
+			"path":   req.URL.Path,
+			"host":   req.Host,
+		})
+
+		req = req.WithContext(spanCtx)
+		ctx.request = req
+		traceID := span.Context().TraceID
+
 		res.After(func() {
 			if err != nil {
 				m.Emit(metrics.Error(err),
 					metrics.WithID(ctx.id),
+					metrics.With("trace_id", traceID),
 					metrics.Message("Outgoing HTTP Response"),
 					metrics.With("method", req.Method),
 					metrics.With("status", res.Status),
@@ -359,6 +374,7 @@ func LogMW(next Handler) Handler {
 
 			m.Emit(metrics.Info("Outgoing HTTP Response"),
 				metrics.WithID(ctx.id),
+				metrics.With("trace_id", traceID),
 				metrics.With("method", req.Method),
 				metrics.With("status", res.Status),
 				metrics.With("header", res.Header()),
@@ -374,6 +390,7 @@ func LogMW(next Handler) Handler {
 
 		m.Emit(metrics.Info("Incoming HTTP Request"),
 			metrics.WithID(ctx.id),
+			metrics.With("trace_id", traceID),
 			metrics.With("method", req.Method),
 			metrics.With("path", req.URL.Path),
 			metrics.With("tls", req.TLS != nil),
@@ -386,6 +403,13 @@ func LogMW(next Handler) Handler {
 			metrics.With("proto", req.Proto))
 
 		err = next(ctx)
+
+		span.SetAttribute("status", res.Status)
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+
 		return err
 	}
 }
@@ -462,7 +486,7 @@ func HTTPTreemux(errHandler ErrorHandler, ops ...Options) TreemuxHandlerMW {
 			defer ctx.ClearFlashMessages()
 
 			for key, val := range params {
-				ctx.Bag().Set(key, val)
+				ctx.SetParam(key, val)
 			}
 
 			if err := middleware(ctx); err != nil && errHandler != nil {
@@ -470,6 +494,11 @@ func HTTPTreemux(errHandler ErrorHandler, ops ...Options) TreemuxHandlerMW {
 				return
 			}
 
+			if err := ctx.InitForms(); err != nil && errHandler != nil {
+				errHandler(HTTPError{Code: http.StatusBadRequest, Err: err}, ctx)
+				return
+			}
+
 			if err := handle(ctx); err != nil && errHandler != nil {
 				errHandler(err, ctx)
 				return
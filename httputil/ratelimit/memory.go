@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key     string
+	state   State
+	expires time.Time
+}
+
+// MemoryStore implements the Store interface, keeping states in memory. Once
+// it holds its capacity of keys, the least recently used key is evicted.
+type MemoryStore struct {
+	ml       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// NewMemoryStore returns a new instance of a MemoryStore holding up to the
+// giving number of keys.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &MemoryStore{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Update implements the Store interface.
+func (m *MemoryStore) Update(key string, ttl time.Duration, fn func(State) State) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	now := time.Now()
+
+	if elem, ok := m.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+
+		var state State
+		if now.Before(entry.expires) {
+			state = entry.state
+		}
+
+		entry.state = fn(state)
+		entry.expires = now.Add(ttl)
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	for m.order.Len() >= m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{
+		key:     key,
+		state:   fn(State{}),
+		expires: now.Add(ttl),
+	})
+
+	return nil
+}
+
+// Len returns the total of keys held.
+func (m *MemoryStore) Len() int {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+	return m.order.Len()
+}
@@ -0,0 +1,453 @@
+// Package ratelimit provides a httputil middleware limiting the rate of requests
+// per client key, using token-bucket or sliding-window algorithms whose state is
+// kept within a pluggable Store.
+//
+//	limitMW, err := ratelimit.New(ratelimit.Config{
+//		Algorithm: ratelimit.TokenBucket(10, time.Second, 20),
+//		Key:       ratelimit.ByHeader("X-API-Key"),
+//	})
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+)
+
+// headers set by the middleware.
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// errors.
+var (
+	ErrNoAlgorithm  = errors.New("Rate limit algorithm is required")
+	ErrLimited      = errors.New("Too Many Requests")
+	ErrInvalidProxy = errors.New("Trusted proxy must be an ip or cidr range")
+	ErrInvalidRate  = errors.New("Rate limit rate and period must be positive")
+	ErrInvalidLimit = errors.New("Rate limit limit and window must be positive")
+)
+
+// State defines the state kept per key by an Algorithm.
+type State struct {
+	// Value holds the tokens left for a token bucket, or the requests of the
+	// current window for a sliding window.
+	Value float64 `json:"value"`
+
+	// Previous holds the requests of the previous window for a sliding window.
+	Previous float64 `json:"previous,omitempty"`
+
+	// Time holds the last refill for a token bucket, or the start of the current
+	// window for a sliding window.
+	Time time.Time `json:"time"`
+}
+
+// Result defines the outcome of a request against an Algorithm.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Algorithm defines an interface for rate limiting algorithms.
+type Algorithm interface {
+	// Take returns the new state of a key and the result of a request made at
+	// the giving time. A zero State is given for unseen keys.
+	Take(state State, now time.Time) (State, Result)
+
+	// TTL returns how long a state must be kept since its last update.
+	TTL() time.Duration
+}
+
+// Store defines an interface for the storage of states per key.
+type Store interface {
+	// Update calls the function with the state of the key, or a zero State if
+	// it has none or has expired, saving the state it returns for the ttl. The
+	// call must be atomic for the key.
+	Update(key string, ttl time.Duration, fn func(State) State) error
+}
+
+//=========================================================================================
+
+// Limiter applies an Algorithm to keys, keeping their states within a Store.
+type Limiter struct {
+	Algorithm Algorithm
+	Store     Store
+
+	// Now sets the function returning the current time, defaults to time.Now.
+	Now func() time.Time
+}
+
+// Allow returns the result of a request for the giving key.
+func (l *Limiter) Allow(key string) (Result, error) {
+	if err := validate(l.Algorithm); err != nil {
+		return Result{}, err
+	}
+
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+
+	var result Result
+	err := l.Store.Update(key, l.Algorithm.TTL(), func(state State) State {
+		state, result = l.Algorithm.Take(state, now())
+		return state
+	})
+
+	return result, err
+}
+
+// validate returns the error of an Algorithm created with invalid values.
+func validate(algo Algorithm) error {
+	if checked, ok := algo.(interface {
+		valid() error
+	}); ok {
+		return checked.valid()
+	}
+	return nil
+}
+
+//=========================================================================================
+
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	err   error
+}
+
+// TokenBucket returns an Algorithm which allows bursts of up to burst requests,
+// refilled at rate requests per the giving period. New returns ErrInvalidRate
+// for it if rate or per are not positive.
+func TokenBucket(rate int, per time.Duration, burst int) Algorithm {
+	if burst < 1 {
+		burst = 1
+	}
+
+	if rate < 1 || per <= 0 {
+		return tokenBucket{burst: float64(burst), err: ErrInvalidRate}
+	}
+
+	return tokenBucket{
+		rate:  float64(rate) / per.Seconds(),
+		burst: float64(burst),
+	}
+}
+
+// valid returns the error of invalid values given to TokenBucket.
+func (t tokenBucket) valid() error {
+	return t.err
+}
+
+// Take implements the Algorithm interface.
+func (t tokenBucket) Take(state State, now time.Time) (State, Result) {
+	tokens := t.burst
+	if !state.Time.IsZero() {
+		tokens = math.Min(t.burst, state.Value+now.Sub(state.Time).Seconds()*t.rate)
+	}
+
+	result := Result{Limit: int(t.burst)}
+
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = t.duration(1 - tokens)
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = t.duration(t.burst - tokens)
+
+	return State{Value: tokens, Time: now}, result
+}
+
+// TTL implements the Algorithm interface.
+func (t tokenBucket) TTL() time.Duration {
+	return t.duration(t.burst)
+}
+
+// duration returns the time needed to refill the giving tokens.
+func (t tokenBucket) duration(tokens float64) time.Duration {
+	if t.rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / t.rate * float64(time.Second))
+}
+
+type slidingWindow struct {
+	limit  float64
+	window time.Duration
+	err    error
+}
+
+// SlidingWindow returns an Algorithm which allows limit requests per window,
+// estimating the requests of the sliding window from the counts of the
+// current and previous fixed windows. New returns ErrInvalidLimit for it if
+// limit or window are not positive.
+func SlidingWindow(limit int, window time.Duration) Algorithm {
+	if limit < 1 || window <= 0 {
+		return slidingWindow{limit: 1, window: time.Second, err: ErrInvalidLimit}
+	}
+
+	return slidingWindow{
+		limit:  float64(limit),
+		window: window,
+	}
+}
+
+// valid returns the error of invalid values given to SlidingWindow.
+func (s slidingWindow) valid() error {
+	return s.err
+}
+
+// Take implements the Algorithm interface.
+func (s slidingWindow) Take(state State, now time.Time) (State, Result) {
+	start := now.Truncate(s.window)
+
+	if !state.Time.Equal(start) {
+		if state.Time.Equal(start.Add(-s.window)) {
+			state.Previous = state.Value
+		} else {
+			state.Previous = 0
+		}
+
+		state.Value = 0
+		state.Time = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(s.window)
+	estimate := state.Previous*weight + state.Value
+
+	result := Result{
+		Limit: int(s.limit),
+		Reset: s.window - elapsed,
+	}
+
+	if estimate+1 <= s.limit {
+		state.Value++
+		estimate++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = s.retryAfter(state, elapsed)
+	}
+
+	result.Remaining = int(math.Max(0, math.Floor(s.limit-estimate)))
+	return state, result
+}
+
+// retryAfter returns the time till the estimate leaves room for a request, the
+// previous window losing weight as the current one elapses.
+func (s slidingWindow) retryAfter(state State, elapsed time.Duration) time.Duration {
+	room := s.limit - state.Value - 1
+	if state.Previous > 0 && room >= 0 {
+		needed := 1 - room/state.Previous
+		return time.Duration(needed*float64(s.window)) - elapsed
+	}
+	return s.window - elapsed
+}
+
+// TTL implements the Algorithm interface.
+func (s slidingWindow) TTL() time.Duration {
+	return 2 * s.window
+}
+
+//=========================================================================================
+
+// KeyFunc defines a function returning the key a request is limited by, where
+// requests with an empty key are not limited.
+type KeyFunc func(*httputil.Context) string
+
+// ByIP returns the key of the request from the ip of its remote address. The
+// X-Forwarded-For and X-Real-IP headers are ignored, as clients connecting
+// directly can set them to anything, see ByProxiedIP for servers behind proxies.
+func ByIP(ctx *httputil.Context) string {
+	return "ip:" + remoteIP(ctx.Request())
+}
+
+// ByProxiedIP returns a KeyFunc which keys requests by the ip of the client
+// forwarded by the giving trusted proxies, as ips or cidr ranges. The
+// X-Forwarded-For header is only read for requests from a trusted proxy, taking
+// the last address not of a trusted proxy, as those before it are set by the
+// client. It panics with ErrInvalidProxy if a proxy fails to parse.
+func ByProxiedIP(proxies ...string) KeyFunc {
+	var trusted []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				panic(ErrInvalidProxy)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			panic(ErrInvalidProxy)
+		}
+
+		trusted = append(trusted, network)
+	}
+
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(ctx *httputil.Context) string {
+		req := ctx.Request()
+
+		client := remoteIP(req)
+		if !isTrusted(client) {
+			return "ip:" + client
+		}
+
+		forwarded := strings.Split(req.Header.Get(httputil.HeaderXForwardedFor), ",")
+		for index := len(forwarded) - 1; index >= 0; index-- {
+			addr := strings.TrimSpace(forwarded[index])
+			if net.ParseIP(addr) == nil {
+				break
+			}
+
+			client = addr
+			if !isTrusted(addr) {
+				break
+			}
+		}
+
+		return "ip:" + client
+	}
+}
+
+// remoteIP returns the ip of the remote address of the request.
+func remoteIP(req *http.Request) string {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
+// ByHeader returns a KeyFunc which keys requests by the value of the giving
+// header, requests without it are keyed by ip through ByIP.
+func ByHeader(name string) KeyFunc {
+	return func(ctx *httputil.Context) string {
+		if value := ctx.GetHeader(name); value != "" {
+			return "header:" + value
+		}
+		return ByIP(ctx)
+	}
+}
+
+// ByIdentity returns a KeyFunc which keys requests by the authenticated identity
+// stored within the Context under the giving key, requests without one are
+// keyed by ip through ByIP.
+func ByIdentity(key string) KeyFunc {
+	return func(ctx *httputil.Context) string {
+		if identity, ok := ctx.Bag().Get(key).(string); ok && identity != "" {
+			return "identity:" + identity
+		}
+		return ByIP(ctx)
+	}
+}
+
+// Config defines the configuration used by the rate limit middleware.
+type Config struct {
+	// Algorithm sets the algorithm requests are limited by, it is required.
+	Algorithm Algorithm
+
+	// Store sets the storage of states, defaults to a MemoryStore of 10000 keys.
+	Store Store
+
+	// Key sets the function returning the key of requests, defaults to ByIP.
+	Key KeyFunc
+
+	// Exceeded sets the Handler called for limited requests after the headers
+	// are set, defaults to returning a HTTPError with a 429.
+	Exceeded httputil.Handler
+}
+
+// New returns a new rate limit middleware for the giving Config. Every response
+// carries the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers,
+// while limited requests also carry Retry-After. Errors of the Store are
+// returned as a HTTPError with a 500.
+func New(config Config) (httputil.Middleware, error) {
+	if config.Algorithm == nil {
+		return nil, ErrNoAlgorithm
+	}
+
+	if err := validate(config.Algorithm); err != nil {
+		return nil, err
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryStore(10000)
+	}
+
+	if config.Key == nil {
+		config.Key = ByIP
+	}
+
+	limiter := &Limiter{Algorithm: config.Algorithm, Store: config.Store}
+
+	return func(next httputil.Handler) httputil.Handler {
+		return func(ctx *httputil.Context) error {
+			key := config.Key(ctx)
+			if key == "" {
+				return next(ctx)
+			}
+
+			result, err := limiter.Allow(key)
+			if err != nil {
+				return httputil.HTTPError{Code: http.StatusInternalServerError, Err: err}
+			}
+
+			ctx.SetHeader(HeaderLimit, strconv.Itoa(result.Limit))
+			ctx.SetHeader(HeaderRemaining, strconv.Itoa(result.Remaining))
+			ctx.SetHeader(HeaderReset, seconds(result.Reset))
+
+			if result.Allowed {
+				return next(ctx)
+			}
+
+			ctx.SetHeader(HeaderRetryAfter, seconds(result.RetryAfter))
+
+			if config.Exceeded != nil {
+				return config.Exceeded(ctx)
+			}
+
+			return httputil.HTTPError{Code: http.StatusTooManyRequests, Err: ErrLimited}
+		}
+	}, nil
+}
+
+// seconds returns the duration in whole seconds, rounded up.
+func seconds(dur time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(dur.Seconds())))
+}
@@ -0,0 +1,303 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/httputil/ratelimit"
+	"github.com/influx6/faux/tests"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestTokenBucket(t *testing.T) {
+	tc := &clock{now: time.Unix(1000, 0)}
+	limiter := &ratelimit.Limiter{
+		Algorithm: ratelimit.TokenBucket(1, time.Second, 3),
+		Store:     ratelimit.NewMemoryStore(10),
+		Now:       tc.Now,
+	}
+
+	for index := 0; index < 3; index++ {
+		result, err := limiter.Allow("bob")
+		if err != nil || !result.Allowed {
+			tests.Failed("Should have allowed request %d of the burst: %+q", index, err)
+		}
+
+		if result.Remaining != 2-index {
+			tests.Failed("Should have %d remaining but got %d", 2-index, result.Remaining)
+		}
+	}
+	tests.Passed("Should have allowed the burst")
+
+	result, _ := limiter.Allow("bob")
+	if result.Allowed {
+		tests.Failed("Should have refused request past the burst")
+	}
+
+	if result.RetryAfter != time.Second {
+		tests.Failed("Should have retry after of 1s but got %s", result.RetryAfter)
+	}
+
+	if result.Reset != 3*time.Second {
+		tests.Failed("Should have reset of 3s but got %s", result.Reset)
+	}
+	tests.Passed("Should have refused request past the burst")
+
+	if result, _ := limiter.Allow("alice"); !result.Allowed {
+		tests.Failed("Should have limited keys separately")
+	}
+	tests.Passed("Should have limited keys separately")
+
+	tc.now = tc.now.Add(time.Second)
+	if result, _ := limiter.Allow("bob"); !result.Allowed || result.Remaining != 0 {
+		tests.Failed("Should have refilled a single token after a second")
+	}
+
+	if result, _ := limiter.Allow("bob"); result.Allowed {
+		tests.Failed("Should have refused request after using the refilled token")
+	}
+	tests.Passed("Should have refilled tokens over time")
+}
+
+func TestSlidingWindow(t *testing.T) {
+	tc := &clock{now: time.Unix(600, 0)}
+	limiter := &ratelimit.Limiter{
+		Algorithm: ratelimit.SlidingWindow(4, time.Minute),
+		Store:     ratelimit.NewMemoryStore(10),
+		Now:       tc.Now,
+	}
+
+	for index := 0; index < 4; index++ {
+		if result, _ := limiter.Allow("bob"); !result.Allowed {
+			tests.Failed("Should have allowed request %d of the window", index)
+		}
+	}
+
+	result, _ := limiter.Allow("bob")
+	if result.Allowed || result.Remaining != 0 {
+		tests.Failed("Should have refused request past the limit")
+	}
+
+	if result.RetryAfter != time.Minute {
+		tests.Failed("Should have retry after of 1m but got %s", result.RetryAfter)
+	}
+	tests.Passed("Should have refused request past the limit")
+
+	// A quarter into the next window the previous count weighs 3.
+	tc.now = tc.now.Add(time.Minute + 15*time.Second)
+
+	if result, _ := limiter.Allow("bob"); !result.Allowed || result.Remaining != 0 {
+		tests.Failed("Should have allowed a single request from the weighted estimate")
+	}
+
+	result, _ = limiter.Allow("bob")
+	if result.Allowed {
+		tests.Failed("Should have refused request past the weighted estimate")
+	}
+
+	if result.RetryAfter != 15*time.Second {
+		tests.Failed("Should have retry after of 15s but got %s", result.RetryAfter)
+	}
+	tests.Passed("Should have weighted the previous window")
+
+	tc.now = tc.now.Add(2 * time.Minute)
+	if result, _ := limiter.Allow("bob"); !result.Allowed || result.Remaining != 3 {
+		tests.Failed("Should have forgotten windows older than the previous")
+	}
+	tests.Passed("Should have forgotten windows older than the previous")
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := ratelimit.NewMemoryStore(2)
+	increment := func(state ratelimit.State) ratelimit.State {
+		state.Value++
+		return state
+	}
+
+	store.Update("a", time.Minute, increment)
+	store.Update("b", time.Minute, increment)
+	store.Update("a", time.Minute, increment)
+	store.Update("c", time.Minute, increment)
+
+	if store.Len() != 2 {
+		tests.Failed("Should have held 2 keys but got %d", store.Len())
+	}
+
+	var value float64
+	store.Update("a", time.Minute, func(state ratelimit.State) ratelimit.State {
+		value = state.Value
+		return state
+	})
+
+	if value != 2 {
+		tests.Failed("Should have kept recently used key but got %f", value)
+	}
+
+	store.Update("b", time.Minute, func(state ratelimit.State) ratelimit.State {
+		value = state.Value
+		return state
+	})
+
+	if value != 0 {
+		tests.Failed("Should have evicted least recently used key")
+	}
+	tests.Passed("Should have evicted least recently used key")
+
+	store.Update("d", -time.Second, increment)
+	store.Update("d", time.Minute, func(state ratelimit.State) ratelimit.State {
+		value = state.Value
+		return state
+	})
+
+	if value != 0 {
+		tests.Failed("Should have given zero state for expired key")
+	}
+	tests.Passed("Should have given zero state for expired key")
+}
+
+func TestMiddleware(t *testing.T) {
+	if _, err := ratelimit.New(ratelimit.Config{}); err != ratelimit.ErrNoAlgorithm {
+		tests.Failed("Should have required an algorithm")
+	}
+	tests.Passed("Should have required an algorithm")
+
+	invalid := []struct {
+		algo ratelimit.Algorithm
+		err  error
+	}{
+		{ratelimit.TokenBucket(1, 0, 2), ratelimit.ErrInvalidRate},
+		{ratelimit.TokenBucket(1, -time.Second, 2), ratelimit.ErrInvalidRate},
+		{ratelimit.TokenBucket(0, time.Second, 2), ratelimit.ErrInvalidRate},
+		{ratelimit.SlidingWindow(4, 0), ratelimit.ErrInvalidLimit},
+		{ratelimit.SlidingWindow(0, time.Minute), ratelimit.ErrInvalidLimit},
+	}
+
+	for _, item := range invalid {
+		if _, err := ratelimit.New(ratelimit.Config{Algorithm: item.algo}); err != item.err {
+			tests.Failed("Should have refused invalid algorithm %+v with %+q but got %+q", item.algo, item.err, err)
+		}
+	}
+	tests.Passed("Should have refused algorithms with invalid values")
+
+	limitMW, err := ratelimit.New(ratelimit.Config{
+		Algorithm: ratelimit.TokenBucket(1, time.Minute, 2),
+		Key:       ratelimit.ByHeader("X-API-Key"),
+	})
+	if err != nil {
+		tests.Failed("Should have created rate limit middleware: %+q", err)
+	}
+
+	handler := httputil.ServeHandler(limitMW(func(ctx *httputil.Context) error {
+		return ctx.NoContent(http.StatusNoContent)
+	}))
+
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("bob")
+	if res.Code != http.StatusNoContent {
+		tests.Failed("Should have allowed request but got %d", res.Code)
+	}
+
+	if res.Header().Get("RateLimit-Limit") != "2" || res.Header().Get("RateLimit-Remaining") != "1" || res.Header().Get("RateLimit-Reset") != "60" {
+		tests.Failed("Should have set rate limit headers: %+q", res.Header())
+	}
+	tests.Passed("Should have set rate limit headers")
+
+	send("bob")
+
+	res = send("bob")
+	if res.Code != http.StatusTooManyRequests {
+		tests.Failed("Should have refused request with 429 but got %d", res.Code)
+	}
+
+	if res.Header().Get("Retry-After") != "60" || res.Header().Get("RateLimit-Remaining") != "0" {
+		tests.Failed("Should have set retry after header: %+q", res.Header())
+	}
+	tests.Passed("Should have refused request with 429 and Retry-After")
+
+	if res := send("alice"); res.Code != http.StatusNoContent {
+		tests.Failed("Should have limited keys separately but got %d", res.Code)
+	}
+	tests.Passed("Should have limited keys separately")
+}
+
+func TestKeyIgnoresSpoofedHeaders(t *testing.T) {
+	limitMW, err := ratelimit.New(ratelimit.Config{
+		Algorithm: ratelimit.TokenBucket(1, time.Minute, 1),
+	})
+	if err != nil {
+		tests.Failed("Should have created rate limit middleware: %+q", err)
+	}
+
+	handler := httputil.ServeHandler(limitMW(func(ctx *httputil.Context) error {
+		return ctx.NoContent(http.StatusNoContent)
+	}))
+
+	for index, spoofed := range []string{"", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.9:4000"
+		req.Header.Set("X-Forwarded-For", spoofed)
+		req.Header.Set("X-Real-IP", spoofed)
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		expected := http.StatusTooManyRequests
+		if index == 0 {
+			expected = http.StatusNoContent
+		}
+
+		if res.Code != expected {
+			tests.Failed("Should have answered request with spoofed %q with %d but got %d", spoofed, expected, res.Code)
+		}
+	}
+	tests.Passed("Should have kept limit of remote address with spoofed headers")
+}
+
+func TestByProxiedIP(t *testing.T) {
+	key := ratelimit.ByProxiedIP("10.0.0.1", "192.168.0.0/16")
+
+	send := func(remote string, forwarded string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwarded)
+
+		return key(httputil.NewContext(httputil.SetRequest(req)))
+	}
+
+	if got := send("203.0.113.9:4000", "198.51.100.7"); got != "ip:203.0.113.9" {
+		tests.Failed("Should have ignored forwarded header of untrusted remote but got %q", got)
+	}
+	tests.Passed("Should have ignored forwarded header of untrusted remote")
+
+	if got := send("10.0.0.1:4000", "1.1.1.1, 198.51.100.7, 192.168.4.4"); got != "ip:198.51.100.7" {
+		tests.Failed("Should have taken last address not of a trusted proxy but got %q", got)
+	}
+	tests.Passed("Should have taken last address not of a trusted proxy")
+
+	defer func() {
+		if recovered := recover(); recovered != ratelimit.ErrInvalidProxy {
+			tests.Failed("Should have panicked with invalid proxy but got %+v", recovered)
+		}
+		tests.Passed("Should have panicked with invalid proxy")
+	}()
+
+	ratelimit.ByProxiedIP("proxy.local")
+}
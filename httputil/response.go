@@ -9,7 +9,8 @@ import (
 
 // errors ...
 var (
-	ErrNoPush = errors.New("Push Not Supported")
+	ErrNoPush   = errors.New("Push Not Supported")
+	ErrNoHijack = errors.New("Hijack Not Supported")
 )
 
 // Response wraps an http.ResponseWriter and implements its interface to be used
@@ -96,7 +97,10 @@ func (r *Response) Flush() {
 // take over the connection.
 // See [http.Hijacker](https://golang.org/pkg/net/http/#Hijacker)
 func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return r.Writer.(http.Hijacker).Hijack()
+	if hijacker, ok := r.Writer.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, ErrNoHijack
 }
 
 // CloseNotify implements the http.CloseNotifier interface to allow detecting
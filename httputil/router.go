@@ -0,0 +1,331 @@
+package httputil
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/influx6/faux/pattern"
+)
+
+// WildcardKey defines the key used to store the remaining path matched by an
+// endless pattern like /files/* within the Context.
+const WildcardKey = "*"
+
+// route defines a Handler registered against a method and pattern.
+type route struct {
+	order   int
+	endless bool
+	matcher pattern.URIMatcher
+	handler Handler
+}
+
+// less returns true/false if the route must be tried before the other. Routes
+// are ordered by their pattern priority, endless patterns are tried last and
+// routes of equal standing keep their registration order.
+func (r *route) less(other *route) bool {
+	if r.endless != other.endless {
+		return other.endless
+	}
+
+	if r.matcher.Priority() != other.matcher.Priority() {
+		return r.matcher.Priority() < other.matcher.Priority()
+	}
+
+	return r.order < other.order
+}
+
+//=========================================================================================
+
+// Router implements the http.Handler interface, routing requests into Handlers
+// registered by method and pattern. Patterns follow the pattern package, where
+// parameters like /users/:id or /users/{id:[\d+]} are stored into the Context
+// by name.
+//
+// Requests matching a pattern only for other methods are answered with a 405
+// and an Allow header, OPTIONS requests are answered with the allowed methods
+// and HEAD requests are served by GET handlers if none are registered.
+type Router struct {
+	RouteGroup
+
+	// NotFound sets the Handler called when no pattern matches the request path,
+	// defaults to responding with a 404.
+	NotFound Handler
+
+	// MethodNotAllowed sets the Handler called when patterns match the request
+	// path but for other methods, defaults to responding with a 405. The Allow
+	// header is set before it is called.
+	MethodNotAllowed Handler
+
+	// ErrorHandler sets the function called with errors returned from Handlers,
+	// defaults to WriteError.
+	ErrorHandler ErrorHandler
+
+	ops    []Options
+	ml     sync.RWMutex
+	total  int
+	routes map[string][]*route
+}
+
+// NewRouter returns a new instance of a Router, where the giving Options are
+// applied to the Context of every request.
+func NewRouter(ops ...Options) *Router {
+	r := &Router{
+		ops:    ops,
+		routes: make(map[string][]*route),
+	}
+
+	r.RouteGroup.router = r
+	return r
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := NewContext(append([]Options{SetRequest(req), SetResponseWriter(w)}, r.ops...)...)
+	defer ctx.ClearFlashMessages()
+
+	if err := r.Serve(ctx); err != nil {
+		if r.ErrorHandler != nil {
+			r.ErrorHandler(err, ctx)
+			return
+		}
+
+		WriteError(ctx, err)
+	}
+}
+
+// Serve routes the giving Context into the Handler whoes pattern matches its
+// path, allowing the Router to be used as a Handler within other Handlers.
+func (r *Router) Serve(ctx *Context) error {
+	method := ctx.Request().Method
+	path := ctx.Path()
+
+	if rt, params, rem, ok := r.match(method, path); ok {
+		return rt.serve(ctx, params, rem)
+	}
+
+	if method == http.MethodHead {
+		if rt, params, rem, ok := r.match(http.MethodGet, path); ok {
+			return rt.serve(ctx, params, rem)
+		}
+	}
+
+	allowed := r.Allowed(path)
+	if len(allowed) == 0 {
+		if r.NotFound != nil {
+			return r.NotFound(ctx)
+		}
+		return NotFound(ctx)
+	}
+
+	ctx.SetHeader(HeaderAllow, strings.Join(allowed, ", "))
+
+	if method == http.MethodOptions {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+
+	if r.MethodNotAllowed != nil {
+		return r.MethodNotAllowed(ctx)
+	}
+
+	return ctx.NoContent(http.StatusMethodNotAllowed)
+}
+
+// Allowed returns the sorted list of methods with patterns matching the giving
+// path, including HEAD where GET is allowed and OPTIONS where any is.
+func (r *Router) Allowed(path string) []string {
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+
+	var allowed []string
+	for method, routes := range r.routes {
+		for _, rt := range routes {
+			if _, _, ok := rt.matcher.Validate(path); ok {
+				allowed = append(allowed, method)
+				break
+			}
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	if hasMethod(allowed, http.MethodGet) && !hasMethod(allowed, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+
+	if !hasMethod(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+
+	sort.Strings(allowed)
+	return allowed
+}
+
+// match returns the first route of the method matching the giving path.
+func (r *Router) match(method string, path string) (*route, pattern.Params, string, bool) {
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+
+	for _, rt := range r.routes[method] {
+		if params, rem, ok := rt.matcher.Validate(path); ok {
+			return rt, params, rem, true
+		}
+	}
+
+	return nil, nil, "", false
+}
+
+// add registers the giving Handler for the method and pattern.
+func (r *Router) add(method string, patt string, handler Handler) {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	method = strings.ToUpper(method)
+
+	r.total++
+	rt := &route{
+		order:   r.total,
+		handler: handler,
+		endless: pattern.IsEndless(patt),
+		matcher: pattern.New(patt),
+	}
+
+	routes := append(r.routes[method], rt)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].less(routes[j])
+	})
+
+	r.routes[method] = routes
+}
+
+// serve stores the matched parameters into the Context before calling the
+// route Handler.
+func (rt *route) serve(ctx *Context, params pattern.Params, rem string) error {
+	for key, val := range params {
+		ctx.SetParam(key, val)
+	}
+
+	if rt.endless {
+		ctx.SetParam(WildcardKey, rem)
+	}
+
+	return rt.handler(ctx)
+}
+
+// formsHandler returns a Handler which loads the form of the request before
+// calling the giving Handler. It sits within all Middleware of a route, so
+// those like BodyLimitMW apply before the body is read.
+func formsHandler(handler Handler) Handler {
+	return func(ctx *Context) error {
+		if err := ctx.InitForms(); err != nil {
+			return HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+
+		return handler(ctx)
+	}
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, item := range methods {
+		if item == method {
+			return true
+		}
+	}
+	return false
+}
+
+//=========================================================================================
+
+// RouteGroup defines a set of routes sharing a path prefix and Middleware, which
+// are registered into a Router.
+type RouteGroup struct {
+	router *Router
+	prefix string
+	mws    []Middleware
+}
+
+// Group returns a new RouteGroup whoes routes are registered under the giving prefix
+// and wrapped by the Middleware of this group followed by the provided ones.
+func (g *RouteGroup) Group(prefix string, mws ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		router: g.router,
+		prefix: joinPath(g.prefix, prefix),
+		mws:    append(append([]Middleware{}, g.mws...), mws...),
+	}
+}
+
+// Use adds the giving Middleware to the group, applying to routes registered
+// after the call.
+func (g *RouteGroup) Use(mws ...Middleware) {
+	g.mws = append(g.mws, mws...)
+}
+
+// Handle registers the Handler for the method and pattern, wrapped by the
+// Middleware of the group followed by the provided ones. The first Middleware
+// is the outermost.
+func (g *RouteGroup) Handle(method string, patt string, handler Handler, mws ...Middleware) {
+	handler = formsHandler(handler)
+
+	all := append(append([]Middleware{}, g.mws...), mws...)
+	for index := len(all) - 1; index >= 0; index-- {
+		handler = all[index](handler)
+	}
+
+	g.router.add(method, joinPath(g.prefix, patt), handler)
+}
+
+// Get registers the Handler for GET requests to the pattern.
+func (g *RouteGroup) Get(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodGet, patt, handler, mws...)
+}
+
+// Head registers the Handler for HEAD requests to the pattern.
+func (g *RouteGroup) Head(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodHead, patt, handler, mws...)
+}
+
+// Post registers the Handler for POST requests to the pattern.
+func (g *RouteGroup) Post(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodPost, patt, handler, mws...)
+}
+
+// Put registers the Handler for PUT requests to the pattern.
+func (g *RouteGroup) Put(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodPut, patt, handler, mws...)
+}
+
+// Patch registers the Handler for PATCH requests to the pattern.
+func (g *RouteGroup) Patch(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodPatch, patt, handler, mws...)
+}
+
+// Delete registers the Handler for DELETE requests to the pattern.
+func (g *RouteGroup) Delete(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodDelete, patt, handler, mws...)
+}
+
+// Options registers the Handler for OPTIONS requests to the pattern, replacing
+// the automatic response.
+func (g *RouteGroup) Options(patt string, handler Handler, mws ...Middleware) {
+	g.Handle(http.MethodOptions, patt, handler, mws...)
+}
+
+// joinPath joins the prefix and path with a single slash.
+func joinPath(prefix string, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if path == "" || path == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return prefix + path
+}
@@ -0,0 +1,116 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/tests"
+)
+
+func serve(router *httputil.Router, method string, path string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(method, path, nil))
+	return res
+}
+
+func TestRouter(t *testing.T) {
+	router := httputil.NewRouter()
+
+	router.Get("/", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "home")
+	})
+
+	router.Get("/users/:id", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "param:"+ctx.Bag().GetString("id"))
+	})
+
+	router.Get(`/users/{id:[\d+]}`, func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "digits:"+ctx.Bag().GetString("id"))
+	})
+
+	router.Get("/users/me", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, "me")
+	})
+
+	router.Post("/users/:id", func(ctx *httputil.Context) error {
+		return ctx.NoContent(http.StatusCreated)
+	})
+
+	router.Get("/files/*", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, ctx.Bag().GetString(httputil.WildcardKey))
+	})
+
+	for path, body := range map[string]string{
+		"/":             "home",
+		"/users/me":     "me",
+		"/users/12":     "digits:12",
+		"/users/bob":    "param:bob",
+		"/files/a/b.go": "/a/b.go",
+	} {
+		res := serve(router, "GET", path)
+		if res.Code != http.StatusOK || res.Body.String() != body {
+			tests.Failed("Should have routed %q to %q: %d %q", path, body, res.Code, res.Body.String())
+		}
+		tests.Passed("Should have routed %q to %q", path, body)
+	}
+
+	if res := serve(router, "GET", "/unknown"); res.Code != http.StatusNotFound {
+		tests.Failed("Should have responded with 404: %d", res.Code)
+	}
+	tests.Passed("Should have responded with 404")
+
+	res := serve(router, "DELETE", "/users/12")
+	if res.Code != http.StatusMethodNotAllowed || res.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		tests.Failed("Should have responded with 405 and Allow header: %d %q", res.Code, res.Header().Get("Allow"))
+	}
+	tests.Passed("Should have responded with 405 and Allow header")
+
+	res = serve(router, "OPTIONS", "/users/12")
+	if res.Code != http.StatusNoContent || res.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		tests.Failed("Should have answered OPTIONS with Allow header: %d %q", res.Code, res.Header().Get("Allow"))
+	}
+	tests.Passed("Should have answered OPTIONS with Allow header")
+
+	if res := serve(router, "HEAD", "/users/me"); res.Code != http.StatusOK {
+		tests.Failed("Should have served HEAD with GET handler: %d", res.Code)
+	}
+	tests.Passed("Should have served HEAD with GET handler")
+}
+
+func TestRouterGroups(t *testing.T) {
+	var calls []string
+	mark := func(name string) httputil.Middleware {
+		return func(next httputil.Handler) httputil.Handler {
+			return func(ctx *httputil.Context) error {
+				calls = append(calls, name)
+				return next(ctx)
+			}
+		}
+	}
+
+	router := httputil.NewRouter()
+	api := router.Group("/api", mark("api"))
+	v1 := api.Group("v1/", mark("v1"))
+
+	v1.Get("/status", func(ctx *httputil.Context) error {
+		calls = append(calls, "handler")
+		return ctx.String(http.StatusOK, "ok")
+	}, mark("route"))
+
+	v1.Get("/fail", func(ctx *httputil.Context) error {
+		return httputil.HTTPError{Code: http.StatusTeapot, Err: http.ErrNotSupported}
+	})
+
+	res := serve(router, "GET", "/api/v1/status")
+	if res.Code != http.StatusOK || len(calls) != 4 || calls[0] != "api" || calls[1] != "v1" || calls[2] != "route" || calls[3] != "handler" {
+		tests.Failed("Should have applied group middleware in order: %d %#v", res.Code, calls)
+	}
+	tests.Passed("Should have applied group middleware in order")
+
+	if res := serve(router, "GET", "/api/v1/fail"); res.Code != http.StatusTeapot {
+		tests.Failed("Should have responded with HTTPError code: %d", res.Code)
+	}
+	tests.Passed("Should have responded with HTTPError code")
+}
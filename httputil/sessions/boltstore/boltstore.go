@@ -0,0 +1,112 @@
+// Package boltstore provides a sessions.Store which keeps sessions within a
+// boltdb database.
+//
+// github.com/boltdb/bolt fails the checkptr checks enabled by the race detector,
+// hence the tests of the package are skipped when run with -race.
+package boltstore
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/influx6/faux/httputil/sessions"
+)
+
+// DefaultBucket defines the bucket used when none is provided.
+const DefaultBucket = "sessions"
+
+// Store implements the sessions.Store interface, keeping every session as a
+// record of its expiry time followed by its data.
+type Store struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// New returns a new instance of a Store which keeps sessions within the bucket
+// of the giving database, creating the bucket if needed.
+func New(db *bolt.DB, bucket string) (*Store, error) {
+	if bucket == "" {
+		bucket = DefaultBucket
+	}
+
+	store := &Store{db: db, bucket: []byte(bucket)}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(store.bucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Load implements the sessions.Store interface.
+func (s *Store) Load(id string) ([]byte, error) {
+	var data []byte
+
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket(s.bucket).Get([]byte(id))
+		if len(record) < 8 || expired(record) {
+			return sessions.ErrNotFound
+		}
+
+		// Bolt values are only valid within the transaction.
+		data = append([]byte(nil), record[8:]...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Save implements the sessions.Store interface.
+func (s *Store) Save(id string, data []byte, ttl time.Duration) error {
+	record := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(record, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(record[8:], data)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte(id), record)
+	})
+}
+
+// Delete implements the sessions.Store interface.
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete([]byte(id))
+	})
+}
+
+// Purge removes all expired sessions, it should be called periodically as
+// expired sessions are otherwise only skipped.
+func (s *Store) Purge() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.bucket)
+
+		// Keys are collected first as deleting through a cursor skips records.
+		var keys [][]byte
+		if err := bucket.ForEach(func(key []byte, record []byte) error {
+			if len(record) < 8 || expired(record) {
+				keys = append(keys, append([]byte(nil), key...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// expired returns true/false if the expiry time of the record has passed.
+func expired(record []byte) bool {
+	return time.Now().UnixNano() > int64(binary.BigEndian.Uint64(record))
+}
@@ -0,0 +1,90 @@
+//go:build !race
+// +build !race
+
+// The tests are left out under the race detector, as github.com/boltdb/bolt
+// fails its checkptr checks with "pointer straddles multiple allocations".
+
+package boltstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/influx6/faux/httputil/sessions"
+	"github.com/influx6/faux/httputil/sessions/boltstore"
+	"github.com/influx6/faux/tests"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltstore")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "sessions.db"), 0600, nil)
+	if err != nil {
+		tests.Failed("Should have opened bolt database: %+q", err)
+	}
+	defer db.Close()
+
+	store, err := boltstore.New(db, "")
+	if err != nil {
+		tests.Failed("Should have created store: %+q", err)
+	}
+	tests.Passed("Should have created store")
+
+	if _, err := store.Load("missing"); err != sessions.ErrNotFound {
+		tests.Failed("Should have failed to load missing session: %+q", err)
+	}
+	tests.Passed("Should have failed to load missing session")
+
+	if err := store.Save("alive", []byte(`{"user":"bob"}`), time.Hour); err != nil {
+		tests.Failed("Should have saved session: %+q", err)
+	}
+
+	data, err := store.Load("alive")
+	if err != nil || string(data) != `{"user":"bob"}` {
+		tests.Failed("Should have loaded saved session: %q %+q", data, err)
+	}
+	tests.Passed("Should have loaded saved session")
+
+	if err := store.Save("expired", []byte("old"), -time.Second); err != nil {
+		tests.Failed("Should have saved expired session: %+q", err)
+	}
+
+	if _, err := store.Load("expired"); err != sessions.ErrNotFound {
+		tests.Failed("Should have skipped expired session: %+q", err)
+	}
+	tests.Passed("Should have skipped expired session")
+
+	if err := store.Purge(); err != nil {
+		tests.Failed("Should have purged expired sessions: %+q", err)
+	}
+
+	var keys []string
+	db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltstore.DefaultBucket)).ForEach(func(key []byte, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+
+	if len(keys) != 1 || keys[0] != "alive" {
+		tests.Failed("Should have purged only expired sessions: %+q", keys)
+	}
+	tests.Passed("Should have purged only expired sessions")
+
+	if err := store.Delete("alive"); err != nil {
+		tests.Failed("Should have deleted session: %+q", err)
+	}
+
+	if _, err := store.Load("alive"); err != sessions.ErrNotFound {
+		tests.Failed("Should have failed to load deleted session: %+q", err)
+	}
+	tests.Passed("Should have deleted session")
+}
@@ -0,0 +1,98 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval defines how many saves happen between removals of expired
+// sessions from a MemoryStore.
+const sweepInterval = 128
+
+type memoryRecord struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore implements the Store interface, keeping sessions in memory.
+// Expired sessions are removed as they are loaded and periodically on save.
+type MemoryStore struct {
+	ml      sync.Mutex
+	saves   int
+	records map[string]memoryRecord
+}
+
+// NewMemoryStore returns a new instance of a MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]memoryRecord),
+	}
+}
+
+// Load implements the Store interface.
+func (m *MemoryStore) Load(id string) ([]byte, error) {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	record, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if time.Now().After(record.expires) {
+		delete(m.records, id)
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), record.data...), nil
+}
+
+// Save implements the Store interface.
+func (m *MemoryStore) Save(id string, data []byte, ttl time.Duration) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	m.records[id] = memoryRecord{
+		data:    append([]byte(nil), data...),
+		expires: time.Now().Add(ttl),
+	}
+
+	m.saves++
+	if m.saves%sweepInterval == 0 {
+		m.purge()
+	}
+
+	return nil
+}
+
+// Delete implements the Store interface.
+func (m *MemoryStore) Delete(id string) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	delete(m.records, id)
+	return nil
+}
+
+// Len returns the total of sessions held, including expired sessions not yet removed.
+func (m *MemoryStore) Len() int {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+	return len(m.records)
+}
+
+// Purge removes all expired sessions.
+func (m *MemoryStore) Purge() {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+	m.purge()
+}
+
+func (m *MemoryStore) purge() {
+	now := time.Now()
+	for id, record := range m.records {
+		if now.After(record.expires) {
+			delete(m.records, id)
+		}
+	}
+}
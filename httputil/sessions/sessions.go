@@ -0,0 +1,544 @@
+// Package sessions provides a session middleware for httputil, keeping session
+// values and flash messages across requests either within a signed and
+// encrypted cookie or within a server-side Store referenced by a signed cookie.
+//
+//	sessionMW, err := sessions.New(sessions.Config{
+//		SigningKey:    signingKey,
+//		EncryptionKey: encryptionKey,
+//		Store:         sessions.NewMemoryStore(),
+//	})
+//
+//	router.Use(sessionMW)
+//	router.Post("/login", func(ctx *httputil.Context) error {
+//		session := sessions.Get(ctx)
+//		session.Regenerate()
+//		session.Set("user", user.ID)
+//
+//		ctx.SetFlash("notice", "Welcome back")
+//		return ctx.Redirect(http.StatusSeeOther, "/")
+//	})
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/metrics"
+)
+
+// SessionKey defines the key used to store the Session within the Context.
+const SessionKey = "httputil.session"
+
+// SaveErrorID defines the id of entries emitted for sessions which failed to
+// save once the response was being written.
+const SaveErrorID = "httputil.session.save"
+
+// maxCookieSize defines the largest cookie browsers are expected to keep.
+const maxCookieSize = 4096
+
+// errors.
+var (
+	ErrNotFound          = errors.New("Session not found")
+	ErrNoSigningKey      = errors.New("Session signing key is required")
+	ErrInvalidCookie     = errors.New("Session cookie is invalid")
+	ErrCookieTooLarge    = errors.New("Session cookie exceeds 4096 bytes")
+	ErrInvalidEncryption = errors.New("Session encryption key must be 16, 24 or 32 bytes")
+)
+
+// Store defines an interface for the server-side storage of encoded sessions.
+type Store interface {
+	// Load returns the data saved for the session id, or ErrNotFound if the
+	// session does not exist or has expired.
+	Load(id string) ([]byte, error)
+
+	// Save saves the data for the session id, expiring it after the ttl.
+	Save(id string, data []byte, ttl time.Duration) error
+
+	// Delete removes the session id.
+	Delete(id string) error
+}
+
+//=========================================================================================
+
+// Session defines the values and flash messages kept for a client across requests.
+// Values are stored as JSON, hence numbers are returned as float64 once loaded.
+type Session struct {
+	ID       string                 `json:"id"`
+	Values   map[string]interface{} `json:"values,omitempty"`
+	Flashes  map[string][]string    `json:"flashes,omitempty"`
+	Created  time.Time              `json:"created"`
+	Accessed time.Time              `json:"accessed"`
+
+	isNew       bool
+	changed     bool
+	destroyed   bool
+	previousIDs []string
+}
+
+// newSession returns a new instance of a Session with a random id.
+func newSession() (*Session, error) {
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Session{
+		ID:       id,
+		Values:   make(map[string]interface{}),
+		Created:  now,
+		Accessed: now,
+		isNew:    true,
+	}, nil
+}
+
+// Get returns the value of the giving key.
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+// GetString returns the value of the giving key if it is a string.
+func (s *Session) GetString(key string) string {
+	value, _ := s.Values[key].(string)
+	return value
+}
+
+// Set sets the value of the giving key.
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.changed = true
+}
+
+// Delete removes the giving key.
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.changed = true
+}
+
+// Clear removes all values.
+func (s *Session) Clear() {
+	s.Values = make(map[string]interface{})
+	s.changed = true
+}
+
+// IsNew returns true/false if the session was created by the current request.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Regenerate gives the session a new id while keeping its values, removing the
+// old id from the Store. It should be called whenever the privileges of the
+// session change, like on login, to prevent session fixation.
+func (s *Session) Regenerate() error {
+	id, err := randomID()
+	if err != nil {
+		return err
+	}
+
+	if !s.isNew {
+		s.previousIDs = append(s.previousIDs, s.ID)
+	}
+
+	s.ID = id
+	s.changed = true
+	return nil
+}
+
+// Destroy removes the session from the Store and expires its cookie once the
+// request completes.
+func (s *Session) Destroy() {
+	s.destroyed = true
+}
+
+// Get returns the Session of the giving Context, or nil if the session
+// middleware was not applied.
+func Get(ctx *httputil.Context) *Session {
+	session, _ := ctx.Bag().Get(SessionKey).(*Session)
+	return session
+}
+
+//=========================================================================================
+
+// Config defines the configuration used by the session middleware.
+type Config struct {
+	// Name sets the name of the session cookie, defaults to "session".
+	Name string
+
+	// Path sets the path of the session cookie, defaults to "/".
+	Path string
+
+	// Domain sets the domain of the session cookie.
+	Domain string
+
+	// Secure sets the session cookie to be sent only over https.
+	Secure bool
+
+	// SigningKey sets the key used to sign the session cookie with HMAC-SHA256,
+	// it is required.
+	SigningKey []byte
+
+	// EncryptionKey sets the AES key of 16, 24 or 32 bytes used to encrypt the
+	// session cookie. It should always be set when Store is nil, as the session
+	// values are kept within the cookie.
+	EncryptionKey []byte
+
+	// Store sets the server-side storage of sessions, where the cookie only
+	// carries the session id. Sessions are kept within the cookie if nil.
+	Store Store
+
+	// IdleTimeout sets how long a session lives without requests, defaults
+	// to 30 minutes.
+	IdleTimeout time.Duration
+
+	// AbsoluteTimeout sets how long a session lives since its creation
+	// regardless of activity, defaults to 24 hours.
+	AbsoluteTimeout time.Duration
+}
+
+// New returns a new session middleware for the giving Config, which loads the
+// Session of every request into the Context and saves it before the response
+// is written.
+//
+// Flash messages found in the session are moved into the Context with SetFlash
+// and removed from the session, while messages set with SetFlash during the
+// request are saved into the session for the next request, allowing them to
+// survive redirects.
+func New(config Config) (httputil.Middleware, error) {
+	if len(config.SigningKey) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	var aead cipher.AEAD
+	if len(config.EncryptionKey) != 0 {
+		block, err := aes.NewCipher(config.EncryptionKey)
+		if err != nil {
+			return nil, ErrInvalidEncryption
+		}
+
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Name == "" {
+		config.Name = "session"
+	}
+
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Minute
+	}
+
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = 24 * time.Hour
+	}
+
+	m := &manager{config: config, aead: aead}
+
+	return func(next httputil.Handler) httputil.Handler {
+		return func(ctx *httputil.Context) error {
+			session, err := m.load(ctx)
+			if err != nil {
+				return err
+			}
+
+			ctx.Bag().Set(SessionKey, session)
+
+			loaded := session.Flashes
+			session.Flashes = nil
+
+			for name, messages := range loaded {
+				for _, message := range messages {
+					ctx.SetFlash(name, message)
+				}
+			}
+
+			var saved bool
+			save := func() error {
+				if saved {
+					return nil
+				}
+
+				saved = true
+				return m.save(ctx, session, loaded)
+			}
+
+			// Sessions are saved right before the headers are written, as the
+			// cookie must go out with them. Failures by then can no longer change
+			// the response, so they are emitted into the Context metrics.
+			ctx.Response().Before(func() {
+				if err := save(); err != nil {
+					reportSave(ctx, err)
+				}
+			})
+
+			if err := next(ctx); err != nil {
+				if saveErr := save(); saveErr != nil {
+					reportSave(ctx, saveErr)
+				}
+				return err
+			}
+
+			// Nothing was written by the handler, so the failure can still be
+			// answered.
+			if err := save(); err != nil {
+				return httputil.HTTPError{Code: http.StatusInternalServerError, Err: err}
+			}
+
+			return nil
+		}
+	}, nil
+}
+
+// reportSave emits the error of a failed save into the metrics of the Context.
+func reportSave(ctx *httputil.Context, err error) {
+	ctx.Metrics().Emit(metrics.Error(err), metrics.WithID(SaveErrorID), metrics.Message("Failed to save session"))
+}
+
+type manager struct {
+	config Config
+	aead   cipher.AEAD
+}
+
+// load returns the session of the request, or a new session if it has none or
+// it has expired.
+func (m *manager) load(ctx *httputil.Context) (*Session, error) {
+	cookie, err := ctx.Cookie(m.config.Name)
+	if err != nil {
+		return newSession()
+	}
+
+	data, err := m.decode(cookie.Value)
+	if err != nil {
+		return newSession()
+	}
+
+	if m.config.Store != nil {
+		id := string(data)
+		if data, err = m.config.Store.Load(id); err != nil {
+			if err == ErrNotFound {
+				return newSession()
+			}
+			return nil, err
+		}
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return newSession()
+	}
+
+	if m.expired(&session) {
+		if m.config.Store != nil {
+			m.config.Store.Delete(session.ID)
+		}
+		return newSession()
+	}
+
+	if session.Values == nil {
+		session.Values = make(map[string]interface{})
+	}
+
+	return &session, nil
+}
+
+// expired returns true/false if the session has passed its idle or absolute timeout.
+func (m *manager) expired(session *Session) bool {
+	now := time.Now()
+	return now.Sub(session.Accessed) > m.config.IdleTimeout || now.Sub(session.Created) > m.config.AbsoluteTimeout
+}
+
+// save saves the session and sets its cookie, keeping flash messages set during
+// the request which were not loaded from the session.
+func (m *manager) save(ctx *httputil.Context, session *Session, loaded map[string][]string) error {
+	if m.config.Store != nil {
+		for _, id := range session.previousIDs {
+			if err := m.config.Store.Delete(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if session.destroyed {
+		if m.config.Store != nil && !session.isNew {
+			if err := m.config.Store.Delete(session.ID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := ctx.Cookie(m.config.Name); err == nil {
+			ctx.SetCookie(m.cookie("", -1))
+		}
+
+		return nil
+	}
+
+	session.Flashes = pendingFlashes(ctx.FlashMessages(), loaded)
+
+	if session.isNew && !session.changed && len(session.Flashes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	session.Accessed = now
+
+	remaining := session.Created.Add(m.config.AbsoluteTimeout).Sub(now)
+	ttl := m.config.IdleTimeout
+	if remaining < ttl {
+		ttl = remaining
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if m.config.Store != nil {
+		if err := m.config.Store.Save(session.ID, data, ttl); err != nil {
+			return err
+		}
+
+		data = []byte(session.ID)
+	}
+
+	value, err := m.encode(data)
+	if err != nil {
+		return err
+	}
+
+	cookie := m.cookie(value, int(remaining/time.Second))
+	if len(cookie.String()) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+
+	ctx.SetCookie(cookie)
+	return nil
+}
+
+func (m *manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.config.Name,
+		Value:    value,
+		Path:     m.config.Path,
+		Domain:   m.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+	}
+}
+
+// pendingFlashes returns the flash messages set during the request, being those
+// following the messages loaded from the session.
+func pendingFlashes(current map[string][]string, loaded map[string][]string) map[string][]string {
+	var pending map[string][]string
+	for name, messages := range current {
+		if previous := loaded[name]; hasPrefix(messages, previous) {
+			messages = messages[len(previous):]
+		}
+
+		if len(messages) == 0 {
+			continue
+		}
+
+		if pending == nil {
+			pending = make(map[string][]string)
+		}
+
+		pending[name] = messages
+	}
+	return pending
+}
+
+func hasPrefix(messages []string, prefix []string) bool {
+	if len(prefix) > len(messages) {
+		return false
+	}
+
+	for index, message := range prefix {
+		if messages[index] != message {
+			return false
+		}
+	}
+	return true
+}
+
+//=========================================================================================
+
+var b64 = base64.RawURLEncoding
+
+// encode encrypts the data if an encryption key is set, then signs it with the
+// cookie name, returning the value in the form payload.signature.
+func (m *manager) encode(data []byte) (string, error) {
+	if m.aead != nil {
+		nonce := make([]byte, m.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+
+		data = m.aead.Seal(nonce, nonce, data, []byte(m.config.Name))
+	}
+
+	payload := b64.EncodeToString(data)
+	return payload + "." + b64.EncodeToString(m.sign(payload)), nil
+}
+
+// decode verifies the signature of the giving value and decrypts its payload.
+func (m *manager) decode(value string) ([]byte, error) {
+	at := strings.LastIndex(value, ".")
+	if at == -1 {
+		return nil, ErrInvalidCookie
+	}
+
+	payload := value[:at]
+
+	signature, err := b64.DecodeString(value[at+1:])
+	if err != nil || !hmac.Equal(signature, m.sign(payload)) {
+		return nil, ErrInvalidCookie
+	}
+
+	data, err := b64.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+
+	if m.aead != nil {
+		size := m.aead.NonceSize()
+		if len(data) < size {
+			return nil, ErrInvalidCookie
+		}
+
+		if data, err = m.aead.Open(nil, data[:size], data[size:], []byte(m.config.Name)); err != nil {
+			return nil, ErrInvalidCookie
+		}
+	}
+
+	return data, nil
+}
+
+func (m *manager) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, m.config.SigningKey)
+	mac.Write([]byte(m.config.Name + "|" + payload))
+	return mac.Sum(nil)
+}
+
+// randomID returns a url safe session id of 32 random bytes.
+func randomID() (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(id), nil
+}
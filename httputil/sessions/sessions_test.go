@@ -0,0 +1,183 @@
+package sessions_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/httputil/sessions"
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/metricstest"
+	"github.com/influx6/faux/tests"
+)
+
+var (
+	signingKey    = []byte("signing-key-for-session-tests")
+	encryptionKey = []byte("0123456789abcdef0123456789abcdef")
+)
+
+func newRouter(config sessions.Config) *httputil.Router {
+	sessionMW, err := sessions.New(config)
+	if err != nil {
+		tests.Failed("Should have created session middleware: %+q", err)
+	}
+
+	router := httputil.NewRouter()
+	router.Use(sessionMW)
+
+	router.Post("/login", func(ctx *httputil.Context) error {
+		session := sessions.Get(ctx)
+		session.Regenerate()
+		session.Set("user", "bob")
+
+		ctx.SetFlash("notice", "welcome")
+		return ctx.Redirect(http.StatusSeeOther, "/")
+	})
+
+	router.Get("/", func(ctx *httputil.Context) error {
+		return ctx.String(http.StatusOK, sessions.Get(ctx).GetString("user")+":"+strings.Join(ctx.Flash("notice"), ","))
+	})
+
+	router.Post("/logout", func(ctx *httputil.Context) error {
+		sessions.Get(ctx).Destroy()
+		return ctx.NoContent(http.StatusNoContent)
+	})
+
+	return router
+}
+
+func request(router *httputil.Router, method string, path string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	for _, item := range (&http.Response{Header: res.Header()}).Cookies() {
+		if item.Name == "session" {
+			return res, item
+		}
+	}
+
+	return res, nil
+}
+
+func testFlow(t *testing.T, config sessions.Config) {
+	router := newRouter(config)
+
+	res, cookie := request(router, "GET", "/", nil)
+	if res.Body.String() != ":" || cookie != nil {
+		tests.Failed("Should have served empty session without cookie: %q %#v", res.Body.String(), cookie)
+	}
+	tests.Passed("Should have served empty session without cookie")
+
+	res, cookie = request(router, "POST", "/login", nil)
+	if res.Code != http.StatusSeeOther || cookie == nil {
+		tests.Failed("Should have set session cookie before redirect: %d", res.Code)
+	}
+	tests.Passed("Should have set session cookie before redirect")
+
+	res, next := request(router, "GET", "/", cookie)
+	if res.Body.String() != "bob:welcome" || next == nil {
+		tests.Failed("Should have kept value and flash across redirect: %q", res.Body.String())
+	}
+	tests.Passed("Should have kept value and flash across redirect")
+
+	if res, _ := request(router, "GET", "/", next); res.Body.String() != "bob:" {
+		tests.Failed("Should have consumed flash message: %q", res.Body.String())
+	}
+	tests.Passed("Should have consumed flash message")
+
+	tampered := *next
+	tampered.Value = "x" + tampered.Value[1:]
+	if res, _ := request(router, "GET", "/", &tampered); res.Body.String() != ":" {
+		tests.Failed("Should have rejected tampered cookie: %q", res.Body.String())
+	}
+	tests.Passed("Should have rejected tampered cookie")
+
+	res, expired := request(router, "POST", "/logout", next)
+	if expired == nil || expired.MaxAge >= 0 {
+		tests.Failed("Should have expired session cookie: %#v", expired)
+	}
+	tests.Passed("Should have expired session cookie")
+}
+
+func TestCookieSessions(t *testing.T) {
+	testFlow(t, sessions.Config{SigningKey: signingKey, EncryptionKey: encryptionKey})
+}
+
+func TestStoreSessions(t *testing.T) {
+	store := sessions.NewMemoryStore()
+	testFlow(t, sessions.Config{SigningKey: signingKey, Store: store})
+
+	if store.Len() != 0 {
+		tests.Failed("Should have removed regenerated and destroyed sessions: %d", store.Len())
+	}
+	tests.Passed("Should have removed regenerated and destroyed sessions")
+}
+
+func TestIdleExpiry(t *testing.T) {
+	router := newRouter(sessions.Config{
+		SigningKey:  signingKey,
+		Store:       sessions.NewMemoryStore(),
+		IdleTimeout: 50 * time.Millisecond,
+	})
+
+	_, cookie := request(router, "POST", "/login", nil)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if res, _ := request(router, "GET", "/", cookie); res.Body.String() != ":" {
+		tests.Failed("Should have expired idle session: %q", res.Body.String())
+	}
+	tests.Passed("Should have expired idle session")
+}
+
+type failingStore struct {
+	*sessions.MemoryStore
+}
+
+func (failingStore) Save(id string, data []byte, ttl time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func TestSaveFailureReported(t *testing.T) {
+	sessionMW, err := sessions.New(sessions.Config{SigningKey: signingKey, Store: failingStore{sessions.NewMemoryStore()}})
+	if err != nil {
+		tests.Failed("Should have created session middleware: %+q", err)
+	}
+
+	recorder := metricstest.NewRecorder()
+	router := httputil.NewRouter(httputil.SetMetrics(recorder.Metrics()))
+	router.Use(sessionMW)
+
+	router.Get("/written", func(ctx *httputil.Context) error {
+		sessions.Get(ctx).Set("user", "bob")
+		return ctx.String(http.StatusOK, "done")
+	})
+
+	router.Get("/empty", func(ctx *httputil.Context) error {
+		sessions.Get(ctx).Set("user", "bob")
+		return nil
+	})
+
+	res, _ := request(router, "GET", "/written", nil)
+	if res.Code != http.StatusOK || res.Body.String() != "done" {
+		tests.Failed("Should have kept written response: %d %q", res.Code, res.Body.String())
+	}
+
+	recorder.Assert(t).Emitted(metricstest.Match().ID(sessions.SaveErrorID).Level(metrics.ErrorLvl))
+	tests.Passed("Should have reported failed save once response was written")
+
+	res, _ = request(router, "GET", "/empty", nil)
+	if res.Code != http.StatusInternalServerError {
+		tests.Failed("Should have answered failed save before response with 500 but got %d", res.Code)
+	}
+	tests.Passed("Should have answered failed save before response with 500")
+}
@@ -0,0 +1,222 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors.
+var (
+	ErrNoFlush      = errors.New("Flush Not Supported")
+	ErrStreamClosed = errors.New("Event stream closed")
+)
+
+// Event defines a server-sent event.
+type Event struct {
+	// ID sets the id clients resume from through the Last-Event-ID header.
+	ID string
+
+	// Event sets the name of the event, clients default to "message".
+	Event string
+
+	// Data sets the data of the event, which may span multiple lines.
+	Data string
+
+	// Retry sets the time clients wait before reconnecting.
+	Retry time.Duration
+}
+
+// SSE sets the response up as a stream of server-sent events, calling the
+// giving function to send events through the EventStream till it returns or
+// the client disconnects. A comment is sent every keepAlive duration to keep
+// proxies from closing the idle connection, unless it is zero.
+//
+// The EventStream is closed once the client disconnects, which the function
+// should watch for through EventStream.Done, with sends failing with
+// ErrStreamClosed. Such failures are not returned from SSE.
+//
+//	return ctx.SSE(15*time.Second, func(stream *httputil.EventStream) error {
+//		for {
+//			select {
+//			case <-stream.Done():
+//				return nil
+//			case msg := <-messages:
+//				if err := stream.Send(httputil.Event{ID: msg.ID, Data: msg.Text}); err != nil {
+//					return err
+//				}
+//			}
+//		}
+//	})
+func (c *Context) SSE(keepAlive time.Duration, fn func(*EventStream) error) error {
+	if _, ok := c.response.Writer.(http.Flusher); !ok {
+		return ErrNoFlush
+	}
+
+	header := c.response.Header()
+	header.Set(HeaderContentType, MIMETextEventStream)
+	header.Set(HeaderCacheControl, "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del(HeaderContentLength)
+
+	if c.request.ProtoMajor == 1 {
+		header.Set("Connection", "keep-alive")
+	}
+
+	c.response.WriteHeader(http.StatusOK)
+	c.response.Flush()
+
+	stream := &EventStream{
+		res:    c.response,
+		done:   c.request.Context().Done(),
+		lastID: c.request.Header.Get(HeaderLastEventID),
+	}
+
+	stop := make(chan struct{})
+	var waiter sync.WaitGroup
+
+	if keepAlive > 0 {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+
+			ticker := time.NewTicker(keepAlive)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-stop:
+					return
+				case <-stream.done:
+					return
+				case <-ticker.C:
+					if err := stream.Comment("keepalive"); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	err := fn(stream)
+
+	close(stop)
+	waiter.Wait()
+
+	if err == ErrStreamClosed {
+		return nil
+	}
+
+	return err
+}
+
+// EventStream writes server-sent events into a response. It is safe for
+// concurrent use.
+type EventStream struct {
+	ml     sync.Mutex
+	res    *Response
+	done   <-chan struct{}
+	lastID string
+	closed bool
+}
+
+// LastEventID returns the id of the last event received by the client, sent
+// through the Last-Event-ID header when it reconnects.
+func (e *EventStream) LastEventID() string {
+	return e.lastID
+}
+
+// Done returns a channel which is closed once the client disconnects.
+func (e *EventStream) Done() <-chan struct{} {
+	return e.done
+}
+
+// Send writes the giving event into the stream and flushes it to the client.
+func (e *EventStream) Send(event Event) error {
+	var out []byte
+
+	if event.ID != "" {
+		out = appendField(out, "id", singleLine(event.ID))
+	}
+
+	if event.Event != "" {
+		out = appendField(out, "event", singleLine(event.Event))
+	}
+
+	if event.Retry > 0 {
+		out = appendField(out, "retry", strconv.FormatInt(int64(event.Retry/time.Millisecond), 10))
+	}
+
+	if event.Data != "" || event.Event != "" {
+		for _, line := range splitLines(event.Data) {
+			out = appendField(out, "data", line)
+		}
+	}
+
+	return e.write(append(out, '\n'))
+}
+
+// Comment writes the giving text into the stream as a comment, which clients
+// ignore.
+func (e *EventStream) Comment(text string) error {
+	var out []byte
+	for _, line := range splitLines(text) {
+		out = append(out, ':', ' ')
+		out = append(out, line...)
+		out = append(out, '\n')
+	}
+
+	return e.write(append(out, '\n'))
+}
+
+// write writes the giving bytes into the response and flushes them, failing
+// with ErrStreamClosed once the client disconnected.
+func (e *EventStream) write(out []byte) error {
+	e.ml.Lock()
+	defer e.ml.Unlock()
+
+	if e.closed {
+		return ErrStreamClosed
+	}
+
+	select {
+	case <-e.done:
+		e.closed = true
+		return ErrStreamClosed
+	default:
+	}
+
+	// Failed writes mean the connection is gone.
+	if _, err := e.res.Write(out); err != nil {
+		e.closed = true
+		return ErrStreamClosed
+	}
+
+	e.res.Flush()
+	return nil
+}
+
+// appendField appends a field line of an event.
+func appendField(out []byte, name string, value string) []byte {
+	out = append(out, name...)
+	out = append(out, ':', ' ')
+	out = append(out, value...)
+	return append(out, '\n')
+}
+
+// splitLines splits the giving text on any of the line endings allowed within
+// event streams.
+func splitLines(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	return strings.Split(text, "\n")
+}
+
+// singleLine removes line endings from the giving field value, as they would
+// end the field.
+func singleLine(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,167 @@
+package httputil_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/httputil/httptesting"
+	"github.com/influx6/faux/tests"
+)
+
+func TestSSE(t *testing.T) {
+	router := httputil.NewRouter()
+	router.Get("/events", func(ctx *httputil.Context) error {
+		return ctx.SSE(0, func(stream *httputil.EventStream) error {
+			start := 0
+			if id := stream.LastEventID(); id != "" {
+				start, _ = strconv.Atoi(id)
+			}
+
+			for index := start + 1; index <= 3; index++ {
+				err := stream.Send(httputil.Event{
+					ID:    strconv.Itoa(index),
+					Event: "tick",
+					Data:  "line one\nline two\r\nline " + strconv.Itoa(index),
+					Retry: 2 * time.Second,
+				})
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/events")
+	if err != nil {
+		tests.Failed("Should have connected to event stream: %+q", err)
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.Header.Get("Content-Type") != "text/event-stream" || res.Header.Get("Cache-Control") != "no-cache" {
+		tests.Failed("Should have set event stream headers: %+q", res.Header)
+	}
+
+	expected := "id: 1\nevent: tick\nretry: 2000\ndata: line one\ndata: line two\ndata: line 1\n\n"
+	if !strings.HasPrefix(string(body), expected) {
+		tests.Failed("Should have written event fields but got %q", body)
+	}
+	tests.Passed("Should have written event fields with multi-line data")
+
+	reader, err := httptesting.Subscribe(context.Background(), server.URL+"/events", "1")
+	if err != nil {
+		tests.Failed("Should have subscribed to event stream: %+q", err)
+	}
+	defer reader.Close()
+
+	event, err := reader.Next()
+	if err != nil {
+		tests.Failed("Should have read event: %+q", err)
+	}
+
+	if event.ID != "2" || event.Event != "tick" || event.Data != "line one\nline two\nline 2" || event.Retry != 2*time.Second {
+		tests.Failed("Should have resumed after last event id: %+v", event)
+	}
+
+	if event, _ := reader.Next(); event.ID != "3" {
+		tests.Failed("Should have read final event: %+v", event)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		tests.Failed("Should have ended stream: %+q", err)
+	}
+	tests.Passed("Should have resumed from Last-Event-ID")
+}
+
+func TestSSEKeepAliveAndDisconnect(t *testing.T) {
+	stopped := make(chan error, 1)
+
+	router := httputil.NewRouter()
+	router.Get("/events", func(ctx *httputil.Context) error {
+		err := ctx.SSE(10*time.Millisecond, func(stream *httputil.EventStream) error {
+			if err := stream.Send(httputil.Event{Data: "hello"}); err != nil {
+				return err
+			}
+
+			<-stream.Done()
+			return stream.Send(httputil.Event{Data: "gone"})
+		})
+
+		stopped <- err
+		return err
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	cancelable, cancel := context.WithCancel(context.Background())
+
+	res, err := http.DefaultClient.Do(req.WithContext(cancelable))
+	if err != nil {
+		tests.Failed("Should have connected to event stream: %+q", err)
+	}
+
+	buf := make([]byte, 512)
+	var received string
+	for !strings.Contains(received, ": keepalive\n\n") {
+		n, err := res.Body.Read(buf)
+		if err != nil {
+			tests.Failed("Should have read keepalive comment: %+q", err)
+		}
+		received += string(buf[:n])
+	}
+
+	if !strings.HasPrefix(received, "data: hello\n\n") {
+		tests.Failed("Should have read event before keepalive but got %q", received)
+	}
+	tests.Passed("Should have sent keepalive comments")
+
+	cancel()
+	res.Body.Close()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			tests.Failed("Should have stopped cleanly but got %+q", err)
+		}
+	case <-time.After(2 * time.Second):
+		tests.Failed("Should have stopped once client disconnected")
+	}
+	tests.Passed("Should have stopped once client disconnected")
+}
+
+func TestEventReader(t *testing.T) {
+	stream := ": comment\r\nevent: update\rdata: a\r\ndata\n\nid: 7\ndata:b\n\nretry: 500\n\n"
+	reader := httptesting.NewEventReader(ioutil.NopCloser(strings.NewReader(stream)))
+
+	event, err := reader.Next()
+	if err != nil || event.Event != "update" || event.Data != "a\n" {
+		tests.Failed("Should have parsed event with mixed line endings: %+v %+q", event, err)
+	}
+
+	event, err = reader.Next()
+	if err != nil || event.ID != "7" || event.Data != "b" || event.Event != "" {
+		tests.Failed("Should have parsed event without space after colon: %+v %+q", event, err)
+	}
+
+	if _, err := reader.Next(); err != io.EOF || reader.Retry() != 500*time.Millisecond {
+		tests.Failed("Should have kept retry from event without data: %+q", err)
+	}
+	tests.Passed("Should have parsed event streams")
+}
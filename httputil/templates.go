@@ -23,6 +23,8 @@ func TextContextFunctions(c *Context) template.FuncMap {
 			c.SetFlash(name, message)
 			return ""
 		},
+		"csrfToken": c.CSRFToken,
+		"csrfField": c.CSRFField,
 	}
 }
 
@@ -44,5 +46,9 @@ func HTMLContextFunctions(c *Context) htemplate.FuncMap {
 			c.SetFlash(name, message)
 			return ""
 		},
+		"csrfToken": c.CSRFToken,
+		"csrfField": func() htemplate.HTML {
+			return htemplate.HTML(c.CSRFField())
+		},
 	}
 }
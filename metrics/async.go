@@ -0,0 +1,484 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// errors.
+var (
+	ErrDispatcherClosed = errors.New("dispatcher already closed")
+)
+
+// OverflowPolicy defines how a Dispatcher queue handles a new Entry when full.
+type OverflowPolicy int
+
+// overflow policies.
+const (
+	// BlockOnFull blocks the sender until the queue has room.
+	BlockOnFull OverflowPolicy = iota
+
+	// DropNewest drops the incoming Entry.
+	DropNewest
+
+	// DropOldest drops the oldest queued Entry to make room for the incoming one.
+	DropOldest
+
+	// SpillToDisk writes the incoming Entry into a spill file which is read back
+	// in order once the queue drains. Spilled entries go through json, so their
+	// field values are delivered in their decoded forms, numbers as float64,
+	// structs as map[string]interface{} and errors as their messages.
+	SpillToDisk
+)
+
+// String returns the name of the policy.
+func (o OverflowPolicy) String() string {
+	switch o {
+	case BlockOnFull:
+		return "block"
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case SpillToDisk:
+		return "spill"
+	}
+
+	return "unknown"
+}
+
+// AsyncConfig defines the configuration used by a Dispatcher for each of its queues.
+type AsyncConfig struct {
+	// QueueSize sets the maximum entries held in memory per processor, defaults to 1024.
+	QueueSize int
+
+	// Policy sets the behaviour when a queue is full.
+	Policy OverflowPolicy
+
+	// SpillDir sets the directory of spill files, defaults to os.TempDir().
+	SpillDir string
+
+	// OnError sets the function called with errors returned by processors.
+	OnError func(error)
+}
+
+// QueueStats defines the counters of a single Dispatcher queue.
+type QueueStats struct {
+	Queued    uint64 `json:"queued"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Spilled   uint64 `json:"spilled"`
+	Failed    uint64 `json:"failed"`
+	Pending   int    `json:"pending"`
+}
+
+// AsyncMetrics defines a Metrics whoes processors are served asynchronously,
+// exposing methods to drain and observe the underline queues.
+type AsyncMetrics interface {
+	Metrics
+	Flush(context.Context) error
+	Close(context.Context) error
+	Stats() []QueueStats
+}
+
+// NewAsync returns a Metrics like New, where all provided Processors are served
+// asynchronously through a Dispatcher created with the giving AsyncConfig.
+func NewAsync(config AsyncConfig, vals ...interface{}) AsyncMetrics {
+	var procs []Processors
+	var others []interface{}
+
+	for _, val := range vals {
+		switch item := val.(type) {
+		case Collector, func(*Entry):
+			others = append(others, item)
+		case Processors:
+			procs = append(procs, item)
+		}
+	}
+
+	dispatcher := NewDispatcher(config, procs...)
+
+	return asyncMetrics{
+		Metrics:    New(append(others, dispatcher)...),
+		Dispatcher: dispatcher,
+	}
+}
+
+type asyncMetrics struct {
+	Metrics
+	*Dispatcher
+}
+
+//=====================================================================================
+
+// Dispatcher implements the Processors interface, delivering every Entry to each
+// of its processors on a separate goroutine through a bounded queue, so the caller
+// is never stalled by a slow processor unless the BlockOnFull policy is used.
+type Dispatcher struct {
+	queues []*asyncQueue
+}
+
+// NewDispatcher returns a new Dispatcher for the provided processors.
+func NewDispatcher(config AsyncConfig, procs ...Processors) *Dispatcher {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+
+	if config.SpillDir == "" {
+		config.SpillDir = os.TempDir()
+	}
+
+	var dispatcher Dispatcher
+	for index, proc := range procs {
+		queue := newAsyncQueue(index, proc, config)
+		dispatcher.queues = append(dispatcher.queues, queue)
+		go queue.run()
+	}
+
+	return &dispatcher
+}
+
+// Handle implements the Processors interface, queuing the Entry for each processor.
+func (d *Dispatcher) Handle(en Entry) error {
+	for _, queue := range d.queues {
+		if err := queue.push(en); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush blocks till all queued entries are delivered or the context is done.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	for _, queue := range d.queues {
+		if err := queue.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops accepting new entries and blocks till all queued entries are
+// delivered or the context is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	var lastErr error
+	for _, queue := range d.queues {
+		if err := queue.close(ctx); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Stats returns the counters of each queue in the order of the processors.
+func (d *Dispatcher) Stats() []QueueStats {
+	stats := make([]QueueStats, 0, len(d.queues))
+	for _, queue := range d.queues {
+		stats = append(stats, queue.snapshot())
+	}
+	return stats
+}
+
+//=====================================================================================
+
+type asyncQueue struct {
+	index  int
+	proc   Processors
+	config AsyncConfig
+	done   chan struct{}
+
+	ml       sync.Mutex
+	cond     *sync.Cond
+	items    []Entry
+	spill    *spillFile
+	closed   bool
+	inflight bool
+	stats    QueueStats
+}
+
+func newAsyncQueue(index int, proc Processors, config AsyncConfig) *asyncQueue {
+	queue := &asyncQueue{
+		index:  index,
+		proc:   proc,
+		config: config,
+		done:   make(chan struct{}),
+	}
+	queue.cond = sync.NewCond(&queue.ml)
+	return queue
+}
+
+func (q *asyncQueue) push(en Entry) error {
+	q.ml.Lock()
+	defer q.ml.Unlock()
+
+	if q.closed {
+		return ErrDispatcherClosed
+	}
+
+	// Entries must go into the spill file while it has pending entries to
+	// keep them in order.
+	if q.spill != nil && q.spill.pending > 0 {
+		return q.spillEntry(en)
+	}
+
+	for len(q.items) >= q.config.QueueSize {
+		switch q.config.Policy {
+		case DropNewest:
+			q.stats.Dropped++
+			return nil
+		case DropOldest:
+			q.items = q.items[1:]
+			q.stats.Dropped++
+		case SpillToDisk:
+			return q.spillEntry(en)
+		default:
+			q.cond.Wait()
+			if q.closed {
+				return ErrDispatcherClosed
+			}
+		}
+	}
+
+	q.items = append(q.items, en)
+	q.stats.Queued++
+	q.cond.Broadcast()
+	return nil
+}
+
+// spillEntry writes the giving entry into the spill file. It expects the lock to be held.
+func (q *asyncQueue) spillEntry(en Entry) error {
+	if q.spill == nil {
+		q.spill = &spillFile{
+			path: filepath.Join(q.config.SpillDir, fmt.Sprintf("metrics-spill-%d-%d-%p.jsonl", os.Getpid(), q.index, q)),
+		}
+	}
+
+	if err := q.spill.write(en); err != nil {
+		q.stats.Dropped++
+		return err
+	}
+
+	q.stats.Queued++
+	q.stats.Spilled++
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *asyncQueue) pending() int {
+	pending := len(q.items)
+	if q.spill != nil {
+		pending += q.spill.pending
+	}
+	return pending
+}
+
+func (q *asyncQueue) run() {
+	defer close(q.done)
+
+	for {
+		q.ml.Lock()
+		for q.pending() == 0 && !q.closed {
+			q.cond.Wait()
+		}
+
+		if q.pending() == 0 && q.closed {
+			q.cleanup()
+			q.ml.Unlock()
+			return
+		}
+
+		var en Entry
+		if len(q.items) != 0 {
+			en = q.items[0]
+			q.items = q.items[1:]
+		} else {
+			var err error
+			en, err = q.spill.read()
+
+			if q.spill.broken {
+				q.stats.Dropped += uint64(q.spill.pending)
+				q.spill.pending = 0
+			}
+
+			if q.spill.pending == 0 {
+				q.cleanup()
+			}
+
+			if err != nil {
+				q.stats.Failed++
+				q.cond.Broadcast()
+				q.ml.Unlock()
+				q.report(err)
+				continue
+			}
+		}
+
+		q.inflight = true
+		q.cond.Broadcast()
+		q.ml.Unlock()
+
+		err := q.proc.Handle(en)
+
+		q.ml.Lock()
+		q.inflight = false
+		if err != nil {
+			q.stats.Failed++
+		} else {
+			q.stats.Delivered++
+		}
+		q.cond.Broadcast()
+		q.ml.Unlock()
+
+		if err != nil {
+			q.report(err)
+		}
+	}
+}
+
+// cleanup removes the spill file if any. It expects the lock to be held.
+func (q *asyncQueue) cleanup() {
+	if q.spill != nil {
+		q.spill.remove()
+		q.spill = nil
+	}
+}
+
+func (q *asyncQueue) report(err error) {
+	if q.config.OnError != nil {
+		q.config.OnError(err)
+	}
+}
+
+// wait blocks till the condition returns true or the context is done.
+func (q *asyncQueue) wait(ctx context.Context, condition func() bool) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.ml.Lock()
+			q.cond.Broadcast()
+			q.ml.Unlock()
+		case <-stop:
+		}
+	}()
+
+	q.ml.Lock()
+	defer q.ml.Unlock()
+
+	for !condition() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.cond.Wait()
+	}
+
+	return nil
+}
+
+func (q *asyncQueue) flush(ctx context.Context) error {
+	return q.wait(ctx, func() bool {
+		return q.pending() == 0 && !q.inflight
+	})
+}
+
+func (q *asyncQueue) close(ctx context.Context) error {
+	q.ml.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.ml.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *asyncQueue) snapshot() QueueStats {
+	q.ml.Lock()
+	defer q.ml.Unlock()
+
+	stats := q.stats
+	stats.Pending = q.pending()
+	return stats
+}
+
+//=====================================================================================
+
+// spillFile stores entries as json lines on disk, to be read back in order.
+type spillFile struct {
+	path    string
+	writer  *os.File
+	reader  *os.File
+	buffer  *bufio.Reader
+	pending int
+	broken  bool
+}
+
+func (s *spillFile) write(en Entry) error {
+	if s.writer == nil {
+		file, err := os.OpenFile(s.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		s.writer = file
+	}
+
+	data, err := json.Marshal(Encodable(en))
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	s.pending++
+	return nil
+}
+
+func (s *spillFile) read() (Entry, error) {
+	var en Entry
+
+	if s.reader == nil {
+		file, err := os.Open(s.path)
+		if err != nil {
+			s.broken = true
+			return en, err
+		}
+		s.reader = file
+		s.buffer = bufio.NewReader(file)
+	}
+
+	line, err := s.buffer.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		s.broken = true
+		return en, err
+	}
+
+	s.pending--
+	err = json.Unmarshal(line, &en)
+	return en, err
+}
+
+func (s *spillFile) remove() {
+	if s.writer != nil {
+		s.writer.Close()
+	}
+
+	if s.reader != nil {
+		s.reader.Close()
+	}
+
+	os.Remove(s.path)
+}
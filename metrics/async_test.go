@@ -0,0 +1,232 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/tests"
+)
+
+// gated blocks delivery of entries till released, signaling once the first
+// entry is being delivered.
+type gated struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+
+	ml      sync.Mutex
+	entries []metrics.Entry
+}
+
+func newGated() *gated {
+	return &gated{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (g *gated) Handle(en metrics.Entry) error {
+	g.once.Do(func() { close(g.started) })
+	<-g.release
+
+	g.ml.Lock()
+	defer g.ml.Unlock()
+	g.entries = append(g.entries, en)
+	return nil
+}
+
+func (g *gated) messages() []string {
+	g.ml.Lock()
+	defer g.ml.Unlock()
+
+	var messages []string
+	for _, en := range g.entries {
+		messages = append(messages, en.Message)
+	}
+	return messages
+}
+
+func sameMessages(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for index := range want {
+		if got[index] != want[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// overflow returns a Dispatcher using the giving policy whoes processor is
+// busy with entry "0" while entries "1" to "4" are handled.
+func overflow(config metrics.AsyncConfig) (*metrics.Dispatcher, *gated) {
+	proc := newGated()
+	dispatcher := metrics.NewDispatcher(config, proc)
+
+	dispatcher.Handle(metrics.Entry{Message: "0"})
+	<-proc.started
+
+	for _, message := range []string{"1", "2", "3", "4"} {
+		dispatcher.Handle(metrics.Entry{Message: message})
+	}
+
+	return dispatcher, proc
+}
+
+func TestDispatcherDropPolicies(t *testing.T) {
+	dispatcher, proc := overflow(metrics.AsyncConfig{QueueSize: 2, Policy: metrics.DropNewest})
+	close(proc.release)
+
+	if err := dispatcher.Flush(context.Background()); err != nil {
+		tests.Failed("Should have flushed dispatcher: %+q", err)
+	}
+
+	if messages := proc.messages(); !sameMessages(messages, "0", "1", "2") {
+		tests.Failed("Should have dropped newest entries: %+q", messages)
+	}
+
+	if stats := dispatcher.Stats()[0]; stats.Dropped != 2 || stats.Delivered != 3 || stats.Pending != 0 {
+		tests.Failed("Should have counted dropped entries: %+v", stats)
+	}
+	tests.Passed("Should have dropped newest entries when full")
+
+	dispatcher, proc = overflow(metrics.AsyncConfig{QueueSize: 2, Policy: metrics.DropOldest})
+	close(proc.release)
+	dispatcher.Flush(context.Background())
+
+	if messages := proc.messages(); !sameMessages(messages, "0", "3", "4") {
+		tests.Failed("Should have dropped oldest entries: %+q", messages)
+	}
+
+	if stats := dispatcher.Stats()[0]; stats.Dropped != 2 || stats.Delivered != 3 {
+		tests.Failed("Should have counted dropped entries: %+v", stats)
+	}
+	tests.Passed("Should have dropped oldest entries when full")
+}
+
+func TestDispatcherBlockPolicy(t *testing.T) {
+	proc := newGated()
+	dispatcher := metrics.NewDispatcher(metrics.AsyncConfig{QueueSize: 1, Policy: metrics.BlockOnFull}, proc)
+
+	dispatcher.Handle(metrics.Entry{Message: "0"})
+	<-proc.started
+	dispatcher.Handle(metrics.Entry{Message: "1"})
+
+	handled := make(chan struct{})
+	go func() {
+		dispatcher.Handle(metrics.Entry{Message: "2"})
+		close(handled)
+	}()
+
+	select {
+	case <-handled:
+		tests.Failed("Should have blocked sender while queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tests.Passed("Should have blocked sender while queue is full")
+
+	close(proc.release)
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		tests.Failed("Should have unblocked sender once queue drained")
+	}
+
+	dispatcher.Flush(context.Background())
+	if messages := proc.messages(); !sameMessages(messages, "0", "1", "2") {
+		tests.Failed("Should have delivered all entries in order: %+q", messages)
+	}
+	tests.Passed("Should have delivered all entries once unblocked")
+}
+
+func TestDispatcherSpillPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics-spill")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	proc := newGated()
+	dispatcher := metrics.NewDispatcher(metrics.AsyncConfig{QueueSize: 1, Policy: metrics.SpillToDisk, SpillDir: dir}, proc)
+
+	dispatcher.Handle(metrics.Entry{Message: "0"})
+	<-proc.started
+
+	dispatcher.Handle(metrics.Entry{Message: "1"})
+	dispatcher.Handle(metrics.Entry{Message: "2", Field: metrics.Field{"count": 3, "err": errors.New("failed")}})
+	dispatcher.Handle(metrics.Entry{Message: "3"})
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		tests.Failed("Should have written spill file: %d", len(files))
+	}
+
+	close(proc.release)
+	if err := dispatcher.Flush(context.Background()); err != nil {
+		tests.Failed("Should have flushed dispatcher: %+q", err)
+	}
+
+	if messages := proc.messages(); !sameMessages(messages, "0", "1", "2", "3") {
+		tests.Failed("Should have delivered spilled entries in order: %+q", messages)
+	}
+	tests.Passed("Should have delivered spilled entries in order")
+
+	if stats := dispatcher.Stats()[0]; stats.Spilled != 2 || stats.Delivered != 4 || stats.Dropped != 0 {
+		tests.Failed("Should have counted spilled entries: %+v", stats)
+	}
+	tests.Passed("Should have counted spilled entries")
+
+	fields := proc.entries[2].Field
+	if fields["count"] != float64(3) || fields["err"] != "failed" {
+		tests.Failed("Should have decoded spilled fields from json: %#v", fields)
+	}
+	tests.Passed("Should have decoded spilled fields from json")
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		tests.Failed("Should have removed spill file once drained: %d", len(files))
+	}
+	tests.Passed("Should have removed spill file once drained")
+}
+
+func TestDispatcherClose(t *testing.T) {
+	proc := newGated()
+	close(proc.release)
+
+	dispatcher := metrics.NewDispatcher(metrics.AsyncConfig{}, proc)
+	for _, message := range []string{"0", "1", "2"} {
+		dispatcher.Handle(metrics.Entry{Message: message})
+	}
+
+	if err := dispatcher.Close(context.Background()); err != nil {
+		tests.Failed("Should have closed dispatcher: %+q", err)
+	}
+
+	if messages := proc.messages(); !sameMessages(messages, "0", "1", "2") {
+		tests.Failed("Should have drained queue on close: %+q", messages)
+	}
+	tests.Passed("Should have drained queue on close")
+
+	if err := dispatcher.Handle(metrics.Entry{}); err != metrics.ErrDispatcherClosed {
+		tests.Failed("Should have refused entries after close: %+q", err)
+	}
+	tests.Passed("Should have refused entries after close")
+
+	blocked := newGated()
+	dispatcher = metrics.NewDispatcher(metrics.AsyncConfig{}, blocked)
+	dispatcher.Handle(metrics.Entry{})
+	<-blocked.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := dispatcher.Flush(ctx); err != context.DeadlineExceeded {
+		tests.Failed("Should have stopped flushing once context is done: %+q", err)
+	}
+	tests.Passed("Should have stopped flushing once context is done")
+	close(blocked.release)
+}
@@ -2,12 +2,14 @@ package metrics
 
 import (
 	"errors"
+	"sync"
 	"time"
 )
 
 // errors.
 var (
-	ErrBatchEmitterClosed = errors.New("batcher already closed")
+	ErrBatchEmitterClosed     = errors.New("batcher already closed")
+	ErrBatchEmitterNotRunning = errors.New("batcher not running")
 )
 
 // MetricConsumer exposes a interface which allows the consumption of Entries
@@ -17,16 +19,90 @@ type MetricConsumer interface {
 	Run(<-chan struct{})
 }
 
+// BatchMetricConsumer exposes a MetricConsumer which collects entries in batches,
+// providing methods to flush the current batch and observe its operations.
+type BatchMetricConsumer interface {
+	MetricConsumer
+	Flush() error
+	Err() error
+	ClearErr()
+	Stats() BatchStats
+}
+
+// BatchStats defines the counters of a batch consumer.
+type BatchStats struct {
+	Queued    uint64 `json:"queued"`
+	Committed uint64 `json:"committed"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"`
+	Retries   uint64 `json:"retries"`
+}
+
 // CommitFunction defines a function type which is used to process a batch of Entry.
 type CommitFunction func([]Entry) error
 
+// BatchConfig defines the configuration used by a batch consumer.
+type BatchConfig struct {
+	// MaxSize sets the total entries collected before a batch is committed.
+	MaxSize int
+
+	// MaxWait sets the maximum time a batch waits before it is committed.
+	MaxWait time.Duration
+
+	// MaxRetries sets the total retries made for a failed commit before the
+	// batch is counted as failed and discarded.
+	MaxRetries int
+
+	// Backoff sets the initial wait before retrying a commit, doubled on every retry.
+	Backoff time.Duration
+
+	// MaxBackoff sets the maximum wait between retries.
+	MaxBackoff time.Duration
+
+	// HandleTimeout sets the maximum time Handle waits for a busy consumer before
+	// the entry is dropped, defaults to 5ms.
+	HandleTimeout time.Duration
+
+	// MaxPending sets the total batches waiting to be committed while a commit
+	// is in progress, beyond which new entries wait for a commit to finish.
+	// Defaults to 4.
+	MaxPending int
+
+	// OnError sets the function called with every failed commit.
+	OnError func(error)
+}
+
 // BatchConsumer returns a new instance of a batchConsumer.
-func BatchConsumer(maxSize int, maxwait time.Duration, fn CommitFunction) MetricConsumer {
+func BatchConsumer(maxSize int, maxwait time.Duration, fn CommitFunction) BatchMetricConsumer {
+	return BatchConsumerWith(BatchConfig{
+		MaxSize: maxSize,
+		MaxWait: maxwait,
+	}, fn)
+}
+
+// BatchConsumerWith returns a new instance of a batchConsumer using the giving BatchConfig.
+func BatchConsumerWith(config BatchConfig, fn CommitFunction) BatchMetricConsumer {
+	if config.HandleTimeout <= 0 {
+		config.HandleTimeout = 5 * time.Millisecond
+	}
+
+	if config.Backoff <= 0 {
+		config.Backoff = 50 * time.Millisecond
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Second
+	}
+
+	if config.MaxPending <= 0 {
+		config.MaxPending = 4
+	}
+
 	var batch batchConsumer
 	batch.fn = fn
-	batch.maxlen = maxSize
-	batch.maxwait = maxwait
+	batch.config = config
 	batch.actions = make(chan func(), 0)
+	batch.closed = make(chan struct{})
 
 	return &batch
 }
@@ -35,76 +111,217 @@ func BatchConsumer(maxSize int, maxwait time.Duration, fn CommitFunction) Metric
 // mode until a provide size threshold is met then it's provided against
 // a provided function for procesing.
 type batchConsumer struct {
-	maxlen    int
+	config  BatchConfig
+	batch   []Entry
+	actions chan func()
+	commits chan commitJob
+	closed  chan struct{}
+	once    sync.Once
+	fn      CommitFunction
+
+	ml        sync.Mutex
+	running   bool
 	commitErr error
-	batch     []Entry
-	actions   chan func()
-	maxwait   time.Duration
-	fn        CommitFunction
+	stats     BatchStats
+}
+
+// commitJob defines a batch handed to the committing goroutine, with the
+// channel receiving the result of the commit if any.
+type commitJob struct {
+	batch []Entry
+	errs  chan error
 }
 
 // Handle takes provided entries and emits giving entries into batch,
 // returning any error encountered with the addition of the entry
-// or one received during the last commit of the entries.
+// or one received during the last failed commit of the entries, which
+// is cleared once returned. Entries which can not be added within the
+// HandleTimeout are dropped and counted in the BatchStats.
 func (bm *batchConsumer) Handle(en Entry) error {
-	if bm.commitErr != nil {
-		return bm.commitErr
-	}
-
-	errChan := make(chan error, 0)
 	action := func() {
 		bm.batch = append(bm.batch, en)
+		bm.count(func(stats *BatchStats) { stats.Queued++ })
 
-		if len(bm.batch) >= bm.maxlen {
-			batch := bm.batch
-			bm.batch = nil
-
-			if err := bm.fn(batch); err != nil {
-				errChan <- err
-				return
-			}
+		if len(bm.batch) >= bm.config.MaxSize {
+			bm.dispatch(nil)
 		}
+	}
 
-		close(errChan)
+	select {
+	case <-bm.closed:
+		bm.count(func(stats *BatchStats) { stats.Dropped++ })
+		return ErrBatchEmitterClosed
+	case bm.actions <- action:
+		return bm.takeErr()
+	case <-time.After(bm.config.HandleTimeout):
+		bm.count(func(stats *BatchStats) { stats.Dropped++ })
+		return nil
+	}
+}
+
+// Flush commits the current batch immediately, waiting for all batches before
+// it to be committed, returning any error from the commit. It fails with
+// ErrBatchEmitterNotRunning if Run was never called.
+func (bm *batchConsumer) Flush() error {
+	bm.ml.Lock()
+	running := bm.running
+	bm.ml.Unlock()
+
+	if !running {
+		return ErrBatchEmitterNotRunning
+	}
+
+	errChan := make(chan error, 1)
+	action := func() {
+		bm.dispatch(errChan)
 	}
 
 	select {
+	case <-bm.closed:
+		return ErrBatchEmitterClosed
 	case bm.actions <- action:
-		return <-errChan
-	case <-time.After(5 * time.Millisecond):
+		if err := <-errChan; err != nil {
+			bm.ClearErr()
+			return err
+		}
 		return nil
 	}
 }
 
-// Emit takes provided entries and emits giving entries into batch loop.
-func (bm *batchConsumer) commit() {
-	bm.actions <- func() {
-		if len(bm.batch) == 0 {
-			return
+// Err returns the error from the last failed commit if not yet cleared.
+func (bm *batchConsumer) Err() error {
+	bm.ml.Lock()
+	defer bm.ml.Unlock()
+	return bm.commitErr
+}
+
+// ClearErr clears the error from the last failed commit.
+func (bm *batchConsumer) ClearErr() {
+	bm.ml.Lock()
+	defer bm.ml.Unlock()
+	bm.commitErr = nil
+}
+
+// Stats returns the current counters of the consumer.
+func (bm *batchConsumer) Stats() BatchStats {
+	bm.ml.Lock()
+	defer bm.ml.Unlock()
+	return bm.stats
+}
+
+func (bm *batchConsumer) takeErr() error {
+	bm.ml.Lock()
+	defer bm.ml.Unlock()
+
+	err := bm.commitErr
+	bm.commitErr = nil
+	return err
+}
+
+func (bm *batchConsumer) count(fn func(*BatchStats)) {
+	bm.ml.Lock()
+	defer bm.ml.Unlock()
+	fn(&bm.stats)
+}
+
+// dispatch hands the current batch to the committing goroutine, sending the
+// result of the commit into the giving channel if not nil. Empty batches are
+// only handed over for the channel. It must only be called from the Run goroutine.
+func (bm *batchConsumer) dispatch(errs chan error) {
+	if len(bm.batch) == 0 && errs == nil {
+		return
+	}
+
+	bm.commits <- commitJob{batch: bm.batch, errs: errs}
+	bm.batch = nil
+}
+
+// committer commits the batches handed over by dispatch in order, till the
+// commits channel is closed.
+func (bm *batchConsumer) committer(done chan struct{}) {
+	defer close(done)
+
+	for job := range bm.commits {
+		err := bm.commit(job.batch)
+		if job.errs != nil {
+			job.errs <- err
 		}
+	}
+}
 
-		batch := bm.batch
-		bm.batch = nil
-		bm.commitErr = bm.fn(batch)
+// commit delivers the giving batch into the commit function, retrying with
+// backoff on failure. It must only be called from the committing goroutine,
+// so retries never keep the Run goroutine from taking in new entries.
+func (bm *batchConsumer) commit(batch []Entry) error {
+	if len(batch) == 0 {
+		return nil
 	}
+
+	wait := bm.config.Backoff
+
+	var err error
+	for attempt := 0; attempt <= bm.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			bm.count(func(stats *BatchStats) { stats.Retries++ })
+			time.Sleep(wait)
+
+			wait *= 2
+			if wait > bm.config.MaxBackoff {
+				wait = bm.config.MaxBackoff
+			}
+		}
+
+		if err = bm.fn(batch); err == nil {
+			bm.count(func(stats *BatchStats) { stats.Committed += uint64(len(batch)) })
+			return nil
+		}
+
+		if bm.config.OnError != nil {
+			bm.config.OnError(err)
+		}
+	}
+
+	bm.ml.Lock()
+	bm.commitErr = err
+	bm.stats.Failed += uint64(len(batch))
+	bm.ml.Unlock()
+
+	return err
 }
 
 // Run handles running the necessary logic to add entries into the batch
 // and call the necessary commit call to evaluate the provided function with
-// the collected Entries.
+// the collected Entries. Batches are committed on a separate goroutine,
+// with Run waiting for all of them to be committed once the close channel is
+// closed, after which new entries are rejected.
 func (bm *batchConsumer) Run(closeChan <-chan struct{}) {
-	ticker := time.NewTimer(bm.maxwait)
+	bm.ml.Lock()
+	bm.running = true
+	bm.ml.Unlock()
+
+	bm.commits = make(chan commitJob, bm.config.MaxPending)
+	committed := make(chan struct{})
+	go bm.committer(committed)
+
+	ticker := time.NewTimer(bm.config.MaxWait)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			ticker.Reset(bm.maxwait)
-			go bm.commit()
+			ticker.Reset(bm.config.MaxWait)
+			bm.dispatch(nil)
 		case action := <-bm.actions:
-			ticker.Reset(bm.maxwait)
+			ticker.Reset(bm.config.MaxWait)
 			action()
 		case <-closeChan:
+			bm.once.Do(func() {
+				close(bm.closed)
+			})
+
+			bm.dispatch(nil)
+			close(bm.commits)
+			<-committed
 			return
 		}
 	}
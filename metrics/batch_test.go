@@ -0,0 +1,167 @@
+package metrics_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/tests"
+)
+
+func TestBatchConsumerFlushOnClose(t *testing.T) {
+	var committed []metrics.Entry
+	consumer := metrics.BatchConsumerWith(metrics.BatchConfig{
+		MaxSize:       10,
+		MaxWait:       time.Minute,
+		HandleTimeout: time.Second,
+	}, func(entries []metrics.Entry) error {
+		committed = append(committed, entries...)
+		return nil
+	})
+
+	closer := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(closer)
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		if err := consumer.Handle(metrics.Entry{Message: "pending"}); err != nil {
+			tests.Failed("Should have added entry to batch: %+q", err)
+		}
+	}
+	tests.Passed("Should have added entries to batch")
+
+	close(closer)
+	<-done
+
+	if len(committed) != 3 {
+		tests.Failed("Should have committed pending batch on close but got %d", len(committed))
+	}
+	tests.Passed("Should have committed pending batch on close")
+
+	if err := consumer.Handle(metrics.Entry{}); err != metrics.ErrBatchEmitterClosed {
+		tests.Failed("Should have rejected entry after close: %+q", err)
+	}
+	tests.Passed("Should have rejected entry after close")
+
+	stats := consumer.Stats()
+	if stats.Queued != 3 || stats.Committed != 3 || stats.Dropped != 1 {
+		tests.Failed("Should have counted entries: %#v", stats)
+	}
+	tests.Passed("Should have counted entries")
+}
+
+func TestBatchConsumerRetry(t *testing.T) {
+	var attempts int
+	failure := errors.New("sink unavailable")
+	consumer := metrics.BatchConsumerWith(metrics.BatchConfig{
+		MaxSize:       1,
+		MaxWait:       time.Minute,
+		MaxRetries:    2,
+		Backoff:       time.Millisecond,
+		HandleTimeout: time.Second,
+	}, func(entries []metrics.Entry) error {
+		attempts++
+		if attempts < 3 {
+			return failure
+		}
+		return nil
+	})
+
+	closer := make(chan struct{})
+	defer close(closer)
+	go consumer.Run(closer)
+
+	if err := consumer.Handle(metrics.Entry{Message: "retried"}); err != nil {
+		tests.Failed("Should have added entry to batch: %+q", err)
+	}
+
+	if err := consumer.Flush(); err != nil {
+		tests.Failed("Should have committed entry after retries: %+q", err)
+	}
+	tests.Passed("Should have committed entry after retries")
+
+	stats := consumer.Stats()
+	if stats.Retries != 2 || stats.Committed != 1 || stats.Failed != 0 {
+		tests.Failed("Should have counted retries: %#v", stats)
+	}
+	tests.Passed("Should have counted retries")
+}
+
+func TestBatchConsumerRetryKeepsIntake(t *testing.T) {
+	var ml sync.Mutex
+	var committed []metrics.Entry
+	var attempts int
+
+	consumer := metrics.BatchConsumerWith(metrics.BatchConfig{
+		MaxSize:       1,
+		MaxWait:       time.Minute,
+		MaxRetries:    1,
+		Backoff:       100 * time.Millisecond,
+		HandleTimeout: 5 * time.Millisecond,
+	}, func(entries []metrics.Entry) error {
+		ml.Lock()
+		defer ml.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			return errors.New("sink unavailable")
+		}
+
+		committed = append(committed, entries...)
+		return nil
+	})
+
+	closer := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(closer)
+		close(done)
+	}()
+
+	consumer.Handle(metrics.Entry{Message: "first"})
+	time.Sleep(10 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		consumer.Handle(metrics.Entry{Message: "during retry"})
+	}
+
+	close(closer)
+	<-done
+
+	if stats := consumer.Stats(); stats.Dropped != 0 || stats.Queued != 4 {
+		tests.Failed("Should have taken entries in while retrying: %#v", stats)
+	}
+	tests.Passed("Should have taken entries in while retrying")
+
+	if len(committed) != 4 || committed[0].Message != "first" {
+		tests.Failed("Should have committed all entries in order: %+v", committed)
+	}
+	tests.Passed("Should have committed all entries in order")
+}
+
+func TestBatchConsumerFlushWithoutRun(t *testing.T) {
+	consumer := metrics.BatchConsumer(10, time.Minute, func(entries []metrics.Entry) error {
+		return nil
+	})
+
+	if err := consumer.Flush(); err != metrics.ErrBatchEmitterNotRunning {
+		tests.Failed("Should have failed to flush without Run: %+q", err)
+	}
+	tests.Passed("Should have failed to flush without Run")
+
+	closer := make(chan struct{})
+	close(closer)
+
+	consumer.Run(closer)
+	consumer.Run(closer)
+
+	if err := consumer.Flush(); err != metrics.ErrBatchEmitterClosed {
+		tests.Failed("Should have failed to flush after close: %+q", err)
+	}
+	tests.Passed("Should have survived Run being called after close")
+}
@@ -0,0 +1,194 @@
+package encoders
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// ECSVersion defines the version of the Elastic Common Schema written by MarshalECS.
+const ECSVersion = "1.6.0"
+
+// ECS returns the Elastic Common Schema json encoding of the giving Entry as a
+// single line, it can be used as the transform of a custom.Emitter. Entries which
+// fail to encode are written with only their time, level and message.
+func ECS(en metrics.Entry) []byte {
+	data, err := MarshalECS(en)
+	if err != nil {
+		data, _ = MarshalECS(metrics.Entry{
+			ID:      en.ID,
+			Time:    en.Time,
+			Level:   en.Level,
+			Message: en.Message,
+		})
+	}
+
+	return append(data, '\n')
+}
+
+// ecsOrigin defines the log.origin object of an ECS document.
+type ecsOrigin struct {
+	Function string `json:"function,omitempty"`
+	File     struct {
+		Name string `json:"name,omitempty"`
+		Line int    `json:"line,omitempty"`
+	} `json:"file"`
+}
+
+// jsonPair defines a single key-value of a json document written in order.
+type jsonPair struct {
+	key   string
+	value interface{}
+}
+
+// MarshalECS returns the Elastic Common Schema json encoding of the giving Entry.
+//
+// The document keys are written in the order of @timestamp, log.level, message,
+// ecs.version, event.id, event.dataset, tags, log.origin, trace.id, span.id,
+// error.message, metrics.fields, metrics.trace and metrics.timelapse. Keys
+// without a value are left out. The level is written in lower case, the
+// entry ID and Type are mapped to event.id and event.dataset, while the fields,
+// trace and timelapse of the Entry live under the custom metrics namespace.
+func MarshalECS(en metrics.Entry) ([]byte, error) {
+	pairs := []jsonPair{
+		{"@timestamp", formatTime(en.Time)},
+		{"log.level", strings.ToLower(en.Level.String())},
+		{"message", en.Message},
+		{"ecs.version", ECSVersion},
+	}
+
+	if en.ID != "" {
+		pairs = append(pairs, jsonPair{"event.id", en.ID})
+	}
+
+	if en.Type != "" {
+		pairs = append(pairs, jsonPair{"event.dataset", en.Type})
+	}
+
+	if len(en.Tags) != 0 {
+		pairs = append(pairs, jsonPair{"tags", en.Tags})
+	}
+
+	if en.Function != "" || en.File != "" {
+		var origin ecsOrigin
+		origin.Function = en.Function
+		origin.File.Name = en.File
+		origin.File.Line = en.Line
+		pairs = append(pairs, jsonPair{"log.origin", origin})
+	}
+
+	if traceID, ok := en.Field["trace_id"].(string); ok && traceID != "" {
+		pairs = append(pairs, jsonPair{"trace.id", traceID})
+	}
+
+	if spanID, ok := en.Field["span_id"].(string); ok && spanID != "" {
+		pairs = append(pairs, jsonPair{"span.id", spanID})
+	}
+
+	if err, ok := en.Field["error"].(error); ok {
+		pairs = append(pairs, jsonPair{"error.message", err.Error()})
+	}
+
+	if len(en.Field) != 0 {
+		pairs = append(pairs, jsonPair{"metrics.fields", plainField(en.Field)})
+	}
+
+	if hasTrace(en.Trace) {
+		pairs = append(pairs, jsonPair{"metrics.trace", toJSONTrace(en.Trace)})
+	}
+
+	if len(en.Timelapse) != 0 {
+		pairs = append(pairs, jsonPair{"metrics.timelapse", toJSONTimelapses(en.Timelapse)})
+	}
+
+	return writeJSONPairs(pairs)
+}
+
+// UnmarshalECS decodes the giving json document produced by MarshalECS into an Entry.
+func UnmarshalECS(data []byte) (metrics.Entry, error) {
+	var doc struct {
+		Timestamp string          `json:"@timestamp"`
+		Level     string          `json:"log.level"`
+		Message   string          `json:"message"`
+		ID        string          `json:"event.id"`
+		Dataset   string          `json:"event.dataset"`
+		Tags      []string        `json:"tags"`
+		Origin    *ecsOrigin      `json:"log.origin"`
+		Fields    metrics.Field   `json:"metrics.fields"`
+		Trace     *jsonTrace      `json:"metrics.trace"`
+		Timelapse []jsonTimelapse `json:"metrics.timelapse"`
+	}
+
+	var en metrics.Entry
+	if err := decodeJSON(data, &doc); err != nil {
+		return en, err
+	}
+
+	at, err := parseTime(doc.Timestamp)
+	if err != nil {
+		return en, err
+	}
+
+	en.Time = at
+	en.ID = doc.ID
+	en.Type = doc.Dataset
+	en.Tags = doc.Tags
+	en.Message = doc.Message
+	en.Level = levelOf(doc.Level)
+	en.Field = make(metrics.Field, len(doc.Fields))
+
+	for key, value := range doc.Fields {
+		en.Field[key] = normalize(value)
+	}
+
+	if doc.Origin != nil {
+		en.Function = doc.Origin.Function
+		en.File = doc.Origin.File.Name
+		en.Line = doc.Origin.File.Line
+	}
+
+	if doc.Trace != nil {
+		if en.Trace, err = fromJSONTrace(*doc.Trace); err != nil {
+			return en, err
+		}
+	}
+
+	if len(doc.Timelapse) != 0 {
+		if en.Timelapse, err = fromJSONTimelapses(doc.Timelapse); err != nil {
+			return en, err
+		}
+	}
+
+	return en, nil
+}
+
+// writeJSONPairs writes the giving pairs as a json object in their order.
+func writeJSONPairs(pairs []jsonPair) ([]byte, error) {
+	var bu bytes.Buffer
+	bu.WriteByte('{')
+
+	for index, pair := range pairs {
+		if index > 0 {
+			bu.WriteByte(',')
+		}
+
+		key, err := json.Marshal(pair.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(pair.value)
+		if err != nil {
+			return nil, err
+		}
+
+		bu.Write(key)
+		bu.WriteByte(':')
+		bu.Write(value)
+	}
+
+	bu.WriteByte('}')
+	return bu.Bytes(), nil
+}
@@ -0,0 +1,256 @@
+// Package encoders provides standard wire encodings of metrics.Entry, each with a
+// matching decoder. All encoders write fields in a deterministic order and expose
+// a transform function usable with custom.NewEmitter.
+package encoders
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// errors.
+var (
+	ErrInvalidEntry = errors.New("invalid encoded entry")
+)
+
+// InvalidEntryError defines the error returned by decoders for malformed
+// encodings, which unwraps to ErrInvalidEntry.
+type InvalidEntryError struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (e InvalidEntryError) Error() string {
+	return ErrInvalidEntry.Error() + ": " + e.Reason
+}
+
+// Unwrap returns ErrInvalidEntry.
+func (e InvalidEntryError) Unwrap() error {
+	return ErrInvalidEntry
+}
+
+// invalidEntry returns a InvalidEntryError with the formatted reason.
+func invalidEntry(format string, args ...interface{}) error {
+	return InvalidEntryError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// sortedKeys returns the keys of the giving Field in sorted order.
+func sortedKeys(f metrics.Field) []string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// plainValue returns a json friendly form of the giving value, where errors,
+// times and durations are turned into strings.
+func plainValue(value interface{}) interface{} {
+	switch item := value.(type) {
+	case error:
+		return item.Error()
+	case time.Time:
+		return item.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return item.String()
+	case metrics.Field:
+		return plainField(item)
+	case map[string]interface{}:
+		return map[string]interface{}(plainField(metrics.Field(item)))
+	case []interface{}:
+		values := make([]interface{}, len(item))
+		for index, elem := range item {
+			values[index] = plainValue(elem)
+		}
+		return values
+	}
+
+	return value
+}
+
+// plainField returns a copy of the giving Field with all values turned into
+// their json friendly forms.
+func plainField(f metrics.Field) metrics.Field {
+	if f == nil {
+		return nil
+	}
+
+	copy := make(metrics.Field, len(f))
+	for key, value := range f {
+		copy[key] = plainValue(value)
+	}
+
+	return copy
+}
+
+// jsonString returns the json encoding of the giving value as a string, falling
+// back to its go syntax representation.
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(plainValue(value))
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+
+	return string(data)
+}
+
+// decodeJSON decodes the giving data into the target, keeping numbers
+// as ints when they have no fraction.
+func decodeJSON(data []byte, target interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// normalize replaces all json.Number values within the giving value with
+// an int or float64.
+func normalize(value interface{}) interface{} {
+	switch item := value.(type) {
+	case json.Number:
+		if val, err := item.Int64(); err == nil {
+			return int(val)
+		}
+
+		val, _ := item.Float64()
+		return val
+	case map[string]interface{}:
+		for key, elem := range item {
+			item[key] = normalize(elem)
+		}
+		return item
+	case []interface{}:
+		for index, elem := range item {
+			item[index] = normalize(elem)
+		}
+		return item
+	}
+
+	return value
+}
+
+// parseTime parses a RFC3339Nano timestamp, returning the zero time for an empty value.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// formatTime formats the giving time as RFC3339Nano in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// levelOf returns the Level for the giving name, defaulting to InfoLvl for
+// unknown names.
+func levelOf(name string) metrics.Level {
+	if lvl := metrics.GetLevel(name); lvl >= 0 {
+		return lvl
+	}
+
+	return metrics.InfoLvl
+}
+
+// jsonTrace defines the json representation of a metrics.Trace used by encoders.
+type jsonTrace struct {
+	Package  string   `json:"package,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Function string   `json:"function,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Time     string   `json:"time,omitempty"`
+	Comments []string `json:"comments,omitempty"`
+	Stack    string   `json:"stack,omitempty"`
+}
+
+// hasTrace returns true/false if the giving trace carries any detail.
+func hasTrace(t metrics.Trace) bool {
+	return t.Function != "" || t.File != "" || t.Package != "" || len(t.Stack) != 0 || len(t.Comments) != 0
+}
+
+func toJSONTrace(t metrics.Trace) jsonTrace {
+	trace := jsonTrace{
+		Package:  t.Package,
+		File:     t.File,
+		Function: t.Function,
+		Line:     t.LineNumber,
+		Comments: t.Comments,
+		Stack:    string(t.Stack),
+	}
+
+	if !t.Time.IsZero() {
+		trace.Time = formatTime(t.Time)
+	}
+
+	return trace
+}
+
+func fromJSONTrace(t jsonTrace) (metrics.Trace, error) {
+	at, err := parseTime(t.Time)
+	if err != nil {
+		return metrics.Trace{}, err
+	}
+
+	trace := metrics.Trace{
+		Package:    t.Package,
+		File:       t.File,
+		Function:   t.Function,
+		LineNumber: t.Line,
+		Comments:   t.Comments,
+		Time:       at,
+	}
+
+	if t.Stack != "" {
+		trace.Stack = []byte(t.Stack)
+	}
+
+	return trace, nil
+}
+
+// jsonTimelapse defines the json representation of a metrics.Timelapse used by encoders.
+type jsonTimelapse struct {
+	Message string        `json:"message"`
+	Time    string        `json:"time"`
+	Fields  metrics.Field `json:"fields,omitempty"`
+}
+
+func toJSONTimelapses(lapses []metrics.Timelapse) []jsonTimelapse {
+	items := make([]jsonTimelapse, 0, len(lapses))
+	for _, lapse := range lapses {
+		items = append(items, jsonTimelapse{
+			Message: lapse.Message,
+			Time:    formatTime(lapse.Time),
+			Fields:  plainField(lapse.Field),
+		})
+	}
+	return items
+}
+
+func fromJSONTimelapses(items []jsonTimelapse) ([]metrics.Timelapse, error) {
+	lapses := make([]metrics.Timelapse, 0, len(items))
+	for _, item := range items {
+		at, err := parseTime(item.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range item.Fields {
+			item.Fields[key] = normalize(value)
+		}
+
+		lapses = append(lapses, metrics.Timelapse{
+			Message: item.Message,
+			Time:    at,
+			Field:   item.Fields,
+		})
+	}
+	return lapses, nil
+}
@@ -0,0 +1,243 @@
+package encoders_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/custom"
+	"github.com/influx6/faux/metrics/encoders"
+	"github.com/influx6/faux/tests"
+)
+
+func sampleEntry() metrics.Entry {
+	at := time.Date(2017, 6, 12, 10, 30, 15, 123456000, time.UTC)
+
+	return metrics.Entry{
+		ID:       "db:query",
+		Type:     "sql",
+		Level:    metrics.ErrorLvl,
+		Message:  "query failed with \"timeout\"\nretrying",
+		Function: "github.com/influx6/faux/db.Query",
+		File:     "query.go",
+		Line:     42,
+		Time:     at,
+		Tags:     []string{"db", "retry"},
+		Field: metrics.Field{
+			"error":    errors.New("i/o timeout"),
+			"attempts": 3,
+			"ratio":    2.5,
+			"cached":   false,
+			"table":    "users",
+			"level":    "clash",
+			"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		Trace: metrics.Trace{
+			Package:    "github.com/influx6/faux/db",
+			File:       "query.go",
+			Function:   "Query",
+			LineNumber: 40,
+			Time:       at,
+			Comments:   []string{"slow path"},
+			Stack:      []byte("goroutine 1 [running]:\nmain.main()"),
+		},
+		Timelapse: []metrics.Timelapse{
+			{Message: "connect", Time: at, Field: metrics.Field{"host": "db1", "port": 5432}},
+			{Message: "execute", Time: at.Add(time.Second)},
+		},
+	}
+}
+
+// expected returns the sample entry as it should come back from the decoders.
+func expected() metrics.Entry {
+	en := sampleEntry()
+	en.Field["error"] = "i/o timeout"
+	en.Timelapse[1].Field = nil
+	return en
+}
+
+func checkEntry(t *testing.T, format string, got metrics.Entry) {
+	want := expected()
+
+	if got.ID != want.ID || got.Type != want.Type || got.Level != want.Level || got.Message != want.Message {
+		tests.Failed("Should have decoded %s entry details: %#v", format, got)
+	}
+	tests.Passed("Should have decoded %s entry details", format)
+
+	if got.Function != want.Function || got.File != want.File || got.Line != want.Line || !got.Time.Equal(want.Time) {
+		tests.Failed("Should have decoded %s entry origin and time: %#v", format, got)
+	}
+	tests.Passed("Should have decoded %s entry origin and time", format)
+
+	if !reflect.DeepEqual(got.Tags, want.Tags) {
+		tests.Failed("Should have decoded %s entry tags: %#v", format, got.Tags)
+	}
+	tests.Passed("Should have decoded %s entry tags", format)
+
+	if !reflect.DeepEqual(got.Field, want.Field) {
+		tests.Failed("Should have decoded %s entry fields: %#v", format, got.Field)
+	}
+	tests.Passed("Should have decoded %s entry fields", format)
+
+	if got.Trace.Function != want.Trace.Function || got.Trace.LineNumber != want.Trace.LineNumber || string(got.Trace.Stack) != string(want.Trace.Stack) || !reflect.DeepEqual(got.Trace.Comments, want.Trace.Comments) {
+		tests.Failed("Should have decoded %s entry trace: %#v", format, got.Trace)
+	}
+	tests.Passed("Should have decoded %s entry trace", format)
+
+	if len(got.Timelapse) != 2 || got.Timelapse[0].Message != "connect" || !got.Timelapse[1].Time.Equal(want.Timelapse[1].Time) || !reflect.DeepEqual(got.Timelapse[0].Field, want.Timelapse[0].Field) {
+		tests.Failed("Should have decoded %s entry timelapse: %#v", format, got.Timelapse)
+	}
+	tests.Passed("Should have decoded %s entry timelapse", format)
+}
+
+func TestLogFmt(t *testing.T) {
+	data := encoders.MarshalLogFmt(sampleEntry())
+
+	if !bytes.Equal(data, encoders.MarshalLogFmt(sampleEntry())) {
+		tests.Failed("Should have produced deterministic logfmt output")
+	}
+	tests.Passed("Should have produced deterministic logfmt output")
+
+	if !bytes.HasPrefix(data, []byte(`time=2017-06-12T10:30:15.123456Z level=ERROR id=db:query type=sql message="query failed with \"timeout\"\nretrying"`)) {
+		tests.Failed("Should have written fixed keys first: %s", data)
+	}
+	tests.Passed("Should have written fixed keys first")
+
+	if !bytes.Contains(data, []byte(`field.level="clash"`)) || !bytes.Contains(data, []byte(`attempts=3 cached=false`)) {
+		tests.Failed("Should have prefixed clashing keys and written bare values: %s", data)
+	}
+	tests.Passed("Should have prefixed clashing keys and written bare values")
+
+	whole, err := encoders.UnmarshalLogFmt(encoders.MarshalLogFmt(metrics.Entry{Field: metrics.Field{"ratio": 2.0}}))
+	if err != nil || whole.Field["ratio"] != 2.0 {
+		tests.Failed("Should have kept whole float as float: %#v", whole.Field)
+	}
+	tests.Passed("Should have kept whole float as float")
+
+	en, err := encoders.UnmarshalLogFmt(data)
+	if err != nil {
+		tests.Failed("Should have decoded logfmt line: %+q", err)
+	}
+	tests.Passed("Should have decoded logfmt line")
+
+	checkEntry(t, "logfmt", en)
+}
+
+func TestECS(t *testing.T) {
+	data, err := encoders.MarshalECS(sampleEntry())
+	if err != nil {
+		tests.Failed("Should have encoded ECS document: %+q", err)
+	}
+	tests.Passed("Should have encoded ECS document")
+
+	if !bytes.HasPrefix(data, []byte(`{"@timestamp":"2017-06-12T10:30:15.123456Z","log.level":"error","message":`)) {
+		tests.Failed("Should have written ECS base fields first: %s", data)
+	}
+	tests.Passed("Should have written ECS base fields first")
+
+	if !bytes.Contains(data, []byte(`"trace.id":"4bf92f3577b34da6a3ce929d0e0e4736"`)) || !bytes.Contains(data, []byte(`"error.message":"i/o timeout"`)) {
+		tests.Failed("Should have mapped trace and error fields: %s", data)
+	}
+	tests.Passed("Should have mapped trace and error fields")
+
+	en, err := encoders.UnmarshalECS(data)
+	if err != nil {
+		tests.Failed("Should have decoded ECS document: %+q", err)
+	}
+	tests.Passed("Should have decoded ECS document")
+
+	checkEntry(t, "ECS", en)
+}
+
+func TestGELF(t *testing.T) {
+	data, err := encoders.MarshalGELF("box-1", sampleEntry())
+	if err != nil {
+		tests.Failed("Should have encoded GELF payload: %+q", err)
+	}
+	tests.Passed("Should have encoded GELF payload")
+
+	if !bytes.Contains(data, []byte(`"timestamp":1497263415.123456,"level":3`)) {
+		tests.Failed("Should have written GELF timestamp and level: %s", data)
+	}
+	tests.Passed("Should have written GELF timestamp and level")
+
+	if !bytes.Contains(data, []byte(`"_cached":"false"`)) || !bytes.Contains(data, []byte(`"_tags":"db,retry"`)) {
+		tests.Failed("Should have written GELF fields as strings and numbers: %s", data)
+	}
+	tests.Passed("Should have written GELF fields as strings and numbers")
+
+	en, err := encoders.UnmarshalGELF(data)
+	if err != nil {
+		tests.Failed("Should have decoded GELF payload: %+q", err)
+	}
+	tests.Passed("Should have decoded GELF payload")
+
+	// GELF turns booleans into strings.
+	if en.Field["cached"] != "false" {
+		tests.Failed("Should have decoded boolean as string: %#v", en.Field["cached"])
+	}
+	en.Field["cached"] = false
+
+	checkEntry(t, "GELF", en)
+}
+
+func TestEmitter(t *testing.T) {
+	var bu bytes.Buffer
+
+	emitter := custom.NewEmitter(&bu, encoders.LogFmt)
+	metrics.New(emitter).Emit(metrics.Info("started"), metrics.With("port", 8080))
+
+	line := bu.String()
+	if !strings.HasSuffix(line, " port=8080\n") || !strings.Contains(line, " message=started ") {
+		tests.Failed("Should have written logfmt line through emitter: %q", line)
+	}
+	tests.Passed("Should have written logfmt line through emitter")
+}
+
+func TestGELFEscapedTags(t *testing.T) {
+	en := sampleEntry()
+	en.Tags = []string{"region=eu,west", `path\to`, ""}
+	en.Trace.Comments = []string{"first\nline", "second"}
+
+	data, err := encoders.MarshalGELF("box-1", en)
+	if err != nil {
+		tests.Failed("Should have encoded GELF payload: %+q", err)
+	}
+
+	if !bytes.Contains(data, []byte(`"_tags":"region=eu\\,west,path\\\\to,"`)) {
+		tests.Failed("Should have escaped commas within tags: %s", data)
+	}
+	tests.Passed("Should have escaped commas within tags")
+
+	decoded, err := encoders.UnmarshalGELF(data)
+	if err != nil {
+		tests.Failed("Should have decoded GELF payload: %+q", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Tags, en.Tags) || !reflect.DeepEqual(decoded.Trace.Comments, en.Trace.Comments) {
+		tests.Failed("Should have recovered tags and comments: %#v %#v", decoded.Tags, decoded.Trace.Comments)
+	}
+	tests.Passed("Should have recovered tags and comments")
+}
+
+func TestInvalidEntryError(t *testing.T) {
+	_, err := encoders.UnmarshalLogFmt([]byte(`msg="unterminated`))
+	if !errors.Is(err, encoders.ErrInvalidEntry) {
+		tests.Failed("Should have matched ErrInvalidEntry: %+q", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), encoders.ErrInvalidEntry.Error()+": ") {
+		tests.Failed("Should have described invalid entry: %+q", err)
+	}
+	tests.Passed("Should have matched ErrInvalidEntry")
+
+	_, err = encoders.UnmarshalGELF([]byte(`{"version":"1.1","timestamp":"soon"}`))
+	if !errors.Is(err, encoders.ErrInvalidEntry) {
+		tests.Failed("Should have matched ErrInvalidEntry for GELF: %+q", err)
+	}
+	tests.Passed("Should have matched ErrInvalidEntry for GELF")
+}
@@ -0,0 +1,355 @@
+package encoders
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// GELFVersion defines the version of the GELF payload written by MarshalGELF.
+const GELFVersion = "1.1"
+
+// gelf additional field keys used for the fixed parts of an Entry.
+const (
+	gelfID             = "_entry_id"
+	gelfType           = "_entry_type"
+	gelfFunction       = "_function"
+	gelfFile           = "_file"
+	gelfLine           = "_line"
+	gelfTags           = "_tags"
+	gelfTracePackage   = "_trace_package"
+	gelfTraceFile      = "_trace_file"
+	gelfTraceFunction  = "_trace_function"
+	gelfTraceLine      = "_trace_line"
+	gelfTraceTime      = "_trace_time"
+	gelfTraceComments  = "_trace_comments"
+	gelfTimelapse      = "_timelapse"
+	gelfFieldPrefix    = "_field_"
+	gelfTagsSeparator  = ','
+	gelfTraceSeparator = '\n'
+)
+
+var gelfKeyFilter = regexp.MustCompile(`[^\w\.\-]`)
+
+// GELF returns a function which returns the GELF encoding of an Entry for the
+// giving host as a single line, it can be used as the transform of a custom.Emitter.
+// Entries which fail to encode are written with only their time, level and message.
+func GELF(host string) func(metrics.Entry) []byte {
+	return func(en metrics.Entry) []byte {
+		data, err := MarshalGELF(host, en)
+		if err != nil {
+			data, _ = MarshalGELF(host, metrics.Entry{
+				ID:      en.ID,
+				Time:    en.Time,
+				Level:   en.Level,
+				Message: en.Message,
+			})
+		}
+
+		return append(data, '\n')
+	}
+}
+
+// GELFLevel returns the syslog severity used by GELF for the giving Level.
+func GELFLevel(lvl metrics.Level) int {
+	switch lvl {
+	case metrics.RedAlertLvl:
+		return 1
+	case metrics.YellowAlertLvl:
+		return 2
+	case metrics.ErrorLvl:
+		return 3
+	}
+
+	return 6
+}
+
+// levelOfGELF returns the Level for the giving syslog severity.
+func levelOfGELF(severity int) metrics.Level {
+	switch {
+	case severity <= 1:
+		return metrics.RedAlertLvl
+	case severity == 2:
+		return metrics.YellowAlertLvl
+	case severity == 3:
+		return metrics.ErrorLvl
+	}
+
+	return metrics.InfoLvl
+}
+
+// MarshalGELF returns the GELF 1.1 encoding of the giving Entry for the host.
+//
+// The payload keys are written in the order of version, host, short_message,
+// full_message, timestamp and level, followed by the entry details as the
+// additional fields _entry_id, _entry_type, _function, _file, _line, _tags,
+// the fields sorted by key, the trace details prefixed with _trace_ and the
+// json encoded _timelapse. As GELF only allows strings and numbers as field
+// values, tags are joined by commas with commas and backslashes within them
+// escaped by a backslash, while booleans and all other values are
+// written as strings or json. Field keys which clash with the entry details
+// are prefixed with _field_. The full_message carries the trace stack if any.
+func MarshalGELF(host string, en metrics.Entry) ([]byte, error) {
+	pairs := []jsonPair{
+		{"version", GELFVersion},
+		{"host", host},
+		{"short_message", en.Message},
+	}
+
+	if len(en.Trace.Stack) != 0 {
+		pairs = append(pairs, jsonPair{"full_message", string(en.Trace.Stack)})
+	}
+
+	pairs = append(pairs,
+		jsonPair{"timestamp", json.Number(fmt.Sprintf("%d.%06d", en.Time.Unix(), en.Time.Nanosecond()/int(time.Microsecond)))},
+		jsonPair{"level", GELFLevel(en.Level)},
+	)
+
+	if en.ID != "" {
+		pairs = append(pairs, jsonPair{gelfID, en.ID})
+	}
+
+	if en.Type != "" {
+		pairs = append(pairs, jsonPair{gelfType, en.Type})
+	}
+
+	if en.Function != "" {
+		pairs = append(pairs, jsonPair{gelfFunction, en.Function})
+	}
+
+	if en.File != "" {
+		pairs = append(pairs, jsonPair{gelfFile, en.File})
+		pairs = append(pairs, jsonPair{gelfLine, en.Line})
+	}
+
+	if len(en.Tags) != 0 {
+		pairs = append(pairs, jsonPair{gelfTags, joinEscaped(en.Tags, gelfTagsSeparator)})
+	}
+
+	for _, key := range sortedKeys(en.Field) {
+		pairs = append(pairs, jsonPair{gelfFieldKey(key), gelfValue(en.Field[key])})
+	}
+
+	if hasTrace(en.Trace) {
+		trace := en.Trace
+		pairs = append(pairs,
+			jsonPair{gelfTracePackage, trace.Package},
+			jsonPair{gelfTraceFile, trace.File},
+			jsonPair{gelfTraceFunction, trace.Function},
+			jsonPair{gelfTraceLine, trace.LineNumber},
+		)
+
+		if !trace.Time.IsZero() {
+			pairs = append(pairs, jsonPair{gelfTraceTime, formatTime(trace.Time)})
+		}
+
+		if len(trace.Comments) != 0 {
+			pairs = append(pairs, jsonPair{gelfTraceComments, joinEscaped(trace.Comments, gelfTraceSeparator)})
+		}
+	}
+
+	if len(en.Timelapse) != 0 {
+		pairs = append(pairs, jsonPair{gelfTimelapse, jsonString(toJSONTimelapses(en.Timelapse))})
+	}
+
+	return writeJSONPairs(pairs)
+}
+
+// UnmarshalGELF decodes the giving GELF payload produced by MarshalGELF into an
+// Entry. Additional fields which are not entry details are added to its fields.
+func UnmarshalGELF(data []byte) (metrics.Entry, error) {
+	var en metrics.Entry
+
+	var doc map[string]interface{}
+	if err := decodeJSON(data, &doc); err != nil {
+		return en, err
+	}
+
+	en.Field = make(metrics.Field)
+
+	for key, raw := range doc {
+		value := normalize(raw)
+		text, _ := value.(string)
+
+		var err error
+		switch key {
+		case "version", "host":
+		case "short_message":
+			en.Message = text
+		case "full_message":
+			en.Trace.Stack = []byte(text)
+		case "timestamp":
+			number, ok := raw.(json.Number)
+			if !ok {
+				return en, invalidEntry("bad timestamp %v", raw)
+			}
+
+			en.Time, err = parseGELFTimestamp(string(number))
+		case "level":
+			severity, _ := value.(int)
+			en.Level = levelOfGELF(severity)
+		case gelfID:
+			en.ID = text
+		case gelfType:
+			en.Type = text
+		case gelfFunction:
+			en.Function = text
+		case gelfFile:
+			en.File = text
+		case gelfLine:
+			en.Line, _ = value.(int)
+		case gelfTags:
+			if text != "" {
+				en.Tags = splitEscaped(text, gelfTagsSeparator)
+			}
+		case gelfTracePackage:
+			en.Trace.Package = text
+		case gelfTraceFile:
+			en.Trace.File = text
+		case gelfTraceFunction:
+			en.Trace.Function = text
+		case gelfTraceLine:
+			en.Trace.LineNumber, _ = value.(int)
+		case gelfTraceTime:
+			en.Trace.Time, err = parseTime(text)
+		case gelfTraceComments:
+			en.Trace.Comments = splitEscaped(text, gelfTraceSeparator)
+		case gelfTimelapse:
+			var items []jsonTimelapse
+			if err = decodeJSON([]byte(text), &items); err == nil {
+				en.Timelapse, err = fromJSONTimelapses(items)
+			}
+		default:
+			if strings.HasPrefix(key, gelfFieldPrefix) {
+				en.Field[strings.TrimPrefix(key, gelfFieldPrefix)] = value
+			} else if strings.HasPrefix(key, "_") {
+				en.Field[strings.TrimPrefix(key, "_")] = value
+			}
+		}
+
+		if err != nil {
+			return en, err
+		}
+	}
+
+	return en, nil
+}
+
+// parseGELFTimestamp parses the giving seconds since the epoch with an optional
+// fraction, without the precision loss of a float.
+func parseGELFTimestamp(value string) (time.Time, error) {
+	parts := strings.SplitN(value, ".", 2)
+
+	secs, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, invalidEntry("bad timestamp %q", value)
+	}
+
+	var nanos int64
+	if len(parts) == 2 {
+		frac := (parts[1] + "000000000")[:9]
+		if nanos, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, invalidEntry("bad timestamp %q", value)
+		}
+	}
+
+	return time.Unix(secs, nanos).UTC(), nil
+}
+
+// gelfFieldKey returns the additional field key used for the giving field key,
+// prefixing it when it clashes with the entry details.
+func gelfFieldKey(key string) string {
+	key = "_" + gelfKeyFilter.ReplaceAllString(key, "_")
+
+	switch key {
+	case "_id", gelfID, gelfType, gelfFunction, gelfFile, gelfLine, gelfTags, gelfTimelapse:
+		return gelfFieldPrefix + key[1:]
+	}
+
+	if strings.HasPrefix(key, "_trace_") || strings.HasPrefix(key, gelfFieldPrefix) {
+		return gelfFieldPrefix + key[1:]
+	}
+
+	return key
+}
+
+// gelfValue returns the GELF form of a field value, which must be a string or number.
+func gelfValue(value interface{}) interface{} {
+	switch item := value.(type) {
+	case string:
+		return item
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return item
+	case float32:
+		if math.IsInf(float64(item), 0) || math.IsNaN(float64(item)) {
+			return fmt.Sprint(item)
+		}
+		return item
+	case float64:
+		if math.IsInf(item, 0) || math.IsNaN(item) {
+			return fmt.Sprint(item)
+		}
+		return item
+	case nil:
+		return ""
+	case bool:
+		return fmt.Sprint(item)
+	case error:
+		return item.Error()
+	case time.Time:
+		return formatTime(item)
+	case time.Duration:
+		return item.String()
+	case fmt.Stringer:
+		return item.String()
+	}
+
+	return jsonString(value)
+}
+
+// joinEscaped joins the giving items by the separator, escaping the separator
+// and backslashes within items by a backslash so splitEscaped recovers them.
+func joinEscaped(items []string, sep byte) string {
+	var out []byte
+	for index, item := range items {
+		if index > 0 {
+			out = append(out, sep)
+		}
+
+		for i := 0; i < len(item); i++ {
+			if item[i] == sep || item[i] == '\\' {
+				out = append(out, '\\')
+			}
+			out = append(out, item[i])
+		}
+	}
+
+	return string(out)
+}
+
+// splitEscaped splits the giving text joined by joinEscaped.
+func splitEscaped(text string, sep byte) []string {
+	var items []string
+	var item []byte
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text):
+			i++
+			item = append(item, text[i])
+		case text[i] == sep:
+			items = append(items, string(item))
+			item = item[:0]
+		default:
+			item = append(item, text[i])
+		}
+	}
+
+	return append(items, string(item))
+}
@@ -0,0 +1,443 @@
+package encoders
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// logfmt keys used for the fixed parts of an Entry.
+const (
+	logfmtTime      = "time"
+	logfmtLevel     = "level"
+	logfmtID        = "id"
+	logfmtType      = "type"
+	logfmtMessage   = "message"
+	logfmtFunction  = "function"
+	logfmtFile      = "file"
+	logfmtLine      = "line"
+	logfmtTag       = "tag"
+	logfmtField     = "field."
+	logfmtTrace     = "trace."
+	logfmtTimelapse = "timelapse."
+)
+
+// LogFmt returns the logfmt encoding of the giving Entry as a single line,
+// it can be used as the transform of a custom.Emitter.
+func LogFmt(en metrics.Entry) []byte {
+	return append(MarshalLogFmt(en), '\n')
+}
+
+// MarshalLogFmt returns the logfmt encoding of the giving Entry.
+//
+// The fixed parts of the Entry are written first in the order of time, level, id,
+// type, message, function, file and line, followed by a tag pair for each tag,
+// the fields sorted by key, the trace details prefixed with "trace." and each
+// timelapse prefixed with "timelapse.<index>.". Field keys which clash with
+// these are prefixed with "field.".
+//
+// Field values which are strings are always quoted, while numbers, booleans and
+// nil are written bare, which allows UnmarshalLogFmt to recover their types.
+// All other values are written as quoted json.
+func MarshalLogFmt(en metrics.Entry) []byte {
+	var bu bytes.Buffer
+
+	writeLogFmtPair(&bu, logfmtTime, formatTime(en.Time))
+	writeLogFmtPair(&bu, logfmtLevel, en.Level.String())
+	writeLogFmtPair(&bu, logfmtID, en.ID)
+	writeLogFmtPair(&bu, logfmtType, en.Type)
+	writeLogFmtPair(&bu, logfmtMessage, en.Message)
+	writeLogFmtPair(&bu, logfmtFunction, en.Function)
+	writeLogFmtPair(&bu, logfmtFile, en.File)
+	writeLogFmtPair(&bu, logfmtLine, strconv.Itoa(en.Line))
+
+	for _, tag := range en.Tags {
+		writeLogFmtPair(&bu, logfmtTag, tag)
+	}
+
+	for _, key := range sortedKeys(en.Field) {
+		writeLogFmtRaw(&bu, logfmtFieldKey(key), logfmtValue(en.Field[key]))
+	}
+
+	if hasTrace(en.Trace) {
+		trace := en.Trace
+		writeLogFmtPair(&bu, logfmtTrace+"package", trace.Package)
+		writeLogFmtPair(&bu, logfmtTrace+"file", trace.File)
+		writeLogFmtPair(&bu, logfmtTrace+"function", trace.Function)
+		writeLogFmtPair(&bu, logfmtTrace+"line", strconv.Itoa(trace.LineNumber))
+
+		if !trace.Time.IsZero() {
+			writeLogFmtPair(&bu, logfmtTrace+"time", formatTime(trace.Time))
+		}
+
+		for _, comment := range trace.Comments {
+			writeLogFmtPair(&bu, logfmtTrace+"comment", comment)
+		}
+
+		if len(trace.Stack) != 0 {
+			writeLogFmtPair(&bu, logfmtTrace+"stack", string(trace.Stack))
+		}
+	}
+
+	for index, lapse := range en.Timelapse {
+		prefix := logfmtTimelapse + strconv.Itoa(index) + "."
+		writeLogFmtPair(&bu, prefix+"message", lapse.Message)
+		writeLogFmtPair(&bu, prefix+"time", formatTime(lapse.Time))
+
+		for _, key := range sortedKeys(lapse.Field) {
+			writeLogFmtRaw(&bu, prefix+"fields."+logfmtKey(key), logfmtValue(lapse.Field[key]))
+		}
+	}
+
+	return bu.Bytes()
+}
+
+// UnmarshalLogFmt decodes the giving logfmt line produced by MarshalLogFmt
+// into an Entry. Unknown keys are added to the fields of the Entry.
+func UnmarshalLogFmt(data []byte) (metrics.Entry, error) {
+	var en metrics.Entry
+	en.Field = make(metrics.Field)
+
+	pairs, err := parseLogFmt(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return en, err
+	}
+
+	lapses := map[int]*metrics.Timelapse{}
+
+	for _, pair := range pairs {
+		switch {
+		case pair.key == logfmtTime:
+			if en.Time, err = parseTime(pair.value); err != nil {
+				return en, err
+			}
+		case pair.key == logfmtLevel:
+			en.Level = levelOf(pair.value)
+		case pair.key == logfmtID:
+			en.ID = pair.value
+		case pair.key == logfmtType:
+			en.Type = pair.value
+		case pair.key == logfmtMessage:
+			en.Message = pair.value
+		case pair.key == logfmtFunction:
+			en.Function = pair.value
+		case pair.key == logfmtFile:
+			en.File = pair.value
+		case pair.key == logfmtLine:
+			if en.Line, err = strconv.Atoi(pair.value); err != nil {
+				return en, err
+			}
+		case pair.key == logfmtTag:
+			en.Tags = append(en.Tags, pair.value)
+		case strings.HasPrefix(pair.key, logfmtField):
+			en.Field[strings.TrimPrefix(pair.key, logfmtField)] = pair.typed()
+		case strings.HasPrefix(pair.key, logfmtTrace):
+			if err := setLogFmtTrace(&en.Trace, strings.TrimPrefix(pair.key, logfmtTrace), pair.value); err != nil {
+				return en, err
+			}
+		case strings.HasPrefix(pair.key, logfmtTimelapse):
+			if err := setLogFmtTimelapse(lapses, strings.TrimPrefix(pair.key, logfmtTimelapse), pair); err != nil {
+				return en, err
+			}
+		default:
+			en.Field[pair.key] = pair.typed()
+		}
+	}
+
+	if len(lapses) != 0 {
+		indexes := make([]int, 0, len(lapses))
+		for index := range lapses {
+			indexes = append(indexes, index)
+		}
+
+		sort.Ints(indexes)
+
+		for _, index := range indexes {
+			en.Timelapse = append(en.Timelapse, *lapses[index])
+		}
+	}
+
+	return en, nil
+}
+
+func setLogFmtTrace(trace *metrics.Trace, key string, value string) error {
+	var err error
+
+	switch key {
+	case "package":
+		trace.Package = value
+	case "file":
+		trace.File = value
+	case "function":
+		trace.Function = value
+	case "line":
+		trace.LineNumber, err = strconv.Atoi(value)
+	case "time":
+		trace.Time, err = parseTime(value)
+	case "comment":
+		trace.Comments = append(trace.Comments, value)
+	case "stack":
+		trace.Stack = []byte(value)
+	}
+
+	return err
+}
+
+func setLogFmtTimelapse(lapses map[int]*metrics.Timelapse, key string, pair logfmtPair) error {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return invalidEntry("bad timelapse key %q", pair.key)
+	}
+
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return invalidEntry("bad timelapse key %q", pair.key)
+	}
+
+	lapse, ok := lapses[index]
+	if !ok {
+		lapse = &metrics.Timelapse{}
+		lapses[index] = lapse
+	}
+
+	switch {
+	case parts[1] == "message":
+		lapse.Message = pair.value
+	case parts[1] == "time":
+		lapse.Time, err = parseTime(pair.value)
+	case strings.HasPrefix(parts[1], "fields."):
+		if lapse.Field == nil {
+			lapse.Field = make(metrics.Field)
+		}
+		lapse.Field[strings.TrimPrefix(parts[1], "fields.")] = pair.typed()
+	}
+
+	return err
+}
+
+// logfmtFieldKey returns the key used for the giving field key, prefixing it
+// when it clashes with the fixed keys of an Entry.
+func logfmtFieldKey(key string) string {
+	key = logfmtKey(key)
+
+	switch key {
+	case logfmtTime, logfmtLevel, logfmtID, logfmtType, logfmtMessage, logfmtFunction, logfmtFile, logfmtLine, logfmtTag:
+		return logfmtField + key
+	}
+
+	if strings.HasPrefix(key, logfmtField) || strings.HasPrefix(key, logfmtTrace) || strings.HasPrefix(key, logfmtTimelapse) {
+		return logfmtField + key
+	}
+
+	return key
+}
+
+// logfmtKey replaces all characters not allowed within a logfmt key with underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue returns the logfmt form of a field value.
+func logfmtValue(value interface{}) string {
+	switch item := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(item)
+	case bool:
+		return strconv.FormatBool(item)
+	case int:
+		return strconv.FormatInt(int64(item), 10)
+	case int8:
+		return strconv.FormatInt(int64(item), 10)
+	case int16:
+		return strconv.FormatInt(int64(item), 10)
+	case int32:
+		return strconv.FormatInt(int64(item), 10)
+	case int64:
+		return strconv.FormatInt(item, 10)
+	case uint:
+		return strconv.FormatUint(uint64(item), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(item), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(item), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(item), 10)
+	case uint64:
+		return strconv.FormatUint(item, 10)
+	case float32:
+		return logfmtFloat(float64(item), 32)
+	case float64:
+		return logfmtFloat(item, 64)
+	case error:
+		return strconv.Quote(item.Error())
+	case time.Time:
+		return strconv.Quote(formatTime(item))
+	case time.Duration:
+		return strconv.Quote(item.String())
+	case fmt.Stringer:
+		return strconv.Quote(item.String())
+	}
+
+	return strconv.Quote(jsonString(value))
+}
+
+// logfmtFloat formats the giving float so it is never mistaken for an integer.
+func logfmtFloat(value float64, bitSize int) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return strconv.Quote(strconv.FormatFloat(value, 'g', -1, bitSize))
+	}
+
+	formatted := strconv.FormatFloat(value, 'g', -1, bitSize)
+	if !strings.ContainsAny(formatted, ".e") {
+		formatted += ".0"
+	}
+
+	return formatted
+}
+
+// writeLogFmtPair writes the giving key and string value, quoting the value if needed.
+func writeLogFmtPair(bu *bytes.Buffer, key string, value string) {
+	if needsQuote(value) {
+		value = strconv.Quote(value)
+	}
+
+	writeLogFmtRaw(bu, key, value)
+}
+
+func writeLogFmtRaw(bu *bytes.Buffer, key string, value string) {
+	if bu.Len() != 0 {
+		bu.WriteByte(' ')
+	}
+
+	bu.WriteString(key)
+	bu.WriteByte('=')
+	bu.WriteString(value)
+}
+
+// needsQuote returns true/false if the giving value must be quoted in logfmt.
+func needsQuote(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=====================================================================================
+
+type logfmtPair struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+// typed returns the value of the pair as a string if it was quoted, else as
+// the int, float, bool or nil it represents.
+func (p logfmtPair) typed() interface{} {
+	if p.quoted {
+		return p.value
+	}
+
+	switch p.value {
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if val, err := strconv.Atoi(p.value); err == nil {
+		return val
+	}
+
+	if val, err := strconv.ParseFloat(p.value, 64); err == nil {
+		return val
+	}
+
+	return p.value
+}
+
+// parseLogFmt splits the giving line into its key-value pairs. Keys without
+// a value are given an empty value.
+func parseLogFmt(line string) ([]logfmtPair, error) {
+	var pairs []logfmtPair
+
+	for index := 0; index < len(line); {
+		if line[index] == ' ' {
+			index++
+			continue
+		}
+
+		start := index
+		for index < len(line) && line[index] != '=' && line[index] != ' ' {
+			index++
+		}
+
+		pair := logfmtPair{key: line[start:index]}
+		if index >= len(line) || line[index] == ' ' {
+			pairs = append(pairs, pair)
+			continue
+		}
+
+		// skip the '='.
+		index++
+
+		if index < len(line) && line[index] == '"' {
+			end := index + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(line) {
+				return nil, invalidEntry("unterminated value for %q", pair.key)
+			}
+
+			value, err := strconv.Unquote(line[index : end+1])
+			if err != nil {
+				return nil, invalidEntry("bad value for %q: %s", pair.key, err)
+			}
+
+			pair.value = value
+			pair.quoted = true
+			index = end + 1
+		} else {
+			start = index
+			for index < len(line) && line[index] != ' ' {
+				index++
+			}
+			pair.value = line[start:index]
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
@@ -239,3 +239,23 @@ func WithFields(f Field) func(*Entry) {
 		}
 	}
 }
+
+// Encodable returns a copy of the Entry with error values within its fields
+// replaced by their messages, as errors do not survive json encoding.
+func Encodable(en Entry) Entry {
+	if len(en.Field) == 0 {
+		return en
+	}
+
+	fields := make(Field, len(en.Field))
+	for key, value := range en.Field {
+		if err, ok := value.(error); ok {
+			fields[key] = err.Error()
+			continue
+		}
+		fields[key] = value
+	}
+
+	en.Field = fields
+	return en
+}
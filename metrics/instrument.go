@@ -0,0 +1,438 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errors.
+var (
+	ErrInstrumentKindMismatch = errors.New("instrument already registered with different kind")
+)
+
+// InstrumentKind defines a string type which represent the kind of a instrument.
+type InstrumentKind string
+
+// instrument kinds.
+const (
+	CounterKind   InstrumentKind = "counter"
+	GaugeKind     InstrumentKind = "gauge"
+	HistogramKind InstrumentKind = "histogram"
+)
+
+// InstrumentsKey defines the Field key used by a Registry to store the
+// snapshots of its instruments in a collected Entry.
+const InstrumentsKey = "instruments"
+
+// DefaultBuckets defines the default upper bounds used by a Histogram, these
+// are tailored for latencies measured in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Labels defines a map of label names to values which identifies a giving series
+// of a instrument.
+type Labels map[string]string
+
+// key returns a unique and stable representation of the labels.
+func (l Labels) key() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var bu bytes.Buffer
+	for _, name := range names {
+		bu.WriteString(name)
+		bu.WriteByte(0xff)
+		bu.WriteString(l[name])
+		bu.WriteByte(0xff)
+	}
+
+	return bu.String()
+}
+
+// copy returns a copy of the labels.
+func (l Labels) copy() Labels {
+	if l == nil {
+		return nil
+	}
+
+	c := make(Labels, len(l))
+	for k, v := range l {
+		c[k] = v
+	}
+
+	return c
+}
+
+// BucketSnapshot defines the cumulative count of observations which where less
+// than or equal to the upper bound of a histogram bucket.
+type BucketSnapshot struct {
+	UpperBound float64 `json:"upper_bound"`
+	Count      uint64  `json:"count"`
+}
+
+// SeriesSnapshot defines the value of a single labelled series of a instrument
+// at the time of collection. Count, Sum and Buckets are only set for histograms.
+type SeriesSnapshot struct {
+	Labels  Labels           `json:"labels"`
+	Value   float64          `json:"value"`
+	Count   uint64           `json:"count,omitempty"`
+	Sum     float64          `json:"sum,omitempty"`
+	Buckets []BucketSnapshot `json:"buckets,omitempty"`
+}
+
+// InstrumentSnapshot defines the state of a instrument and all its series at
+// the time of collection.
+type InstrumentSnapshot struct {
+	Name   string           `json:"name"`
+	Help   string           `json:"help"`
+	Kind   InstrumentKind   `json:"kind"`
+	Series []SeriesSnapshot `json:"series"`
+}
+
+// instrument defines the internal interface implemented by all instruments
+// to be snapshotted by a Registry.
+type instrument interface {
+	kind() InstrumentKind
+	snapshot() InstrumentSnapshot
+}
+
+//=====================================================================================
+
+// Registry defines a structure which holds a set of named instruments, it implements
+// the Collector interface, delivering a snapshot of all instruments as an Entry when
+// Metrics.CollectMetrics is called.
+type Registry struct {
+	ml          sync.Mutex
+	names       []string
+	instruments map[string]instrument
+}
+
+// NewRegistry returns a new instance of a Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		instruments: make(map[string]instrument),
+	}
+}
+
+// Counter returns the Counter registered with the giving name, creating it if
+// it does not exists. It panics if the name is used by another kind of instrument.
+func (r *Registry) Counter(name string, help string) *Counter {
+	return r.register(name, CounterKind, func() instrument {
+		return &Counter{name: name, help: help, series: make(map[string]*counterSeries)}
+	}).(*Counter)
+}
+
+// Gauge returns the Gauge registered with the giving name, creating it if
+// it does not exists. It panics if the name is used by another kind of instrument.
+func (r *Registry) Gauge(name string, help string) *Gauge {
+	return r.register(name, GaugeKind, func() instrument {
+		return &Gauge{name: name, help: help, series: make(map[string]*gaugeSeries)}
+	}).(*Gauge)
+}
+
+// Histogram returns the Histogram registered with the giving name, creating it if
+// it does not exists using the provided bucket upper bounds or DefaultBuckets if
+// none is provided. It panics if the name is used by another kind of instrument.
+func (r *Registry) Histogram(name string, help string, buckets ...float64) *Histogram {
+	return r.register(name, HistogramKind, func() instrument {
+		if len(buckets) == 0 {
+			buckets = DefaultBuckets
+		}
+
+		bounds := append([]float64{}, buckets...)
+		sort.Float64s(bounds)
+
+		return &Histogram{name: name, help: help, buckets: bounds, series: make(map[string]*histogramSeries)}
+	}).(*Histogram)
+}
+
+// register returns the instrument with the giving name, creating a new one with the
+// provided function if none exists.
+func (r *Registry) register(name string, kind InstrumentKind, fn func() instrument) instrument {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	if inst, ok := r.instruments[name]; ok {
+		if inst.kind() != kind {
+			panic(ErrInstrumentKindMismatch)
+		}
+		return inst
+	}
+
+	inst := fn()
+	r.names = append(r.names, name)
+	r.instruments[name] = inst
+	return inst
+}
+
+// Snapshot returns the snapshots of all registered instruments in
+// registration order.
+func (r *Registry) Snapshot() []InstrumentSnapshot {
+	r.ml.Lock()
+	insts := make([]instrument, 0, len(r.names))
+	for _, name := range r.names {
+		insts = append(insts, r.instruments[name])
+	}
+	r.ml.Unlock()
+
+	snapshots := make([]InstrumentSnapshot, 0, len(insts))
+	for _, inst := range insts {
+		snapshots = append(snapshots, inst.snapshot())
+	}
+
+	return snapshots
+}
+
+// Collect implements the Collector interface, returning an Entry containing the
+// snapshots of all instruments within it's Field under the InstrumentsKey.
+func (r *Registry) Collect(id string) Entry {
+	return Entry{
+		ID:      id,
+		Level:   InfoLvl,
+		Type:    InstrumentsKey,
+		Time:    time.Now(),
+		Message: "Instruments Snapshot",
+		Field: Field{
+			InstrumentsKey: r.Snapshot(),
+		},
+	}
+}
+
+// GetInstruments returns the instrument snapshots stored within the Entry
+// if it was collected from a Registry.
+func GetInstruments(en Entry) ([]InstrumentSnapshot, bool) {
+	val, ok := en.Field.Get(InstrumentsKey)
+	if !ok {
+		return nil, false
+	}
+
+	snapshots, ok := val.([]InstrumentSnapshot)
+	return snapshots, ok
+}
+
+//=====================================================================================
+
+// Counter defines a instrument whoes series only ever increase.
+type Counter struct {
+	name   string
+	help   string
+	ml     sync.Mutex
+	keys   []string
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels Labels
+	value  float64
+}
+
+// Inc increments the series of the giving labels by 1.
+func (c *Counter) Inc(labels Labels) {
+	c.Add(1, labels)
+}
+
+// Add adds the giving value into the series of the giving labels.
+// Negative values are ignored as counters can not decrease.
+func (c *Counter) Add(value float64, labels Labels) {
+	if value < 0 {
+		return
+	}
+
+	key := labels.key()
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{labels: labels.copy()}
+		c.keys = append(c.keys, key)
+		c.series[key] = series
+	}
+
+	series.value += value
+}
+
+func (c *Counter) kind() InstrumentKind {
+	return CounterKind
+}
+
+func (c *Counter) snapshot() InstrumentSnapshot {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	snap := InstrumentSnapshot{Name: c.name, Help: c.help, Kind: CounterKind}
+	for _, key := range c.keys {
+		series := c.series[key]
+		snap.Series = append(snap.Series, SeriesSnapshot{
+			Labels: series.labels.copy(),
+			Value:  series.value,
+		})
+	}
+
+	return snap
+}
+
+//=====================================================================================
+
+// Gauge defines a instrument whoes series can be set to arbitrary values.
+type Gauge struct {
+	name   string
+	help   string
+	ml     sync.Mutex
+	keys   []string
+	series map[string]*gaugeSeries
+}
+
+type gaugeSeries struct {
+	labels Labels
+	value  float64
+}
+
+// Set sets the series of the giving labels to the provided value.
+func (g *Gauge) Set(value float64, labels Labels) {
+	g.update(labels, func(series *gaugeSeries) {
+		series.value = value
+	})
+}
+
+// Add adds the giving value, which can be negative, into the series of the
+// giving labels.
+func (g *Gauge) Add(value float64, labels Labels) {
+	g.update(labels, func(series *gaugeSeries) {
+		series.value += value
+	})
+}
+
+// Inc increments the series of the giving labels by 1.
+func (g *Gauge) Inc(labels Labels) {
+	g.Add(1, labels)
+}
+
+// Dec decrements the series of the giving labels by 1.
+func (g *Gauge) Dec(labels Labels) {
+	g.Add(-1, labels)
+}
+
+func (g *Gauge) update(labels Labels, fn func(*gaugeSeries)) {
+	key := labels.key()
+
+	g.ml.Lock()
+	defer g.ml.Unlock()
+
+	series, ok := g.series[key]
+	if !ok {
+		series = &gaugeSeries{labels: labels.copy()}
+		g.keys = append(g.keys, key)
+		g.series[key] = series
+	}
+
+	fn(series)
+}
+
+func (g *Gauge) kind() InstrumentKind {
+	return GaugeKind
+}
+
+func (g *Gauge) snapshot() InstrumentSnapshot {
+	g.ml.Lock()
+	defer g.ml.Unlock()
+
+	snap := InstrumentSnapshot{Name: g.name, Help: g.help, Kind: GaugeKind}
+	for _, key := range g.keys {
+		series := g.series[key]
+		snap.Series = append(snap.Series, SeriesSnapshot{
+			Labels: series.labels.copy(),
+			Value:  series.value,
+		})
+	}
+
+	return snap
+}
+
+//=====================================================================================
+
+// Histogram defines a instrument which counts observations into a set of buckets.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	ml      sync.Mutex
+	keys    []string
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels Labels
+	count  uint64
+	sum    float64
+	counts []uint64
+}
+
+// Observe records the giving value into the series of the giving labels.
+func (h *Histogram) Observe(value float64, labels Labels) {
+	key := labels.key()
+
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labels: labels.copy(), counts: make([]uint64, len(h.buckets))}
+		h.keys = append(h.keys, key)
+		h.series[key] = series
+	}
+
+	series.count++
+	series.sum += value
+
+	if index := sort.SearchFloat64s(h.buckets, value); index < len(h.buckets) {
+		series.counts[index]++
+	}
+}
+
+// Since records the seconds elapsed since the giving time into the series of
+// the giving labels.
+func (h *Histogram) Since(start time.Time, labels Labels) {
+	h.Observe(time.Since(start).Seconds(), labels)
+}
+
+func (h *Histogram) kind() InstrumentKind {
+	return HistogramKind
+}
+
+func (h *Histogram) snapshot() InstrumentSnapshot {
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	snap := InstrumentSnapshot{Name: h.name, Help: h.help, Kind: HistogramKind}
+	for _, key := range h.keys {
+		series := h.series[key]
+
+		var cumulative uint64
+		buckets := make([]BucketSnapshot, len(h.buckets))
+		for index, bound := range h.buckets {
+			cumulative += series.counts[index]
+			buckets[index] = BucketSnapshot{UpperBound: bound, Count: cumulative}
+		}
+
+		snap.Series = append(snap.Series, SeriesSnapshot{
+			Labels:  series.labels.copy(),
+			Count:   series.count,
+			Sum:     series.sum,
+			Buckets: buckets,
+		})
+	}
+
+	return snap
+}
@@ -0,0 +1,136 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/tests"
+)
+
+func TestCounter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.Counter("requests_total", "Total requests")
+
+	counter.Inc(metrics.Labels{"code": "200"})
+	counter.Add(2.5, metrics.Labels{"code": "200"})
+	counter.Add(-10, metrics.Labels{"code": "200"})
+	counter.Inc(metrics.Labels{"code": "500"})
+
+	snap := registry.Snapshot()[0]
+	if snap.Kind != metrics.CounterKind || len(snap.Series) != 2 {
+		tests.Failed("Should have two counter series: %+v", snap)
+	}
+
+	if snap.Series[0].Value != 3.5 {
+		tests.Failed("Should have ignored negative value but got %f", snap.Series[0].Value)
+	}
+	tests.Passed("Should have ignored negative values added to counter")
+
+	if snap.Series[1].Labels["code"] != "500" || snap.Series[1].Value != 1 {
+		tests.Failed("Should have kept series per labels: %+v", snap.Series[1])
+	}
+	tests.Passed("Should have kept series per labels")
+
+	if registry.Counter("requests_total", "") != counter {
+		tests.Failed("Should have returned registered counter for same name")
+	}
+	tests.Passed("Should have returned registered counter for same name")
+}
+
+func TestGauge(t *testing.T) {
+	registry := metrics.NewRegistry()
+	gauge := registry.Gauge("queue_depth", "Items in queue")
+
+	gauge.Set(10, nil)
+	gauge.Add(-4, nil)
+	gauge.Inc(nil)
+	gauge.Dec(nil)
+	gauge.Dec(nil)
+
+	if value := registry.Snapshot()[0].Series[0].Value; value != 5 {
+		tests.Failed("Should have gauge value of 5 but got %f", value)
+	}
+	tests.Passed("Should have added negative values to gauge")
+
+	gauge.Set(-2, nil)
+	if value := registry.Snapshot()[0].Series[0].Value; value != -2 {
+		tests.Failed("Should have set gauge to -2 but got %f", value)
+	}
+	tests.Passed("Should have set gauge value")
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	registry := metrics.NewRegistry()
+	histogram := registry.Histogram("latency_seconds", "Request latency", 1, 0.5, 2)
+
+	for _, value := range []float64{0.1, 0.5, 0.50001, 1, 2, 3} {
+		histogram.Observe(value, nil)
+	}
+
+	series := registry.Snapshot()[0].Series[0]
+	if series.Count != 6 || series.Sum != 7.10001 {
+		tests.Failed("Should have counted all observations: %+v", series)
+	}
+
+	expected := []metrics.BucketSnapshot{
+		{UpperBound: 0.5, Count: 2},
+		{UpperBound: 1, Count: 4},
+		{UpperBound: 2, Count: 5},
+	}
+
+	if len(series.Buckets) != len(expected) {
+		tests.Failed("Should have %d buckets but got %d", len(expected), len(series.Buckets))
+	}
+
+	for index, bucket := range expected {
+		if series.Buckets[index] != bucket {
+			tests.Failed("Should have bucket %+v but got %+v", bucket, series.Buckets[index])
+		}
+	}
+	tests.Passed("Should have counted observations equal to a bound within its bucket")
+}
+
+func TestRegistryKindMismatch(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("jobs", "Jobs")
+
+	defer func() {
+		if recovered := recover(); recovered != metrics.ErrInstrumentKindMismatch {
+			tests.Failed("Should have panicked with kind mismatch but got %+v", recovered)
+		}
+		tests.Passed("Should have panicked when name is registered as another kind")
+	}()
+
+	registry.Gauge("jobs", "Jobs")
+}
+
+func TestRegistryCollect(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("b_total", "B").Inc(nil)
+	registry.Gauge("a_value", "A").Set(3, metrics.Labels{"host": "one"})
+
+	entry := registry.Collect("collector")
+	if entry.ID != "collector" || entry.Type != metrics.InstrumentsKey {
+		tests.Failed("Should have collected entry with id and type: %+v", entry)
+	}
+	tests.Passed("Should have collected entry with id and type")
+
+	snapshots, ok := metrics.GetInstruments(entry)
+	if !ok || len(snapshots) != 2 {
+		tests.Failed("Should have retrieved instruments from entry: %+v", snapshots)
+	}
+
+	if snapshots[0].Name != "b_total" || snapshots[1].Name != "a_value" {
+		tests.Failed("Should have kept registration order: %+v", snapshots)
+	}
+
+	if snapshots[1].Kind != metrics.GaugeKind || snapshots[1].Series[0].Labels["host"] != "one" {
+		tests.Failed("Should have collected gauge series: %+v", snapshots[1])
+	}
+	tests.Passed("Should have retrieved instruments from entry")
+
+	if _, ok := metrics.GetInstruments(metrics.Entry{}); ok {
+		tests.Failed("Should have found no instruments within plain entry")
+	}
+	tests.Passed("Should have found no instruments within plain entry")
+}
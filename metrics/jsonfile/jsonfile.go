@@ -10,7 +10,7 @@ import (
 )
 
 // JSON returns a metrics.Metric which writes a series of batch entries into a json file.
-func JSON(targetFile string, maxBatchPerWrite int, maxwait time.Duration) (metrics.MetricConsumer, error) {
+func JSON(targetFile string, maxBatchPerWrite int, maxwait time.Duration) (metrics.BatchMetricConsumer, error) {
 	// If the directory does not exists, create it first.
 	dir := filepath.Dir(targetFile)
 	if dir != "" {
@@ -0,0 +1,390 @@
+package jsonfile
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// rotateTimeFormat defines the timestamp layout appended to rotated files. It is
+// fixed width so rotated files sort lexically in the order they were created.
+const rotateTimeFormat = "20060102T150405.000000000"
+
+// errors.
+var (
+	ErrRotatingFileClosed = errors.New("rotating file already closed")
+)
+
+// RotateConfig defines the rules used by a RotatingFile to decide when the
+// current file is rolled over and what happens to old files.
+type RotateConfig struct {
+	// MaxSize sets the maximum size in bytes a file may reach before it is rotated.
+	// A value of zero disables size based rotation.
+	MaxSize int64
+
+	// Interval sets the wall-clock period after which a file is rotated, it is
+	// aligned against the zero time, so an hour interval rotates on the hour.
+	// A value of zero disables time based rotation.
+	Interval time.Duration
+
+	// MaxFiles sets the total rotated files kept around, older files are removed.
+	// A value of zero keeps all rotated files.
+	MaxFiles int
+
+	// Compress sets rotated files to be gzipped.
+	Compress bool
+
+	// OnError sets a function called with errors met while rotating, which do
+	// not fail the write that triggered the rotation.
+	OnError func(error)
+}
+
+// RotatingFile implements a io.WriteCloser which writes into a target file,
+// rolling it into a timestamped file when any of the limits of the
+// RotateConfig is met.
+type RotatingFile struct {
+	config     RotateConfig
+	targetFile string
+
+	ml       sync.Mutex
+	file     *os.File
+	size     int64
+	deadline time.Time
+	closed   bool
+}
+
+// NewRotatingFile returns a new instance of a RotatingFile for the target file.
+func NewRotatingFile(targetFile string, config RotateConfig) (*RotatingFile, error) {
+	// If the directory does not exists, create it first.
+	dir := filepath.Dir(targetFile)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	rf := &RotatingFile{
+		config:     config,
+		targetFile: targetFile,
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+// Write writes the giving data into the current file, rotating before hand if
+// the write would take the file above the max size or the interval has elapsed.
+func (rf *RotatingFile) Write(data []byte) (int, error) {
+	rf.ml.Lock()
+	defer rf.ml.Unlock()
+
+	if rf.closed {
+		return 0, ErrRotatingFileClosed
+	}
+
+	// A failed rotation may have left no file open.
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if rf.shouldRotate(int64(len(data))) {
+		if err := rf.rotate(); err != nil {
+			// Without a file to write into the entry is lost, else rotation is
+			// retried later while the entry is still written.
+			if rf.file == nil {
+				return 0, err
+			}
+
+			if rf.config.OnError != nil {
+				rf.config.OnError(err)
+			}
+		}
+	}
+
+	n, err := rf.file.Write(data)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Sync commits the current content of the file into stable storage.
+func (rf *RotatingFile) Sync() error {
+	rf.ml.Lock()
+	defer rf.ml.Unlock()
+
+	if rf.closed {
+		return ErrRotatingFileClosed
+	}
+
+	if rf.file == nil {
+		return rf.open()
+	}
+
+	return rf.file.Sync()
+}
+
+// Rotate forcefully rotates the current file regardless of the configured limits.
+func (rf *RotatingFile) Rotate() error {
+	rf.ml.Lock()
+	defer rf.ml.Unlock()
+
+	if rf.closed {
+		return ErrRotatingFileClosed
+	}
+
+	return rf.rotate()
+}
+
+// Close closes the current file, further writes will fail.
+func (rf *RotatingFile) Close() error {
+	rf.ml.Lock()
+	defer rf.ml.Unlock()
+
+	if rf.closed {
+		return nil
+	}
+
+	rf.closed = true
+	if rf.file == nil {
+		return nil
+	}
+
+	return rf.file.Close()
+}
+
+// shouldRotate returns true/false if the current file must be rotated before
+// the giving incoming size is written.
+func (rf *RotatingFile) shouldRotate(incoming int64) bool {
+	if rf.config.MaxSize > 0 && rf.size > 0 && rf.size+incoming > rf.config.MaxSize {
+		return true
+	}
+
+	if rf.config.Interval > 0 && !time.Now().Before(rf.deadline) {
+		// An empty file has nothing worth keeping, it carries over into the
+		// next interval instead.
+		if rf.size == 0 {
+			rf.deadline = rf.nextDeadline()
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// rotate closes the current file, moves it into a timestamped file and opens
+// a new target file. If the file can not be moved, the target file is reopened
+// to keep writing into it, so the file is only left nil if none could be
+// opened. It expects the lock to be held.
+func (rf *RotatingFile) rotate() error {
+	if rf.file != nil {
+		err := rf.file.Close()
+		rf.file = nil
+
+		rotated := rotatedName(rf.targetFile, time.Now())
+		if err == nil {
+			err = os.Rename(rf.targetFile, rotated)
+		}
+
+		if err != nil {
+			if oerr := rf.open(); oerr != nil {
+				return oerr
+			}
+			return err
+		}
+
+		if err := rf.open(); err != nil {
+			return err
+		}
+
+		if rf.config.Compress {
+			if err := compressFile(rotated); err != nil {
+				return err
+			}
+		}
+
+		return rf.prune()
+	}
+
+	return rf.open()
+}
+
+// nextDeadline returns the start of the next rotation interval.
+func (rf *RotatingFile) nextDeadline() time.Time {
+	return time.Now().Truncate(rf.config.Interval).Add(rf.config.Interval)
+}
+
+// open opens the target file for appending, setting the current size and
+// next rotation deadline.
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.targetFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = stat.Size()
+
+	if rf.config.Interval > 0 {
+		rf.deadline = rf.nextDeadline()
+	}
+
+	return nil
+}
+
+// prune removes the oldest rotated files above the max files allowed.
+func (rf *RotatingFile) prune() error {
+	if rf.config.MaxFiles <= 0 {
+		return nil
+	}
+
+	files, err := RotatedFiles(rf.targetFile)
+	if err != nil {
+		return err
+	}
+
+	if len(files) <= rf.config.MaxFiles {
+		return nil
+	}
+
+	for _, file := range files[:len(files)-rf.config.MaxFiles] {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotatedFiles returns the path of all rotated files of the target file
+// sorted from the oldest to the newest.
+func RotatedFiles(targetFile string) ([]string, error) {
+	prefix, ext := rotatedPrefix(targetFile)
+
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+
+		if _, err := time.Parse(rotateTimeFormat, strings.TrimSuffix(stamp, ext)); err != nil {
+			continue
+		}
+
+		files = append(files, match)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// rotatedName returns the name a target file is moved into when rotated at the
+// giving time.
+func rotatedName(targetFile string, at time.Time) string {
+	prefix, ext := rotatedPrefix(targetFile)
+	return prefix + at.UTC().Format(rotateTimeFormat) + ext
+}
+
+// rotatedPrefix returns the prefix and extension used for rotated files of
+// the target file.
+func rotatedPrefix(targetFile string) (string, string) {
+	ext := filepath.Ext(targetFile)
+	return strings.TrimSuffix(targetFile, ext) + "-", ext
+}
+
+// compressFile gzips the giving file into a file with a .gz extension,
+// removing the original.
+func compressFile(file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dest, err := os.OpenFile(file+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dest)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dest.Close()
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		dest.Close()
+		return err
+	}
+
+	if err := dest.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(file)
+}
+
+// RotatingConsumer embeds the metrics.BatchMetricConsumer writing into a RotatingFile,
+// exposing the file for closing once the consumer is stopped.
+type RotatingConsumer struct {
+	metrics.BatchMetricConsumer
+	*RotatingFile
+}
+
+// RotatingJSON returns a metrics.BatchMetricConsumer which writes a series of batch entries
+// into a json file which is rotated based on the provided RotateConfig.
+func RotatingJSON(targetFile string, maxBatchPerWrite int, maxwait time.Duration, config RotateConfig) (*RotatingConsumer, error) {
+	rf, err := NewRotatingFile(targetFile, config)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := metrics.BatchConsumer(maxBatchPerWrite, maxwait, func(entries []metrics.Entry) error {
+		for _, item := range entries {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+
+			// Write each entry in a single call to never split one across files.
+			if _, err := rf.Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+
+		return rf.Sync()
+	})
+
+	return &RotatingConsumer{
+		BatchMetricConsumer: consumer,
+		RotatingFile:        rf,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package jsonfile_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics/jsonfile"
+	"github.com/influx6/faux/tests"
+)
+
+func TestRotatingFileBySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	tests.Passed("Should have created temporary directory")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.json")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{MaxSize: 10, MaxFiles: 2})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := rf.Write([]byte("0123456789")); err != nil {
+			tests.Failed("Should have written into rotating file: %+q", err)
+		}
+	}
+	tests.Passed("Should have written into rotating file")
+
+	files, err := jsonfile.RotatedFiles(target)
+	if err != nil {
+		tests.Failed("Should have retrieved rotated files: %+q", err)
+	}
+	tests.Passed("Should have retrieved rotated files")
+
+	if len(files) != 2 {
+		tests.Failed("Should have kept only 2 rotated files but got %d", len(files))
+	}
+	tests.Passed("Should have kept only 2 rotated files")
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		tests.Failed("Should have read current file: %+q", err)
+	}
+
+	if string(data) != "0123456789" {
+		tests.Failed("Should have only last write in current file: %+q", data)
+	}
+	tests.Passed("Should have only last write in current file")
+}
+
+func TestRotatingFileWithCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	tests.Passed("Should have created temporary directory")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.json")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{Compress: true})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	if _, err := rf.Write([]byte("{}\n")); err != nil {
+		tests.Failed("Should have written into rotating file: %+q", err)
+	}
+	tests.Passed("Should have written into rotating file")
+
+	if err := rf.Rotate(); err != nil {
+		tests.Failed("Should have rotated file: %+q", err)
+	}
+	tests.Passed("Should have rotated file")
+
+	files, err := jsonfile.RotatedFiles(target)
+	if err != nil {
+		tests.Failed("Should have retrieved rotated files: %+q", err)
+	}
+
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".json.gz") {
+		tests.Failed("Should have a single gzipped rotated file: %+q", files)
+	}
+	tests.Passed("Should have a single gzipped rotated file")
+}
+
+func TestRotatingFileKeepsWritingOnFailedRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	tests.Passed("Should have created temporary directory")
+
+	defer os.RemoveAll(dir)
+
+	var rotateErr error
+	target := filepath.Join(dir, "app.json")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{
+		MaxSize: 10,
+		OnError: func(err error) { rotateErr = err },
+	})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	if _, err := rf.Write([]byte("0123456789")); err != nil {
+		tests.Failed("Should have written into rotating file: %+q", err)
+	}
+
+	// Removing the target makes moving it during rotation fail.
+	if err := os.Remove(target); err != nil {
+		tests.Failed("Should have removed target file: %+q", err)
+	}
+
+	if _, err := rf.Write([]byte("abcdefghij")); err != nil {
+		tests.Failed("Should have written entry despite failed rotation: %+q", err)
+	}
+
+	if rotateErr == nil {
+		tests.Failed("Should have reported failed rotation")
+	}
+	tests.Passed("Should have reported failed rotation")
+
+	if _, err := rf.Write([]byte("klmnopqrst")); err != nil {
+		tests.Failed("Should have kept writing after failed rotation: %+q", err)
+	}
+
+	files, err := jsonfile.RotatedFiles(target)
+	if err != nil || len(files) != 1 {
+		tests.Failed("Should have rotated reopened file: %+q %+q", files, err)
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil || string(data) != "abcdefghij" {
+		tests.Failed("Should have kept entry written after failed rotation: %+q", data)
+	}
+	tests.Passed("Should have kept writing after failed rotation")
+}
+
+func TestRotatingFileSkipsEmptyIntervals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		tests.Failed("Should have created temporary directory: %+q", err)
+	}
+	tests.Passed("Should have created temporary directory")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.json")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{Interval: 10 * time.Millisecond})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := rf.Write([]byte("{}\n")); err != nil {
+		tests.Failed("Should have written into rotating file: %+q", err)
+	}
+
+	if files, _ := jsonfile.RotatedFiles(target); len(files) != 0 {
+		tests.Failed("Should not have rotated empty file: %+q", files)
+	}
+	tests.Passed("Should not have rotated empty file")
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := rf.Write([]byte("{}\n")); err != nil {
+		tests.Failed("Should have written into rotating file: %+q", err)
+	}
+
+	if files, _ := jsonfile.RotatedFiles(target); len(files) != 1 {
+		tests.Failed("Should have rotated file with content: %+q", files)
+	}
+	tests.Passed("Should have rotated file with content")
+}
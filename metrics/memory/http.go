@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/metrics"
+)
+
+// KeepAlive defines the interval a comment line is sent on an idle event stream
+// to keep intermediaries from closing the connection.
+var KeepAlive = 15 * time.Second
+
+// ParseQuery returns a Query from the giving url values, which supports the
+// following parameters:
+//
+//	level=error,redalert	levels an entry must have one of.
+//	id=db:query		ID an entry must have.
+//	tag=db			tag an entry must carry.
+//	field=user:bob		field value an entry must carry, repeatable.
+//	since=5m|RFC3339	start of the time range, as a duration before now or a time.
+//	until=RFC3339		end of the time range.
+//	after=20		sequence an entry must come after.
+//	limit=100		maximum entries returned.
+func ParseQuery(values url.Values) (Query, error) {
+	var q Query
+	q.ID = values.Get("id")
+	q.Tag = values.Get("tag")
+
+	if levels := values.Get("level"); levels != "" {
+		for _, name := range strings.Split(levels, ",") {
+			lvl := metrics.GetLevel(strings.TrimSpace(name))
+			if lvl < 0 {
+				return q, fmt.Errorf("unknown level %q", name)
+			}
+			q.Levels = append(q.Levels, lvl)
+		}
+	}
+
+	for _, field := range values["field"] {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			return q, fmt.Errorf("field %q must be in key:value form", field)
+		}
+
+		if q.Fields == nil {
+			q.Fields = make(map[string]string)
+		}
+		q.Fields[parts[0]] = parts[1]
+	}
+
+	var err error
+	if q.Since, err = parseQueryTime(values.Get("since")); err != nil {
+		return q, err
+	}
+
+	if q.Until, err = parseQueryTime(values.Get("until")); err != nil {
+		return q, err
+	}
+
+	if after := values.Get("after"); after != "" {
+		if q.After, err = strconv.ParseUint(after, 10, 64); err != nil {
+			return q, fmt.Errorf("invalid after %q", after)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+
+	return q, nil
+}
+
+// parseQueryTime parses the giving value as a RFC3339 time or a duration before now.
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if dur, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-dur), nil
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return at, fmt.Errorf("invalid time %q", value)
+	}
+
+	return at, nil
+}
+
+// HTTPHandler returns a httputil.Handler which serves the records of the Ring
+// matching the Query parsed from the request's parameters. Records are served
+// as a json array, unless the request accepts "text/event-stream" or sets the
+// "stream" parameter, where matching records are served as server-sent events
+// followed by new records as they arrive, till the client goes away. Event
+// streams resume after the sequence in the Last-Event-ID header if provided.
+func HTTPHandler(ring *Ring) httputil.Handler {
+	return func(ctx *httputil.Context) error {
+		q, err := ParseQuery(ctx.QueryParams())
+		if err != nil {
+			return httputil.HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+
+		if !wantsStream(ctx) {
+			records := ring.Query(q)
+			if records == nil {
+				records = []Record{}
+			}
+
+			for index, record := range records {
+				records[index].Entry = metrics.Encodable(record.Entry)
+			}
+
+			return ctx.JSON(http.StatusOK, records)
+		}
+
+		if lastID := ctx.GetHeader("Last-Event-ID"); lastID != "" {
+			if q.After, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+				return httputil.HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid Last-Event-ID %q", lastID)}
+			}
+		}
+
+		return serveStream(ctx, ring, q)
+	}
+}
+
+// wantsStream returns true/false if the request asks for an event stream.
+func wantsStream(ctx *httputil.Context) bool {
+	if strings.Contains(ctx.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+
+	stream, _ := strconv.ParseBool(ctx.QueryParam("stream"))
+	return stream
+}
+
+func serveStream(ctx *httputil.Context, ring *Ring, q Query) error {
+	// Subscribe before reading the backlog so no record is missed between both.
+	live := q
+	live.Limit = 0
+	sub := ring.Subscribe(64, live)
+	defer sub.Close()
+
+	res := ctx.Response()
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	last := q.After
+	for _, record := range ring.Query(q) {
+		if err := writeEvent(res, record); err != nil {
+			return err
+		}
+		last = record.Seq
+	}
+
+	flush(res)
+
+	var done <-chan struct{}
+	if reqCtx := ctx.Context(); reqCtx != nil {
+		done = reqCtx.Done()
+	}
+
+	ticker := time.NewTicker(KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			if _, err := res.Write([]byte(": keepalive\n\n")); err != nil {
+				return nil
+			}
+			flush(res)
+		case record, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+
+			if record.Seq <= last {
+				continue
+			}
+
+			if err := writeEvent(res, record); err != nil {
+				return nil
+			}
+
+			last = record.Seq
+			flush(res)
+		}
+	}
+}
+
+func writeEvent(res *httputil.Response, record Record) error {
+	data, err := json.Marshal(metrics.Encodable(record.Entry))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(res, "id: %d\nevent: entry\ndata: %s\n\n", record.Seq, data)
+	return err
+}
+
+func flush(res *httputil.Response) {
+	if _, ok := res.Writer.(http.Flusher); ok {
+		res.Flush()
+	}
+}
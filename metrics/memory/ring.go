@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// Query defines the conditions used to select entries from a Ring, where
+// unset conditions match all entries.
+type Query struct {
+	// Levels sets the levels an Entry must have one of.
+	Levels []metrics.Level
+
+	// ID sets the ID an Entry must have.
+	ID string
+
+	// Tag sets a tag an Entry must carry.
+	Tag string
+
+	// Fields sets the field values an Entry must carry, values are compared
+	// against the fmt.Sprint form of the entry field.
+	Fields map[string]string
+
+	// Since and Until set the time range an Entry must fall within, inclusive.
+	Since time.Time
+	Until time.Time
+
+	// After sets the sequence an Entry must come after.
+	After uint64
+
+	// Limit sets the maximum entries returned, keeping the most recent.
+	Limit int
+
+	// Filter sets a custom function an Entry must pass.
+	Filter metrics.FilterFn
+}
+
+// Match returns true/false if the giving Entry matches the Query.
+func (q Query) Match(en metrics.Entry) bool {
+	if len(q.Levels) != 0 {
+		var found bool
+		for _, lvl := range q.Levels {
+			if lvl == en.Level {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if q.ID != "" && q.ID != en.ID {
+		return false
+	}
+
+	if q.Tag != "" {
+		var found bool
+		for _, tag := range en.Tags {
+			if tag == q.Tag {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for key, value := range q.Fields {
+		item, ok := en.Field[key]
+		if !ok || fmt.Sprint(item) != value {
+			return false
+		}
+	}
+
+	if !q.Since.IsZero() && en.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && en.Time.After(q.Until) {
+		return false
+	}
+
+	if q.Filter != nil && !q.Filter(en) {
+		return false
+	}
+
+	return true
+}
+
+//=====================================================================================
+
+// Record defines an Entry held by a Ring along with its sequence number, which
+// increases by one for every Entry handled by the Ring.
+type Record struct {
+	Seq   uint64        `json:"seq"`
+	Entry metrics.Entry `json:"entry"`
+}
+
+// Subscription defines a live feed of entries from a Ring which match a Query.
+// Entries are dropped for a subscriber whoes channel is full.
+type Subscription struct {
+	C <-chan Record
+
+	ring  *Ring
+	query Query
+	feed  chan Record
+	once  sync.Once
+}
+
+// Close removes the Subscription from its Ring and closes its channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.ring.unsubscribe(s)
+	})
+}
+
+// Ring implements the metrics.Processors interface, keeping the last N entries
+// it receives in a fixed size buffer which can be queried and subscribed to.
+type Ring struct {
+	ml    sync.RWMutex
+	items []Record
+	next  int
+	full  bool
+	seq   uint64
+	subs  map[*Subscription]struct{}
+}
+
+// NewRing returns a new instance of a Ring holding up to size entries.
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Ring{
+		items: make([]Record, size),
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+// Handle implements the metrics.Processors interface, adding the Entry into the
+// buffer in place of the oldest once full and delivering it to subscribers.
+func (r *Ring) Handle(en metrics.Entry) error {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	r.seq++
+	record := Record{Seq: r.seq, Entry: en}
+
+	r.items[r.next] = record
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	for sub := range r.subs {
+		if !sub.query.Match(en) {
+			continue
+		}
+
+		select {
+		case sub.feed <- record:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Size returns the maximum entries held by the Ring.
+func (r *Ring) Size() int {
+	return len(r.items)
+}
+
+// Len returns the total entries currently held by the Ring.
+func (r *Ring) Len() int {
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+
+	if r.full {
+		return len(r.items)
+	}
+	return r.next
+}
+
+// Seq returns the sequence of the last Entry handled by the Ring.
+func (r *Ring) Seq() uint64 {
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+	return r.seq
+}
+
+// Entries returns all entries held by the Ring from the oldest to the newest.
+func (r *Ring) Entries() []metrics.Entry {
+	records := r.Query(Query{})
+
+	entries := make([]metrics.Entry, len(records))
+	for index, record := range records {
+		entries[index] = record.Entry
+	}
+	return entries
+}
+
+// Query returns all records held by the Ring which match the giving Query from
+// the oldest to the newest.
+func (r *Ring) Query(q Query) []Record {
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+
+	var records []Record
+	r.each(func(record Record) {
+		if record.Seq > q.After && q.Match(record.Entry) {
+			records = append(records, record)
+		}
+	})
+
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+
+	return records
+}
+
+// Reset removes all entries held by the Ring.
+func (r *Ring) Reset() {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	r.items = make([]Record, len(r.items))
+	r.next = 0
+	r.full = false
+}
+
+// Subscribe returns a Subscription which receives every Entry handled after this
+// call that matches the giving Query. The buffer sets the capacity of its channel.
+func (r *Ring) Subscribe(buffer int, q Query) *Subscription {
+	feed := make(chan Record, buffer)
+	sub := &Subscription{
+		C:     feed,
+		ring:  r,
+		feed:  feed,
+		query: q,
+	}
+
+	r.ml.Lock()
+	r.subs[sub] = struct{}{}
+	r.ml.Unlock()
+
+	return sub
+}
+
+func (r *Ring) unsubscribe(sub *Subscription) {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	delete(r.subs, sub)
+	close(sub.feed)
+}
+
+// each calls the function with every held record from the oldest to the
+// newest. It expects the lock to be held.
+func (r *Ring) each(fn func(Record)) {
+	if r.full {
+		for _, record := range r.items[r.next:] {
+			fn(record)
+		}
+	}
+
+	for _, record := range r.items[:r.next] {
+		fn(record)
+	}
+}
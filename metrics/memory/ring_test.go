@@ -0,0 +1,103 @@
+package memory_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/httputil/httptesting"
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/memory"
+	"github.com/influx6/faux/tests"
+)
+
+func TestRing(t *testing.T) {
+	ring := memory.NewRing(3)
+	m := metrics.New(ring)
+
+	m.Emit(metrics.Info("first"), metrics.WithID("a"))
+	m.Emit(metrics.Errorf("second"), metrics.WithID("b"), metrics.With("user", "bob"))
+	m.Emit(metrics.Info("third"), metrics.WithID("a"), metrics.Tags("db"))
+	m.Emit(metrics.Info("fourth"), metrics.WithID("a"), metrics.With("user", "alice"))
+
+	entries := ring.Entries()
+	if len(entries) != 3 || entries[0].Message != "second" || entries[2].Message != "fourth" {
+		tests.Failed("Should have kept last three entries in order: %#v", entries)
+	}
+	tests.Passed("Should have kept last three entries in order")
+
+	if records := ring.Query(memory.Query{ID: "a"}); len(records) != 2 || records[0].Seq != 3 {
+		tests.Failed("Should have queried entries by id: %#v", records)
+	}
+	tests.Passed("Should have queried entries by id")
+
+	if records := ring.Query(memory.Query{Levels: []metrics.Level{metrics.ErrorLvl}, Fields: map[string]string{"user": "bob"}}); len(records) != 1 {
+		tests.Failed("Should have queried entries by level and field: %#v", records)
+	}
+	tests.Passed("Should have queried entries by level and field")
+
+	if records := ring.Query(memory.Query{Tag: "db"}); len(records) != 1 || records[0].Entry.Message != "third" {
+		tests.Failed("Should have queried entries by tag: %#v", records)
+	}
+	tests.Passed("Should have queried entries by tag")
+
+	if records := ring.Query(memory.Query{Since: time.Now().Add(time.Minute)}); len(records) != 0 {
+		tests.Failed("Should have queried entries by time range: %#v", records)
+	}
+	tests.Passed("Should have queried entries by time range")
+
+	sub := ring.Subscribe(2, memory.Query{ID: "c"})
+	m.Emit(metrics.Info("skipped"), metrics.WithID("a"))
+	m.Emit(metrics.Info("live"), metrics.WithID("c"))
+
+	select {
+	case record := <-sub.C:
+		if record.Entry.Message != "live" || record.Seq != 6 {
+			tests.Failed("Should have received matching live entry: %#v", record)
+		}
+	default:
+		tests.Failed("Should have received matching live entry")
+	}
+	tests.Passed("Should have received matching live entry")
+
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		tests.Failed("Should have closed subscription channel")
+	}
+	tests.Passed("Should have closed subscription channel")
+}
+
+func TestRingHTTPHandler(t *testing.T) {
+	ring := memory.NewRing(10)
+	m := metrics.New(ring)
+
+	m.Emit(metrics.Error(errors.New("bad write")), metrics.WithID("disk"))
+	m.Emit(metrics.Info("ok"), metrics.WithID("disk"))
+
+	res := httptest.NewRecorder()
+	ctx := httptesting.Get("/entries?level=error&id=disk", nil, res)
+	if err := memory.HTTPHandler(ring)(ctx); err != nil {
+		tests.Failed("Should have served entries: %+q", err)
+	}
+	tests.Passed("Should have served entries")
+
+	var records []memory.Record
+	if err := json.Unmarshal(res.Body.Bytes(), &records); err != nil {
+		tests.Failed("Should have served json records: %+q", err)
+	}
+	tests.Passed("Should have served json records")
+
+	if len(records) != 1 || records[0].Entry.Field["error"] != "bad write" {
+		tests.Failed("Should have served matching entry with error message: %#v", records)
+	}
+	tests.Passed("Should have served matching entry with error message")
+
+	res = httptest.NewRecorder()
+	ctx = httptesting.Get("/entries?level=unknown", nil, res)
+	if err := memory.HTTPHandler(ring)(ctx); err == nil {
+		tests.Failed("Should have rejected unknown level")
+	}
+	tests.Passed("Should have rejected unknown level")
+}
@@ -0,0 +1,469 @@
+// Package metricstest provides a recorder processor and fluent assertions for
+// testing code which emits metrics.
+//
+//	rec := metricstest.NewRecorder()
+//	service := NewService(rec.Metrics())
+//	service.Query("users")
+//
+//	rec.Assert(t).
+//		Emitted(metricstest.Match().Level(metrics.InfoLvl).ID("db:query").Field("table", "users")).
+//		NoneAbove(metrics.ErrorLvl)
+package metricstest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// T defines the subset of *testing.T used to report failures.
+type T interface {
+	Errorf(format string, args ...interface{})
+}
+
+// helper marks the calling function as a test helper if supported by the T.
+func helper(t T) {
+	if h, ok := t.(interface {
+		Helper()
+	}); ok {
+		h.Helper()
+	}
+}
+
+//=====================================================================================
+
+// Recorder implements the metrics.Processors interface, recording every Entry
+// it receives for later assertions. It is safe for concurrent use.
+type Recorder struct {
+	ml      sync.Mutex
+	entries []metrics.Entry
+}
+
+// NewRecorder returns a new instance of a Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Handle implements the metrics.Processors interface.
+func (r *Recorder) Handle(en metrics.Entry) error {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	r.entries = append(r.entries, en)
+	return nil
+}
+
+// Metrics returns a metrics.Metrics built from the giving values which delivers
+// into the Recorder.
+func (r *Recorder) Metrics(vals ...interface{}) metrics.Metrics {
+	return metrics.New(append(vals, r)...)
+}
+
+// Entries returns a copy of all recorded entries in the order received.
+func (r *Recorder) Entries() []metrics.Entry {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	entries := make([]metrics.Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Reset removes all recorded entries.
+func (r *Recorder) Reset() {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+	r.entries = nil
+}
+
+// Assert returns a new Assert for the recorded entries which reports into the T.
+func (r *Recorder) Assert(t T) *Assert {
+	return &Assert{t: t, rec: r}
+}
+
+//=====================================================================================
+
+// Matcher defines a set of conditions an Entry is matched against, unset
+// conditions match all entries.
+type Matcher struct {
+	level   *metrics.Level
+	id      *string
+	typ     *string
+	message *string
+	tags    []string
+	keys    []string
+	fields  metrics.Field
+	where   []metrics.FilterFn
+}
+
+// Match returns a new Matcher which matches all entries.
+func Match() *Matcher {
+	return &Matcher{fields: make(metrics.Field)}
+}
+
+// Level sets the level an Entry must have.
+func (m *Matcher) Level(lvl metrics.Level) *Matcher {
+	m.level = &lvl
+	return m
+}
+
+// ID sets the ID an Entry must have.
+func (m *Matcher) ID(id string) *Matcher {
+	m.id = &id
+	return m
+}
+
+// Type sets the type an Entry must have.
+func (m *Matcher) Type(typ string) *Matcher {
+	m.typ = &typ
+	return m
+}
+
+// Message sets the message an Entry must have.
+func (m *Matcher) Message(message string) *Matcher {
+	m.message = &message
+	return m
+}
+
+// Tag adds a tag an Entry must carry.
+func (m *Matcher) Tag(tag string) *Matcher {
+	m.tags = append(m.tags, tag)
+	return m
+}
+
+// Field adds a field value an Entry must carry. Error values in the Entry match
+// an expected string or error with the same message.
+func (m *Matcher) Field(key string, value interface{}) *Matcher {
+	if _, ok := m.fields[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.fields[key] = value
+	return m
+}
+
+// Where adds a custom function an Entry must pass.
+func (m *Matcher) Where(fn metrics.FilterFn) *Matcher {
+	m.where = append(m.where, fn)
+	return m
+}
+
+// Matches returns true/false if the giving Entry matches the Matcher.
+func (m *Matcher) Matches(en metrics.Entry) bool {
+	return len(m.Diff(en)) == 0
+}
+
+// Diff returns a line for every condition of the Matcher the giving Entry fails.
+func (m *Matcher) Diff(en metrics.Entry) []string {
+	var diffs []string
+
+	if m.level != nil && *m.level != en.Level {
+		diffs = append(diffs, fmt.Sprintf("level: want %s, got %s", *m.level, en.Level))
+	}
+
+	if m.id != nil && *m.id != en.ID {
+		diffs = append(diffs, fmt.Sprintf("id: want %q, got %q", *m.id, en.ID))
+	}
+
+	if m.typ != nil && *m.typ != en.Type {
+		diffs = append(diffs, fmt.Sprintf("type: want %q, got %q", *m.typ, en.Type))
+	}
+
+	if m.message != nil && *m.message != en.Message {
+		diffs = append(diffs, fmt.Sprintf("message: want %q, got %q", *m.message, en.Message))
+	}
+
+	for _, tag := range m.tags {
+		if !hasTag(en.Tags, tag) {
+			diffs = append(diffs, fmt.Sprintf("tags: want %q, got %q", tag, en.Tags))
+		}
+	}
+
+	for _, key := range m.keys {
+		want := m.fields[key]
+
+		got, ok := en.Field[key]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("fields[%s]: want %#v, got nothing", key, want))
+			continue
+		}
+
+		if !equal(want, got) {
+			diffs = append(diffs, fmt.Sprintf("fields[%s]: want %#v, got %#v", key, want, got))
+		}
+	}
+
+	for index, fn := range m.where {
+		if !fn(en) {
+			diffs = append(diffs, fmt.Sprintf("where #%d: returned false", index))
+		}
+	}
+
+	return diffs
+}
+
+// String returns a description of the Matcher.
+func (m *Matcher) String() string {
+	var parts []string
+
+	if m.level != nil {
+		parts = append(parts, "level="+m.level.String())
+	}
+
+	if m.id != nil {
+		parts = append(parts, fmt.Sprintf("id=%q", *m.id))
+	}
+
+	if m.typ != nil {
+		parts = append(parts, fmt.Sprintf("type=%q", *m.typ))
+	}
+
+	if m.message != nil {
+		parts = append(parts, fmt.Sprintf("message=%q", *m.message))
+	}
+
+	for _, tag := range m.tags {
+		parts = append(parts, fmt.Sprintf("tag=%q", tag))
+	}
+
+	for _, key := range m.keys {
+		parts = append(parts, fmt.Sprintf("fields[%s]=%#v", key, m.fields[key]))
+	}
+
+	if len(m.where) != 0 {
+		parts = append(parts, fmt.Sprintf("where=%d", len(m.where)))
+	}
+
+	if len(parts) == 0 {
+		return "{any}"
+	}
+
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, item := range tags {
+		if item == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// equal returns true/false if the recorded value equals the expected value.
+func equal(want interface{}, got interface{}) bool {
+	if reflect.DeepEqual(want, got) {
+		return true
+	}
+
+	if err, ok := got.(error); ok {
+		switch item := want.(type) {
+		case string:
+			return err.Error() == item
+		case error:
+			return err.Error() == item.Error()
+		}
+	}
+
+	return false
+}
+
+//=====================================================================================
+
+// Assert provides fluent assertions against the entries of a Recorder.
+type Assert struct {
+	t      T
+	rec    *Recorder
+	window time.Duration
+}
+
+// Within returns a copy of the Assert whoes assertions wait up to the giving
+// window for matching entries to be recorded, for code which emits asynchronously.
+func (a *Assert) Within(window time.Duration) *Assert {
+	clone := *a
+	clone.window = window
+	return &clone
+}
+
+// poll returns the recorded entries once the condition passes or the window elapses.
+func (a *Assert) poll(condition func([]metrics.Entry) bool) ([]metrics.Entry, bool) {
+	deadline := time.Now().Add(a.window)
+	for {
+		entries := a.rec.Entries()
+		if condition(entries) {
+			return entries, true
+		}
+
+		if !time.Now().Before(deadline) {
+			return entries, false
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Emitted asserts an Entry matching the Matcher was recorded.
+func (a *Assert) Emitted(m *Matcher) *Assert {
+	helper(a.t)
+
+	entries, ok := a.poll(func(entries []metrics.Entry) bool {
+		return indexOf(entries, 0, m) >= 0
+	})
+
+	if !ok {
+		a.t.Errorf("metricstest: expected entry matching %s\n%s", m, report(entries, m))
+	}
+
+	return a
+}
+
+// NotEmitted asserts no Entry matching the Matcher was recorded.
+func (a *Assert) NotEmitted(m *Matcher) *Assert {
+	helper(a.t)
+
+	entries := a.rec.Entries()
+	if index := indexOf(entries, 0, m); index >= 0 {
+		a.t.Errorf("metricstest: expected no entry matching %s, found #%d: %s", m, index, describe(entries[index]))
+	}
+
+	return a
+}
+
+// Count asserts exactly n entries matching the Matcher were recorded.
+func (a *Assert) Count(n int, m *Matcher) *Assert {
+	helper(a.t)
+
+	var total int
+	entries, ok := a.poll(func(entries []metrics.Entry) bool {
+		total = 0
+		for _, en := range entries {
+			if m.Matches(en) {
+				total++
+			}
+		}
+		return total == n
+	})
+
+	if !ok {
+		a.t.Errorf("metricstest: expected %d entries matching %s, found %d\n%s", n, m, total, list(entries))
+	}
+
+	return a
+}
+
+// InOrder asserts entries matching each Matcher were recorded in the order of
+// the matchers, other entries may be recorded in between.
+func (a *Assert) InOrder(matchers ...*Matcher) *Assert {
+	helper(a.t)
+
+	var failed int
+	entries, ok := a.poll(func(entries []metrics.Entry) bool {
+		from := 0
+		for index, m := range matchers {
+			at := indexOf(entries, from, m)
+			if at < 0 {
+				failed = index
+				return false
+			}
+			from = at + 1
+		}
+		return true
+	})
+
+	if !ok {
+		a.t.Errorf("metricstest: expected entry matching %s in order after %d matched entries\n%s", matchers[failed], failed, report(entries, matchers[failed]))
+	}
+
+	return a
+}
+
+// NoneAbove asserts no Entry more severe than the giving Level was recorded,
+// where RedAlertLvl is the most severe.
+func (a *Assert) NoneAbove(lvl metrics.Level) *Assert {
+	helper(a.t)
+
+	var found []string
+	for index, en := range a.rec.Entries() {
+		if en.Level < lvl {
+			found = append(found, fmt.Sprintf("  #%d %s", index, describe(en)))
+		}
+	}
+
+	if len(found) != 0 {
+		a.t.Errorf("metricstest: expected no entries above %s, found:\n%s", lvl, strings.Join(found, "\n"))
+	}
+
+	return a
+}
+
+// indexOf returns the index of the first Entry from the giving index matching the Matcher.
+func indexOf(entries []metrics.Entry, from int, m *Matcher) int {
+	for index := from; index < len(entries); index++ {
+		if m.Matches(entries[index]) {
+			return index
+		}
+	}
+	return -1
+}
+
+// report returns the diff against the closest Entry to the Matcher followed by
+// the list of all recorded entries.
+func report(entries []metrics.Entry, m *Matcher) string {
+	if len(entries) == 0 {
+		return "no entries were recorded"
+	}
+
+	closest, diffs := -1, []string(nil)
+	for index, en := range entries {
+		diff := m.Diff(en)
+		if closest < 0 || len(diff) < len(diffs) {
+			closest, diffs = index, diff
+		}
+	}
+
+	var bu bytes.Buffer
+	fmt.Fprintf(&bu, "closest entry #%d: %s\n", closest, describe(entries[closest]))
+	for _, diff := range diffs {
+		fmt.Fprintf(&bu, "    %s\n", diff)
+	}
+
+	bu.WriteString(list(entries))
+	return bu.String()
+}
+
+// list returns a line for every recorded entry.
+func list(entries []metrics.Entry) string {
+	var bu bytes.Buffer
+	bu.WriteString("recorded entries:")
+
+	for index, en := range entries {
+		fmt.Fprintf(&bu, "\n  #%d %s", index, describe(en))
+	}
+
+	return bu.String()
+}
+
+// describe returns a single line description of the giving Entry.
+func describe(en metrics.Entry) string {
+	var bu bytes.Buffer
+	fmt.Fprintf(&bu, "%s id=%q message=%q", en.Level, en.ID, en.Message)
+
+	keys := make([]string, 0, len(en.Field))
+	for key := range en.Field {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&bu, " %s=%v", key, en.Field[key])
+	}
+
+	return bu.String()
+}
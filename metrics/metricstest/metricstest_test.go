@@ -0,0 +1,75 @@
+package metricstest_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/metricstest"
+	"github.com/influx6/faux/tests"
+)
+
+type fakeT struct {
+	failures []string
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	rec := metricstest.NewRecorder()
+	m := rec.Metrics()
+
+	m.Emit(metrics.Info("connecting"), metrics.WithID("db:connect"))
+	m.Emit(metrics.Error(errors.New("timeout")), metrics.WithID("db:query"), metrics.With("table", "users"))
+	m.Emit(metrics.Info("done"), metrics.WithID("db:close"))
+
+	rec.Assert(t).
+		Emitted(metricstest.Match().Level(metrics.ErrorLvl).ID("db:query").Field("table", "users").Field("error", "timeout")).
+		InOrder(metricstest.Match().ID("db:connect"), metricstest.Match().ID("db:close")).
+		Count(2, metricstest.Match().Level(metrics.InfoLvl)).
+		NotEmitted(metricstest.Match().Level(metrics.RedAlertLvl)).
+		NoneAbove(metrics.ErrorLvl)
+	tests.Passed("Should have passed matching assertions")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		m.Emit(metrics.Info("late"), metrics.WithID("db:late"))
+	}()
+
+	rec.Assert(t).Within(time.Second).Emitted(metricstest.Match().ID("db:late"))
+	tests.Passed("Should have waited for asynchronous entry")
+}
+
+func TestRecorderFailures(t *testing.T) {
+	rec := metricstest.NewRecorder()
+	m := rec.Metrics()
+
+	m.Emit(metrics.Info("done"), metrics.WithID("db:query"), metrics.With("table", "orders"))
+	m.Emit(metrics.YellowAlert(errors.New("disk"), "disk almost full"), metrics.WithID("disk"))
+
+	var fake fakeT
+	rec.Assert(&fake).
+		Emitted(metricstest.Match().ID("db:query").Field("table", "users")).
+		InOrder(metricstest.Match().ID("disk"), metricstest.Match().ID("db:query")).
+		NoneAbove(metrics.ErrorLvl)
+
+	if len(fake.failures) != 3 {
+		tests.Failed("Should have reported three failures: %#v", fake.failures)
+	}
+	tests.Passed("Should have reported three failures")
+
+	if !strings.Contains(fake.failures[0], `fields[table]: want "users", got "orders"`) {
+		tests.Failed("Should have reported field diff: %s", fake.failures[0])
+	}
+	tests.Passed("Should have reported field diff")
+
+	if !strings.Contains(fake.failures[2], `YELLOWALERT id="disk"`) {
+		tests.Failed("Should have reported entry above level: %s", fake.failures[2])
+	}
+	tests.Passed("Should have reported entry above level")
+}
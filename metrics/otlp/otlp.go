@@ -0,0 +1,722 @@
+// Package otlp exports metrics.Entry values into an OpenTelemetry collector using
+// the OTLP/HTTP JSON encoding. Entries emitted by a metrics.Span are exported as
+// spans while all others are exported as log records.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// paths of the OTLP/HTTP endpoints.
+const (
+	LogsPath   = "/v1/logs"
+	TracesPath = "/v1/traces"
+)
+
+// scopeName defines the instrumentation scope set on all exported records.
+const scopeName = "github.com/influx6/faux/metrics"
+
+// errors.
+var (
+	ErrNoEndpoint = errors.New("otlp endpoint not provided")
+)
+
+// StatusError defines the error returned when the collector responds with a
+// non successful status code.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+// Error returns the error string. Implements error interface.
+func (s StatusError) Error() string {
+	return fmt.Sprintf("otlp collector responded with status %d: %s", s.Code, s.Body)
+}
+
+// Config defines the configuration used by a Client.
+type Config struct {
+	// Endpoint sets the base url of the collector, e.g http://localhost:4318.
+	Endpoint string
+
+	// ServiceName sets the service.name resource attribute.
+	ServiceName string
+
+	// Resource sets extra attributes describing the resource producing entries.
+	Resource metrics.Field
+
+	// Headers sets extra headers sent with every request.
+	Headers map[string]string
+
+	// Client sets the http.Client used, defaults to http.DefaultClient.
+	Client *http.Client
+
+	// MaxRetries sets the total retries made for a failed request.
+	MaxRetries int
+
+	// Backoff sets the initial wait before retrying, doubled on every retry.
+	Backoff time.Duration
+
+	// MaxBackoff sets the maximum wait between retries.
+	MaxBackoff time.Duration
+}
+
+// Client implements the delivery of entries into a OTLP/HTTP collector.
+type Client struct {
+	config Config
+}
+
+// NewClient returns a new instance of a Client.
+func NewClient(config Config) *Client {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	if config.Backoff <= 0 {
+		config.Backoff = 100 * time.Millisecond
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Second
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &Client{config: config}
+}
+
+// Exporter returns a metrics.MetricConsumer which delivers batches of entries
+// into the collector described by the provided Config. Logs and spans are
+// batched and retried apart, so a failure of one never delivers the other twice.
+func Exporter(config Config, maxBatch int, maxwait time.Duration) metrics.BatchMetricConsumer {
+	client := NewClient(config)
+
+	return &exporter{
+		logs:  metrics.BatchConsumer(maxBatch, maxwait, client.ExportLogs),
+		spans: metrics.BatchConsumer(maxBatch, maxwait, client.ExportSpans),
+	}
+}
+
+// Export delivers the giving entries into the collector, sending span entries
+// to the traces endpoint and all others to the logs endpoint. Both are
+// attempted even if one fails, the first error is returned. As retrying the
+// whole call would deliver the successful signal again, callers retrying should
+// use ExportLogs and ExportSpans instead.
+func (c *Client) Export(entries []metrics.Entry) error {
+	logErr := c.ExportLogs(entries)
+	spanErr := c.ExportSpans(entries)
+
+	if logErr != nil {
+		return logErr
+	}
+
+	return spanErr
+}
+
+// ExportLogs delivers the giving entries which are not spans into the logs endpoint.
+func (c *Client) ExportLogs(entries []metrics.Entry) error {
+	if c.config.Endpoint == "" {
+		return ErrNoEndpoint
+	}
+
+	var logs []metrics.Entry
+	for _, en := range entries {
+		if en.Type != metrics.SpanType {
+			logs = append(logs, en)
+		}
+	}
+
+	if len(logs) == 0 {
+		return nil
+	}
+
+	data, err := EncodeLogs(c.config, logs)
+	if err != nil {
+		return err
+	}
+
+	return c.post(LogsPath, data)
+}
+
+// ExportSpans delivers the giving entries which are spans into the traces endpoint.
+func (c *Client) ExportSpans(entries []metrics.Entry) error {
+	if c.config.Endpoint == "" {
+		return ErrNoEndpoint
+	}
+
+	var spans []metrics.Entry
+	for _, en := range entries {
+		if en.Type == metrics.SpanType {
+			spans = append(spans, en)
+		}
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := EncodeSpans(c.config, spans)
+	if err != nil {
+		return err
+	}
+
+	return c.post(TracesPath, data)
+}
+
+// exporter implements the metrics.BatchMetricConsumer interface, routing logs
+// and spans into their own batch consumers.
+type exporter struct {
+	logs  metrics.BatchMetricConsumer
+	spans metrics.BatchMetricConsumer
+}
+
+// Handle implements the metrics.Processors interface.
+func (e *exporter) Handle(en metrics.Entry) error {
+	if en.Type == metrics.SpanType {
+		return e.spans.Handle(en)
+	}
+	return e.logs.Handle(en)
+}
+
+// Run runs both batch consumers till the giving channel is closed.
+func (e *exporter) Run(closer <-chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.spans.Run(closer)
+	}()
+
+	e.logs.Run(closer)
+	<-done
+}
+
+// Flush commits the current batches of both consumers.
+func (e *exporter) Flush() error {
+	logErr := e.logs.Flush()
+	spanErr := e.spans.Flush()
+
+	if logErr != nil {
+		return logErr
+	}
+
+	return spanErr
+}
+
+// Err returns the error from the last failed commit of either consumer.
+func (e *exporter) Err() error {
+	if err := e.logs.Err(); err != nil {
+		return err
+	}
+	return e.spans.Err()
+}
+
+// ClearErr clears the errors of both consumers.
+func (e *exporter) ClearErr() {
+	e.logs.ClearErr()
+	e.spans.ClearErr()
+}
+
+// Stats returns the sum of the counters of both consumers.
+func (e *exporter) Stats() metrics.BatchStats {
+	logs, spans := e.logs.Stats(), e.spans.Stats()
+
+	return metrics.BatchStats{
+		Queued:    logs.Queued + spans.Queued,
+		Committed: logs.Committed + spans.Committed,
+		Dropped:   logs.Dropped + spans.Dropped,
+		Failed:    logs.Failed + spans.Failed,
+		Retries:   logs.Retries + spans.Retries,
+	}
+}
+
+// post delivers the payload to the giving path, retrying with backoff on
+// network errors and retryable status codes.
+func (c *Client) post(path string, payload []byte) error {
+	wait := c.config.Backoff
+
+	var err error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+
+			wait *= 2
+			if wait > c.config.MaxBackoff {
+				wait = c.config.MaxBackoff
+			}
+		}
+
+		var retry bool
+		if retry, err = c.send(path, payload); err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+// send makes a single request, returning true if a failure can be retried.
+func (c *Client) send(path string, payload []byte) (bool, error) {
+	req, err := http.NewRequest("POST", c.config.Endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := c.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		io.Copy(ioutil.Discard, res.Body)
+		return false, nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	statusErr := StatusError{Code: res.StatusCode, Body: string(body)}
+
+	switch res.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, statusErr
+	}
+
+	return false, statusErr
+}
+
+//=====================================================================================
+
+// EncodeLogs returns the OTLP/JSON ExportLogsServiceRequest for the giving entries.
+func EncodeLogs(config Config, entries []metrics.Entry) ([]byte, error) {
+	records := make([]logRecord, 0, len(entries))
+	for _, en := range entries {
+		records = append(records, toLogRecord(en))
+	}
+
+	return json.Marshal(logsRequest{
+		ResourceLogs: []resourceLogs{
+			{
+				Resource: toResource(config),
+				ScopeLogs: []scopeLogs{
+					{Scope: scope{Name: scopeName}, LogRecords: records},
+				},
+			},
+		},
+	})
+}
+
+// EncodeSpans returns the OTLP/JSON ExportTraceServiceRequest for the giving
+// entries emitted by metrics.Span.
+func EncodeSpans(config Config, entries []metrics.Entry) ([]byte, error) {
+	items := make([]span, 0, len(entries))
+	for _, en := range entries {
+		items = append(items, toSpan(en))
+	}
+
+	return json.Marshal(tracesRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: toResource(config),
+				ScopeSpans: []scopeSpans{
+					{Scope: scope{Name: scopeName}, Spans: items},
+				},
+			},
+		},
+	})
+}
+
+// Severity returns the OpenTelemetry severity number for the giving level.
+func Severity(lvl metrics.Level) int {
+	switch lvl {
+	case metrics.InfoLvl:
+		return 9
+	case metrics.ErrorLvl:
+		return 17
+	case metrics.YellowAlertLvl:
+		return 19
+	case metrics.RedAlertLvl:
+		return 21
+	}
+
+	return 0
+}
+
+func toResource(config Config) resource {
+	var res resource
+	if config.ServiceName != "" {
+		res.Attributes = append(res.Attributes, keyValue{Key: "service.name", Value: toAnyValue(config.ServiceName)})
+	}
+
+	res.Attributes = append(res.Attributes, toAttributes(config.Resource)...)
+	return res
+}
+
+func toLogRecord(en metrics.Entry) logRecord {
+	record := logRecord{
+		TimeUnixNano:         unixNano(en.Time),
+		ObservedTimeUnixNano: unixNano(time.Now()),
+		SeverityNumber:       Severity(en.Level),
+		SeverityText:         en.Level.String(),
+		Body:                 toAnyValue(en.Message),
+		Attributes:           toAttributes(en.Field),
+	}
+
+	if traceID, ok := en.Field.GetString("trace_id"); ok {
+		record.TraceID = traceID
+	}
+
+	if spanID, ok := en.Field.GetString("span_id"); ok {
+		record.SpanID = spanID
+	}
+
+	record.Attributes = append(record.Attributes, entryAttributes(en)...)
+	return record
+}
+
+func toSpan(en metrics.Entry) span {
+	item := span{
+		Name:              en.Message,
+		Kind:              1,
+		EndTimeUnixNano:   unixNano(en.Time),
+		StartTimeUnixNano: unixNano(en.Time),
+	}
+
+	attrs := make(metrics.Field, len(en.Field))
+	for key, value := range en.Field {
+		switch key {
+		case "trace_id":
+			item.TraceID, _ = value.(string)
+		case "span_id":
+			item.SpanID, _ = value.(string)
+		case "parent_span_id":
+			item.ParentSpanID, _ = value.(string)
+		case "start":
+			if start, ok := value.(time.Time); ok {
+				item.StartTimeUnixNano = unixNano(start)
+			}
+		case "end":
+			if end, ok := value.(time.Time); ok {
+				item.EndTimeUnixNano = unixNano(end)
+			}
+		case "duration":
+		case "error":
+			item.Status.Code = 2
+			item.Status.Message = fmt.Sprint(value)
+		default:
+			attrs[key] = value
+		}
+	}
+
+	item.Attributes = toAttributes(attrs)
+
+	for _, lapse := range en.Timelapse {
+		item.Events = append(item.Events, event{
+			TimeUnixNano: unixNano(lapse.Time),
+			Name:         lapse.Message,
+			Attributes:   toAttributes(lapse.Field),
+		})
+	}
+
+	return item
+}
+
+// entryAttributes returns the attributes describing the Entry itself rather
+// than its fields.
+func entryAttributes(en metrics.Entry) []keyValue {
+	var attrs []keyValue
+
+	if en.ID != "" {
+		attrs = append(attrs, keyValue{Key: "entry.id", Value: toAnyValue(en.ID)})
+	}
+
+	if en.Type != "" {
+		attrs = append(attrs, keyValue{Key: "entry.type", Value: toAnyValue(en.Type)})
+	}
+
+	if en.Function != "" {
+		attrs = append(attrs,
+			keyValue{Key: "code.function", Value: toAnyValue(en.Function)},
+			keyValue{Key: "code.filepath", Value: toAnyValue(en.File)},
+			keyValue{Key: "code.lineno", Value: toAnyValue(en.Line)},
+		)
+	}
+
+	if len(en.Tags) != 0 {
+		attrs = append(attrs, keyValue{Key: "entry.tags", Value: toAnyValue(en.Tags)})
+	}
+
+	if en.Trace.Function != "" || len(en.Trace.Stack) != 0 {
+		attrs = append(attrs, keyValue{Key: "entry.trace", Value: toAnyValue(map[string]interface{}{
+			"package":  en.Trace.Package,
+			"file":     en.Trace.File,
+			"function": en.Trace.Function,
+			"line":     en.Trace.LineNumber,
+			"stack":    string(en.Trace.Stack),
+			"comments": en.Trace.Comments,
+			"time":     en.Trace.Time,
+		})})
+	}
+
+	if len(en.Timelapse) != 0 {
+		lapses := make([]interface{}, 0, len(en.Timelapse))
+		for _, lapse := range en.Timelapse {
+			lapses = append(lapses, map[string]interface{}{
+				"message": lapse.Message,
+				"time":    lapse.Time,
+				"fields":  map[string]interface{}(lapse.Field),
+			})
+		}
+
+		attrs = append(attrs, keyValue{Key: "entry.timelapse", Value: toAnyValue(lapses)})
+	}
+
+	return attrs
+}
+
+func toAttributes(f metrics.Field) []keyValue {
+	if len(f) == 0 {
+		return nil
+	}
+
+	attrs := make([]keyValue, 0, len(f))
+	for key, value := range f {
+		attrs = append(attrs, keyValue{Key: key, Value: toAnyValue(value)})
+	}
+
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return attrs
+}
+
+func toAnyValue(value interface{}) *anyValue {
+	switch item := value.(type) {
+	case nil:
+		return &anyValue{}
+	case string:
+		return &anyValue{StringValue: &item}
+	case bool:
+		return &anyValue{BoolValue: &item}
+	case int:
+		return intValue(int64(item))
+	case int8:
+		return intValue(int64(item))
+	case int16:
+		return intValue(int64(item))
+	case int32:
+		return intValue(int64(item))
+	case int64:
+		return intValue(item)
+	case uint:
+		return uintValue(uint64(item))
+	case uint8:
+		return intValue(int64(item))
+	case uint16:
+		return intValue(int64(item))
+	case uint32:
+		return intValue(int64(item))
+	case uint64:
+		return uintValue(item)
+	case float32:
+		return floatValue(float64(item))
+	case float64:
+		return floatValue(item)
+	case time.Time:
+		val := item.UTC().Format(time.RFC3339Nano)
+		return &anyValue{StringValue: &val}
+	case time.Duration:
+		val := item.String()
+		return &anyValue{StringValue: &val}
+	case error:
+		val := item.Error()
+		return &anyValue{StringValue: &val}
+	case fmt.Stringer:
+		val := item.String()
+		return &anyValue{StringValue: &val}
+	case []string:
+		values := make([]*anyValue, 0, len(item))
+		for _, elem := range item {
+			values = append(values, toAnyValue(elem))
+		}
+		return &anyValue{ArrayValue: &arrayValue{Values: values}}
+	case []interface{}:
+		values := make([]*anyValue, 0, len(item))
+		for _, elem := range item {
+			values = append(values, toAnyValue(elem))
+		}
+		return &anyValue{ArrayValue: &arrayValue{Values: values}}
+	case metrics.Field:
+		return &anyValue{KvlistValue: &kvList{Values: toAttributes(item)}}
+	case map[string]interface{}:
+		return &anyValue{KvlistValue: &kvList{Values: toAttributes(metrics.Field(item))}}
+	case map[string]string:
+		f := make(metrics.Field, len(item))
+		for k, v := range item {
+			f[k] = v
+		}
+		return &anyValue{KvlistValue: &kvList{Values: toAttributes(f)}}
+	case http.Header:
+		return toAnyValue(map[string][]string(item))
+	case map[string][]string:
+		f := make(metrics.Field, len(item))
+		for k, v := range item {
+			f[k] = v
+		}
+		return &anyValue{KvlistValue: &kvList{Values: toAttributes(f)}}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		val := fmt.Sprintf("%#v", value)
+		return &anyValue{StringValue: &val}
+	}
+
+	val := string(data)
+	return &anyValue{StringValue: &val}
+}
+
+func intValue(value int64) *anyValue {
+	val := strconv.FormatInt(value, 10)
+	return &anyValue{IntValue: &val}
+}
+
+// uintValue returns values which overflow the signed 64 bit integers of OTLP
+// as strings.
+func uintValue(value uint64) *anyValue {
+	if value > math.MaxInt64 {
+		val := strconv.FormatUint(value, 10)
+		return &anyValue{StringValue: &val}
+	}
+	return intValue(int64(value))
+}
+
+// floatValue returns NaN and infinities as strings, as json can not encode them.
+func floatValue(value float64) *anyValue {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		val := strconv.FormatFloat(value, 'g', -1, 64)
+		return &anyValue{StringValue: &val}
+	}
+	return &anyValue{DoubleValue: &value}
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+//=====================================================================================
+// OTLP/JSON wire types, see https://github.com/open-telemetry/opentelemetry-proto.
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 *anyValue  `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Events            []event    `json:"events,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []keyValue `json:"attributes,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type keyValue struct {
+	Key   string    `json:"key"`
+	Value *anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *kvList     `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []*anyValue `json:"values"`
+}
+
+type kvList struct {
+	Values []keyValue `json:"values"`
+}
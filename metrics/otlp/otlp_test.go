@@ -0,0 +1,185 @@
+package otlp_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/otlp"
+	"github.com/influx6/faux/tests"
+)
+
+type collector struct {
+	ml       sync.Mutex
+	failures int
+	payloads map[string][]map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.payloads[r.URL.Path] = append(c.payloads[r.URL.Path], payload)
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestClientExport(t *testing.T) {
+	col := &collector{failures: 1, payloads: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(col)
+	defer server.Close()
+
+	client := otlp.NewClient(otlp.Config{
+		Endpoint:    server.URL,
+		ServiceName: "tests",
+		MaxRetries:  2,
+		Backoff:     time.Millisecond,
+	})
+
+	var entries []metrics.Entry
+	m := metrics.New(metrics.DoWith(func(en metrics.Entry) error {
+		entries = append(entries, en)
+		return nil
+	}))
+
+	m.Emit(metrics.Errorf("failed to connect: %d", 20), metrics.WithID("db:connect"), metrics.Tags("db"))
+
+	_, span := metrics.StartSpan(context.Background(), m, "request")
+	span.End()
+
+	if err := client.Export(entries); err != nil {
+		tests.Failed("Should have exported entries: %+q", err)
+	}
+	tests.Passed("Should have exported entries")
+
+	if len(col.payloads[otlp.LogsPath]) != 1 {
+		tests.Failed("Should have received a logs payload after retry")
+	}
+	tests.Passed("Should have received a logs payload after retry")
+
+	if len(col.payloads[otlp.TracesPath]) != 1 {
+		tests.Failed("Should have received a traces payload")
+	}
+	tests.Passed("Should have received a traces payload")
+
+	resourceLogs := col.payloads[otlp.LogsPath][0]["resourceLogs"].([]interface{})
+	scopeLogs := resourceLogs[0].(map[string]interface{})["scopeLogs"].([]interface{})
+	records := scopeLogs[0].(map[string]interface{})["logRecords"].([]interface{})
+	record := records[0].(map[string]interface{})
+
+	if record["severityNumber"].(float64) != 17 || record["severityText"] != "ERROR" {
+		tests.Failed("Should have mapped error level: %#v", record)
+	}
+	tests.Passed("Should have mapped error level")
+
+	body := record["body"].(map[string]interface{})
+	if body["stringValue"] != "failed to connect: 20" {
+		tests.Failed("Should have set message as body: %#v", body)
+	}
+	tests.Passed("Should have set message as body")
+}
+
+func TestClientExportFailsOnBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := otlp.NewClient(otlp.Config{Endpoint: server.URL, MaxRetries: 3, Backoff: time.Millisecond})
+	err := client.Export([]metrics.Entry{{Message: "hello", Level: metrics.InfoLvl}})
+	if statusErr, ok := err.(otlp.StatusError); !ok || statusErr.Code != http.StatusBadRequest {
+		tests.Failed("Should have failed with status error: %+q", err)
+	}
+	tests.Passed("Should have failed with status error")
+}
+
+func TestEncodeLogsSpecialValues(t *testing.T) {
+	data, err := otlp.EncodeLogs(otlp.Config{}, []metrics.Entry{{
+		Message: "values",
+		Field: metrics.Field{
+			"big": uint64(math.MaxUint64),
+			"nan": math.NaN(),
+			"inf": math.Inf(-1),
+		},
+	}})
+	if err != nil {
+		tests.Failed("Should have encoded uint64 and non-finite floats: %+q", err)
+	}
+	tests.Passed("Should have encoded uint64 and non-finite floats")
+
+	for _, value := range []string{`"18446744073709551615"`, `"NaN"`, `"-Inf"`} {
+		if !strings.Contains(string(data), `"stringValue":`+value) {
+			tests.Failed("Should have encoded %s as string value: %s", value, data)
+		}
+	}
+	tests.Passed("Should have encoded overflowing and non-finite values as strings")
+}
+
+func TestClientExportSignalsApart(t *testing.T) {
+	var ml sync.Mutex
+	received := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ml.Lock()
+		defer ml.Unlock()
+
+		if r.URL.Path == otlp.LogsPath {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		received[r.URL.Path]++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var entries []metrics.Entry
+	m := metrics.New(metrics.DoWith(func(en metrics.Entry) error {
+		entries = append(entries, en)
+		return nil
+	}))
+
+	m.Emit(metrics.Info("started"))
+	_, span := metrics.StartSpan(context.Background(), m, "request")
+	span.End()
+
+	client := otlp.NewClient(otlp.Config{Endpoint: server.URL, Backoff: time.Millisecond})
+	if err := client.Export(entries); err == nil {
+		tests.Failed("Should have failed to export logs")
+	}
+	tests.Passed("Should have failed to export logs")
+
+	if received[otlp.TracesPath] != 1 {
+		tests.Failed("Should have delivered spans despite failed logs: %+v", received)
+	}
+
+	if err := client.ExportSpans(entries); err != nil || received[otlp.TracesPath] != 2 {
+		tests.Failed("Should have delivered spans alone: %+q %+v", err, received)
+	}
+
+	if err := client.ExportLogs(entries); err == nil || received[otlp.TracesPath] != 2 {
+		tests.Failed("Should have retried logs without delivering spans: %+v", received)
+	}
+	tests.Passed("Should have exported logs and spans apart")
+}
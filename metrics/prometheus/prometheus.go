@@ -0,0 +1,233 @@
+// Package prometheus renders snapshots of metrics instruments in the Prometheus
+// text exposition format, see https://prometheus.io/docs/instrumenting/exposition_formats/.
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influx6/faux/httputil"
+	"github.com/influx6/faux/metrics"
+)
+
+// ContentType defines the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Snapshotter defines an interface which exposes a single method to retrieve
+// the current snapshots of a set of instruments, it is implemented by metrics.Registry.
+type Snapshotter interface {
+	Snapshot() []metrics.InstrumentSnapshot
+}
+
+// Handler returns a http.Handler which renders the snapshots of the provided
+// Snapshotter in the text exposition format on every request.
+func Handler(source Snapshotter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bu bytes.Buffer
+		if err := Write(&bu, source.Snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(bu.Bytes())
+	})
+}
+
+// HTTPHandler returns a httputil.Handler which renders the snapshots of the provided
+// Snapshotter in the text exposition format on every request.
+func HTTPHandler(source Snapshotter) httputil.Handler {
+	return func(ctx *httputil.Context) error {
+		var bu bytes.Buffer
+		if err := Write(&bu, source.Snapshot()); err != nil {
+			return err
+		}
+
+		return ctx.Blob(http.StatusOK, ContentType, bu.Bytes())
+	}
+}
+
+// Write writes the giving snapshots into the writer in the text exposition format.
+// Labels named le or quantile, which the format reserves, are written as
+// exported_le and exported_quantile.
+func Write(w io.Writer, snapshots []metrics.InstrumentSnapshot) error {
+	bw := bufio.NewWriter(w)
+
+	for _, snap := range snapshots {
+		name := SanitizeName(snap.Name)
+
+		if snap.Help != "" {
+			bw.WriteString("# HELP ")
+			bw.WriteString(name)
+			bw.WriteByte(' ')
+			bw.WriteString(EscapeHelp(snap.Help))
+			bw.WriteByte('\n')
+		}
+
+		bw.WriteString("# TYPE ")
+		bw.WriteString(name)
+		bw.WriteByte(' ')
+		bw.WriteString(typeOf(snap.Kind))
+		bw.WriteByte('\n')
+
+		for _, series := range snap.Series {
+			if snap.Kind != metrics.HistogramKind {
+				writeSample(bw, name, series.Labels, "", "", series.Value)
+				continue
+			}
+
+			for _, bucket := range series.Buckets {
+				writeSample(bw, name+"_bucket", series.Labels, "le", FormatFloat(bucket.UpperBound), float64(bucket.Count))
+			}
+
+			writeSample(bw, name+"_bucket", series.Labels, "le", "+Inf", float64(series.Count))
+			writeSample(bw, name+"_sum", series.Labels, "", "", series.Sum)
+			writeSample(bw, name+"_count", series.Labels, "", "", float64(series.Count))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// reserved defines the label names set by the exposition format itself, user
+// labels of the same name are prefixed with "exported_".
+var reserved = map[string]bool{
+	"le":       true,
+	"quantile": true,
+}
+
+// writeSample writes a single sample line with the labels sorted by name,
+// an extra label is appended last if its name is not empty.
+func writeSample(bw *bufio.Writer, name string, labels metrics.Labels, extraName string, extraValue string, value float64) {
+	bw.WriteString(name)
+
+	if len(labels) != 0 || extraName != "" {
+		values := make(map[string]string, len(labels))
+		names := make([]string, 0, len(labels))
+		for label, value := range labels {
+			label = SanitizeLabel(label)
+			if reserved[label] {
+				label = "exported_" + label
+			}
+
+			values[label] = value
+			names = append(names, label)
+		}
+
+		sort.Strings(names)
+
+		bw.WriteByte('{')
+		for index, label := range names {
+			if index > 0 {
+				bw.WriteByte(',')
+			}
+
+			writeLabel(bw, label, values[label])
+		}
+
+		if extraName != "" {
+			if len(names) != 0 {
+				bw.WriteByte(',')
+			}
+
+			writeLabel(bw, extraName, extraValue)
+		}
+		bw.WriteByte('}')
+	}
+
+	bw.WriteByte(' ')
+	bw.WriteString(FormatFloat(value))
+	bw.WriteByte('\n')
+}
+
+func writeLabel(bw *bufio.Writer, name string, value string) {
+	bw.WriteString(name)
+	bw.WriteString(`="`)
+	bw.WriteString(EscapeLabelValue(value))
+	bw.WriteByte('"')
+}
+
+// typeOf returns the exposition type for the giving instrument kind.
+func typeOf(kind metrics.InstrumentKind) string {
+	switch kind {
+	case metrics.CounterKind:
+		return "counter"
+	case metrics.GaugeKind:
+		return "gauge"
+	case metrics.HistogramKind:
+		return "histogram"
+	}
+
+	return "untyped"
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// EscapeHelp escapes backslashes and line feeds within a HELP text.
+func EscapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// EscapeLabelValue escapes backslashes, double quotes and line feeds within
+// a label value.
+func EscapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// FormatFloat returns the exposition representation of the giving value.
+func FormatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// SanitizeName returns the giving metric name with all invalid characters
+// replaced with an underscore.
+func SanitizeName(name string) string {
+	return sanitize(name, true)
+}
+
+// SanitizeLabel returns the giving label name with all invalid characters
+// replaced with an underscore.
+func SanitizeLabel(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+
+	out := []byte(name)
+	for index, ch := range out {
+		switch {
+		case ch == '_', ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z':
+			continue
+		case ch == ':' && allowColon:
+			continue
+		case ch >= '0' && ch <= '9' && index > 0:
+			continue
+		}
+
+		out[index] = '_'
+	}
+
+	return string(out)
+}
@@ -0,0 +1,94 @@
+package prometheus_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/prometheus"
+	"github.com/influx6/faux/tests"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Counter("http_requests_total", "Total requests\nserved.").Add(3, metrics.Labels{"path": `/a"b`, "method": "GET"})
+	reg.Gauge("queue_size", "").Set(4.5, nil)
+
+	hist := reg.Histogram("latency_seconds", "Request latency.", 0.1, 1)
+	hist.Observe(0.05, nil)
+	hist.Observe(0.5, nil)
+	hist.Observe(2, nil)
+
+	server := httptest.NewServer(prometheus.Handler(reg))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		tests.Failed("Should have scraped handler: %+q", err)
+	}
+	tests.Passed("Should have scraped handler")
+
+	defer res.Body.Close()
+
+	if res.Header.Get("Content-Type") != prometheus.ContentType {
+		tests.Failed("Should have received exposition content type: %+q", res.Header.Get("Content-Type"))
+	}
+	tests.Passed("Should have received exposition content type")
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		tests.Failed("Should have read response body: %+q", err)
+	}
+
+	expected := []string{
+		`# HELP http_requests_total Total requests\nserved.`,
+		`# TYPE http_requests_total counter`,
+		`http_requests_total{method="GET",path="/a\"b"} 3`,
+		`# TYPE queue_size gauge`,
+		`queue_size 4.5`,
+		`# TYPE latency_seconds histogram`,
+		`latency_seconds_bucket{le="0.1"} 1`,
+		`latency_seconds_bucket{le="1"} 2`,
+		`latency_seconds_bucket{le="+Inf"} 3`,
+		`latency_seconds_sum 2.55`,
+		`latency_seconds_count 3`,
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	for _, line := range expected {
+		var found bool
+		for _, item := range lines {
+			if item == line {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			tests.Info("Body: %s", body)
+			tests.Failed("Should have found line %q in exposition", line)
+		}
+	}
+	tests.Passed("Should have rendered all expected lines")
+}
+
+func TestReservedLabels(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Histogram("latency_seconds", "", 1).Observe(0.5, metrics.Labels{"le": "user", "quantile": "0.9", "path": "/"})
+
+	var bu bytes.Buffer
+	if err := prometheus.Write(&bu, reg.Snapshot()); err != nil {
+		tests.Failed("Should have written exposition: %+q", err)
+	}
+
+	expected := `latency_seconds_bucket{exported_le="user",exported_quantile="0.9",path="/",le="1"} 1`
+	if !strings.Contains(bu.String(), expected+"\n") {
+		tests.Info("Body: %s", bu.String())
+		tests.Failed("Should have renamed reserved labels into %q", expected)
+	}
+	tests.Passed("Should have renamed reserved labels")
+}
@@ -0,0 +1,350 @@
+// Package reader provides functions to read back the newline delimited entries
+// written by the jsonfile package, from single files, sets of rotated files or
+// by following a file as it is written.
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/jsonfile"
+)
+
+// Handler defines a function type which receives every Entry read.
+type Handler func(metrics.Entry) error
+
+// Files returns the rotated files of the target file from the oldest to the
+// newest, followed by the target file itself if it exists.
+func Files(targetFile string) ([]string, error) {
+	files, err := jsonfile.RotatedFiles(targetFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(targetFile); err == nil {
+		files = append(files, targetFile)
+	}
+
+	return files, nil
+}
+
+// Stream reads every line of the reader as an Entry, delivering those which
+// pass the filter into the Handler. Empty lines are skipped, while malformed
+// lines stop the stream with an error carrying their line number.
+func Stream(r io.Reader, filter metrics.FilterFn, fn Handler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	var line int
+	for scanner.Scan() {
+		line++
+
+		en, ok, err := decode(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+
+		if !ok || (filter != nil && !filter(en)) {
+			continue
+		}
+
+		if err := fn(en); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ReadFiles streams the entries of all files in order into the Handler, files
+// with a .gz extension are decompressed.
+func ReadFiles(filter metrics.FilterFn, fn Handler, files ...string) error {
+	for _, file := range files {
+		if err := readFile(file, filter, fn); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	}
+
+	return nil
+}
+
+// Replay streams the entries of all files in order into the Metrics.
+func Replay(m metrics.Metrics, filter metrics.FilterFn, files ...string) error {
+	return ReadFiles(filter, m.Send, files...)
+}
+
+func readFile(file string, filter metrics.FilterFn, fn Handler) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+
+		defer gz.Close()
+		r = gz
+	}
+
+	return Stream(r, filter, fn)
+}
+
+// decode decodes the giving line into an Entry, returning false for empty lines.
+func decode(line []byte) (metrics.Entry, bool, error) {
+	var en metrics.Entry
+
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return en, false, nil
+	}
+
+	if err := json.Unmarshal(line, &en); err != nil {
+		return en, false, err
+	}
+
+	return en, true, nil
+}
+
+//=====================================================================================
+
+// FollowConfig defines the configuration used by Follow.
+type FollowConfig struct {
+	// FromStart sets the file to be read from its start instead of its end.
+	FromStart bool
+
+	// PollInterval sets the wait between checks for new content, defaults to 250ms.
+	PollInterval time.Duration
+
+	// Filter sets the function entries must pass to be delivered.
+	Filter metrics.FilterFn
+
+	// OnError sets the function called with malformed lines, which are skipped.
+	OnError func(error)
+}
+
+// Follow delivers entries written into the target file into the Handler like
+// tail -f, till the close channel is closed or the Handler returns an error.
+//
+// The file is reopened from its start once it is replaced through rotation,
+// after all remaining entries of the replaced file are read, and is read again
+// from its start when truncated. A missing file is waited on.
+func Follow(targetFile string, config FollowConfig, fn Handler, closeChan <-chan struct{}) error {
+	if config.PollInterval <= 0 {
+		config.PollInterval = 250 * time.Millisecond
+	}
+
+	tail := follower{
+		config: config,
+		target: targetFile,
+		fn:     fn,
+	}
+
+	defer tail.close()
+
+	fromStart := config.FromStart
+	for {
+		if tail.file == nil {
+			opened, err := tail.open(fromStart)
+			if err != nil {
+				return err
+			}
+
+			// Files created or replaced after we began are always read from the start.
+			fromStart = true
+
+			if !opened {
+				if !wait(closeChan, config.PollInterval) {
+					return nil
+				}
+				continue
+			}
+		}
+
+		read, err := tail.read()
+		if err != nil {
+			return err
+		}
+
+		if read {
+			select {
+			case <-closeChan:
+				return nil
+			default:
+				continue
+			}
+		}
+
+		if err := tail.check(); err != nil {
+			return err
+		}
+
+		if tail.file == nil {
+			continue
+		}
+
+		if !wait(closeChan, config.PollInterval) {
+			return nil
+		}
+	}
+}
+
+// wait waits for the giving duration, returning false if the close channel was closed.
+func wait(closeChan <-chan struct{}, dur time.Duration) bool {
+	select {
+	case <-closeChan:
+		return false
+	case <-time.After(dur):
+		return true
+	}
+}
+
+type follower struct {
+	config  FollowConfig
+	target  string
+	fn      Handler
+	file    *os.File
+	reader  *bufio.Reader
+	offset  int64
+	partial []byte
+	line    int
+}
+
+// open opens the target file, returning false if it does not exist yet.
+func (f *follower) open(fromStart bool) (bool, error) {
+	file, err := os.Open(f.target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	f.offset = 0
+	if !fromStart {
+		if f.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return false, err
+		}
+	}
+
+	f.file = file
+	f.reader = bufio.NewReader(file)
+	f.partial = nil
+	f.line = 0
+	return true, nil
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// read delivers all complete lines currently available in the file, returning
+// true if any data was read.
+func (f *follower) read() (bool, error) {
+	var read bool
+	for {
+		data, err := f.reader.ReadBytes('\n')
+		f.offset += int64(len(data))
+		read = read || len(data) != 0
+
+		if err != nil {
+			if err == io.EOF {
+				f.partial = append(f.partial, data...)
+				return read, nil
+			}
+			return read, err
+		}
+
+		if len(f.partial) != 0 {
+			data = append(f.partial, data...)
+			f.partial = nil
+		}
+
+		if err := f.deliver(data); err != nil {
+			return read, err
+		}
+	}
+}
+
+func (f *follower) deliver(data []byte) error {
+	f.line++
+
+	en, ok, err := decode(data)
+	if err != nil {
+		if f.config.OnError != nil {
+			f.config.OnError(fmt.Errorf("%s: line %d: %s", f.target, f.line, err))
+		}
+		return nil
+	}
+
+	if !ok || (f.config.Filter != nil && !f.config.Filter(en)) {
+		return nil
+	}
+
+	return f.fn(en)
+}
+
+// check detects the rotation or truncation of the target file once the end of
+// the current file is reached.
+func (f *follower) check() error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(f.target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// The file was rotated away, drain what was appended to it between the
+	// last read and the rotation, deliver any unterminated line left and
+	// switch to the new file.
+	if err != nil || !os.SameFile(current, stat) {
+		if _, err := f.read(); err != nil {
+			return err
+		}
+
+		if len(f.partial) != 0 {
+			partial := f.partial
+			f.partial = nil
+
+			if err := f.deliver(partial); err != nil {
+				return err
+			}
+		}
+
+		f.close()
+		return nil
+	}
+
+	if stat.Size() < f.offset {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		f.offset = 0
+		f.line = 0
+		f.partial = nil
+		f.reader.Reset(f.file)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package reader_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/jsonfile"
+	"github.com/influx6/faux/metrics/memory"
+	"github.com/influx6/faux/metrics/reader"
+	"github.com/influx6/faux/tests"
+)
+
+func writeEntries(t *testing.T, rf *jsonfile.RotatingFile, messages ...string) {
+	for _, message := range messages {
+		data, err := json.Marshal(metrics.Entry{Message: message, Level: metrics.InfoLvl})
+		if err != nil {
+			tests.Failed("Should have encoded entry: %+q", err)
+		}
+
+		if _, err := rf.Write(append(data, '\n')); err != nil {
+			tests.Failed("Should have written entry: %+q", err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reader")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	tests.Passed("Should have created temp dir")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.log")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{Compress: true})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	writeEntries(t, rf, "one", "two")
+	rf.Rotate()
+	writeEntries(t, rf, "skip", "three")
+	rf.Close()
+
+	files, err := reader.Files(target)
+	if err != nil || len(files) != 2 || filepath.Ext(files[0]) != ".gz" || files[1] != target {
+		tests.Failed("Should have listed rotated files before target: %#v", files)
+	}
+	tests.Passed("Should have listed rotated files before target")
+
+	var store memory.Memory
+	filter := func(en metrics.Entry) bool { return en.Message != "skip" }
+	if err := reader.Replay(metrics.New(&store), filter, files...); err != nil {
+		tests.Failed("Should have replayed entries: %+q", err)
+	}
+	tests.Passed("Should have replayed entries")
+
+	if len(store.Data) != 3 || store.Data[0].Message != "one" || store.Data[2].Message != "three" {
+		tests.Failed("Should have replayed filtered entries in order: %#v", store.Data)
+	}
+	tests.Passed("Should have replayed filtered entries in order")
+}
+
+func TestFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reader")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	tests.Passed("Should have created temp dir")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.log")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	writeEntries(t, rf, "old")
+
+	received := make(chan string, 10)
+	closer := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- reader.Follow(target, reader.FollowConfig{PollInterval: 10 * time.Millisecond}, func(en metrics.Entry) error {
+			received <- en.Message
+			return nil
+		}, closer)
+	}()
+
+	expect := func(message string) {
+		select {
+		case got := <-received:
+			if got != message {
+				tests.Failed("Should have received %q but got %q", message, got)
+			}
+		case <-time.After(2 * time.Second):
+			tests.Failed("Should have received %q", message)
+		}
+		tests.Passed("Should have received %q", message)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	writeEntries(t, rf, "new")
+	expect("new")
+
+	rf.Rotate()
+	writeEntries(t, rf, "rotated")
+	expect("rotated")
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.Truncate(target, 0); err != nil {
+		tests.Failed("Should have truncated file: %+q", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	writeEntries(t, rf, "truncated")
+	expect("truncated")
+
+	close(closer)
+	if err := <-done; err != nil {
+		tests.Failed("Should have stopped following without error: %+q", err)
+	}
+	tests.Passed("Should have stopped following without error")
+}
+
+func TestFollowDrainsRotatedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reader")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	tests.Passed("Should have created temp dir")
+
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.log")
+	rf, err := jsonfile.NewRotatingFile(target, jsonfile.RotateConfig{})
+	if err != nil {
+		tests.Failed("Should have created rotating file: %+q", err)
+	}
+	tests.Passed("Should have created rotating file")
+
+	defer rf.Close()
+
+	received := make(chan string, 10)
+	closer := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- reader.Follow(target, reader.FollowConfig{FromStart: true, PollInterval: time.Millisecond}, func(en metrics.Entry) error {
+			received <- en.Message
+			return nil
+		}, closer)
+	}()
+
+	writeEntries(t, rf, "ready")
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		tests.Failed("Should have started following")
+	}
+
+	// Lines appended to the old file right before it is rotated away must
+	// still arrive once the follower notices the rotation.
+	for round := 0; round < 50; round++ {
+		message := fmt.Sprintf("line-%d", round)
+		writeEntries(t, rf, message)
+		rf.Rotate()
+
+		select {
+		case got := <-received:
+			if got != message {
+				tests.Failed("Should have received %q but got %q", message, got)
+			}
+		case <-time.After(2 * time.Second):
+			tests.Failed("Should have received %q appended before rotation", message)
+		}
+
+		// Give the follower time to switch to the new file before the next
+		// rotation, as files rotated twice before that are never followed.
+		time.Sleep(5 * time.Millisecond)
+	}
+	tests.Passed("Should have received every line appended before rotation")
+
+	close(closer)
+	if err := <-done; err != nil {
+		tests.Failed("Should have stopped following without error: %+q", err)
+	}
+	tests.Passed("Should have stopped following without error")
+}
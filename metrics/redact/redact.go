@@ -0,0 +1,342 @@
+// Package redact provides a metrics.Processors which masks sensitive values within
+// entries before they are delivered to other processors.
+package redact
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// Mask defines the default value which replaces redacted content.
+const Mask = "[REDACTED]"
+
+// DefaultKeys defines the keys whoes values are redacted by Default, keys are
+// matched regardless of case.
+var DefaultKeys = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"api_key",
+	"apikey",
+	"x-api-key",
+	"access_token",
+	"refresh_token",
+	"client_secret",
+	"private_key",
+}
+
+// DefaultKeyPatterns defines the key patterns whoes values are redacted by Default.
+var DefaultKeyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|api[_-]?key|private[_-]?key)`),
+}
+
+// DefaultValuePatterns defines the patterns of content redacted from all string
+// values by Default, these cover authorization headers, bearer and basic
+// credentials of at least 16 characters, JWTs and emails. Shorter credentials
+// are only matched after Authorization, so prose like "basic auth failed" is
+// kept.
+var DefaultValuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bauthorization:\s*(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{16,}=*`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+}
+
+// DefaultCardPatterns defines the patterns of card numbers redacted from all
+// string values by Default.
+var DefaultCardPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+}
+
+// Config defines the rules used by a Redactor to find sensitive values.
+type Config struct {
+	// Keys sets the keys whoes values are fully masked, matched regardless of case.
+	Keys []string
+
+	// KeyPatterns sets the patterns of keys whoes values are fully masked.
+	KeyPatterns []*regexp.Regexp
+
+	// ValuePatterns sets the patterns of content masked within all string values.
+	ValuePatterns []*regexp.Regexp
+
+	// CardPatterns sets the patterns of card numbers masked within all string
+	// values, matches are only masked if their digits pass the Luhn checksum,
+	// leaving ids and timestamps of similar length untouched.
+	CardPatterns []*regexp.Regexp
+
+	// Mask sets the function which returns the replacement of a masked value,
+	// utils.Hide can be used to keep the length of values. Defaults to
+	// always returning Mask.
+	Mask func(string) string
+}
+
+// Redactor implements the metrics.Processors interface, redacting all entries
+// according to it's Config before delivering them into its processors.
+type Redactor struct {
+	config Config
+	keys   map[string]struct{}
+	procs  []metrics.Processors
+}
+
+// New returns a new Redactor which delivers redacted entries into the provided processors.
+func New(config Config, procs ...metrics.Processors) *Redactor {
+	if config.Mask == nil {
+		config.Mask = func(string) string { return Mask }
+	}
+
+	keys := make(map[string]struct{}, len(config.Keys))
+	for _, key := range config.Keys {
+		keys[strings.ToLower(key)] = struct{}{}
+	}
+
+	return &Redactor{
+		config: config,
+		keys:   keys,
+		procs:  procs,
+	}
+}
+
+// Default returns a new Redactor using DefaultKeys, DefaultKeyPatterns,
+// DefaultValuePatterns and DefaultCardPatterns which delivers redacted entries
+// into the provided processors.
+func Default(procs ...metrics.Processors) *Redactor {
+	return New(Config{
+		Keys:          DefaultKeys,
+		KeyPatterns:   DefaultKeyPatterns,
+		ValuePatterns: DefaultValuePatterns,
+		CardPatterns:  DefaultCardPatterns,
+	}, procs...)
+}
+
+// Handle implements the metrics.Processors interface.
+func (r *Redactor) Handle(en metrics.Entry) error {
+	redacted := r.Entry(en)
+
+	for _, proc := range r.procs {
+		if err := proc.Handle(redacted); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Entry returns a copy of the giving Entry with all sensitive values masked.
+// The maps and slices of the original Entry are never modified.
+func (r *Redactor) Entry(en metrics.Entry) metrics.Entry {
+	en.Message = r.redactString(en.Message)
+	en.Field = r.field(en.Field)
+	en.Tags = r.redactStrings(en.Tags)
+	en.Trace = r.trace(en.Trace)
+
+	if len(en.Timelapse) != 0 {
+		lapses := make([]metrics.Timelapse, len(en.Timelapse))
+		for index, lapse := range en.Timelapse {
+			lapse.Message = r.redactString(lapse.Message)
+			lapse.Field = r.field(lapse.Field)
+			lapses[index] = lapse
+		}
+		en.Timelapse = lapses
+	}
+
+	return en
+}
+
+// field returns a redacted copy of the giving Field.
+func (r *Redactor) field(f metrics.Field) metrics.Field {
+	if f == nil {
+		return nil
+	}
+
+	redacted := make(metrics.Field, len(f))
+	for key, value := range f {
+		redacted[key] = r.keyed(key, value)
+	}
+
+	return redacted
+}
+
+// redactStrings returns a redacted copy of the giving strings.
+func (r *Redactor) redactStrings(items []string) []string {
+	if items == nil {
+		return nil
+	}
+
+	redacted := make([]string, len(items))
+	for index, item := range items {
+		redacted[index] = r.redactString(item)
+	}
+
+	return redacted
+}
+
+// trace returns a copy of the giving Trace with its comments and stack redacted.
+func (r *Redactor) trace(t metrics.Trace) metrics.Trace {
+	t.Comments = r.redactStrings(t.Comments)
+
+	if len(t.Stack) != 0 {
+		t.Stack = []byte(r.redactString(string(t.Stack)))
+	}
+
+	return t
+}
+
+// keyed returns the redacted value of the giving key, fully masking it if the
+// key is sensitive.
+func (r *Redactor) keyed(key string, value interface{}) interface{} {
+	if r.sensitiveKey(key) {
+		return r.mask(value)
+	}
+
+	return r.value(value)
+}
+
+// sensitiveKey returns true/false if the giving key matches a configured key
+// or key pattern.
+func (r *Redactor) sensitiveKey(key string) bool {
+	if _, ok := r.keys[strings.ToLower(key)]; ok {
+		return true
+	}
+
+	for _, pattern := range r.config.KeyPatterns {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mask returns the masked form of the giving value.
+func (r *Redactor) mask(value interface{}) interface{} {
+	switch item := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.config.Mask(item)
+	case []string:
+		masked := make([]string, len(item))
+		for index, elem := range item {
+			masked[index] = r.config.Mask(elem)
+		}
+		return masked
+	}
+
+	return r.config.Mask(fmt.Sprint(value))
+}
+
+// value returns a redacted copy of the giving value, walking through maps and
+// slices.
+func (r *Redactor) value(value interface{}) interface{} {
+	switch item := value.(type) {
+	case string:
+		return r.redactString(item)
+	case error:
+		message := item.Error()
+		if redacted := r.redactString(message); redacted != message {
+			return redacted
+		}
+		return item
+	case []string:
+		return r.redactStrings(item)
+	case []interface{}:
+		redacted := make([]interface{}, len(item))
+		for index, elem := range item {
+			redacted[index] = r.value(elem)
+		}
+		return redacted
+	case metrics.Field:
+		return r.field(item)
+	case map[string]interface{}:
+		return map[string]interface{}(r.field(metrics.Field(item)))
+	case map[string]string:
+		redacted := make(map[string]string, len(item))
+		for key, elem := range item {
+			if r.sensitiveKey(key) {
+				redacted[key] = r.config.Mask(elem)
+				continue
+			}
+			redacted[key] = r.redactString(elem)
+		}
+		return redacted
+	case http.Header:
+		return http.Header(r.multiMap(item))
+	case map[string][]string:
+		return r.multiMap(item)
+	}
+
+	return value
+}
+
+func (r *Redactor) multiMap(item map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(item))
+	for key, elems := range item {
+		values := make([]string, len(elems))
+		sensitive := r.sensitiveKey(key)
+		for index, elem := range elems {
+			if sensitive {
+				values[index] = r.config.Mask(elem)
+				continue
+			}
+			values[index] = r.redactString(elem)
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// redactString returns the giving string with all content matching the value
+// patterns masked.
+func (r *Redactor) redactString(value string) string {
+	for _, pattern := range r.config.ValuePatterns {
+		value = pattern.ReplaceAllStringFunc(value, r.config.Mask)
+	}
+
+	for _, pattern := range r.config.CardPatterns {
+		value = pattern.ReplaceAllStringFunc(value, r.maskCard)
+	}
+
+	return value
+}
+
+// maskCard returns the masked form of the giving card number if it passes the
+// Luhn checksum, else it is returned as is.
+func (r *Redactor) maskCard(value string) string {
+	if !luhn(value) {
+		return value
+	}
+	return r.config.Mask(value)
+}
+
+// luhn returns true/false if the digits within the giving value pass the Luhn
+// checksum, ignoring all other characters.
+func luhn(value string) bool {
+	var sum, digits int
+	for index := len(value) - 1; index >= 0; index-- {
+		if value[index] < '0' || value[index] > '9' {
+			continue
+		}
+
+		digit := int(value[index] - '0')
+		if digits%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+		digits++
+	}
+
+	return digits != 0 && sum%10 == 0
+}
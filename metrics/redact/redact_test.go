@@ -0,0 +1,121 @@
+package redact_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/memory"
+	"github.com/influx6/faux/metrics/redact"
+	"github.com/influx6/faux/tests"
+)
+
+func TestDefaultRedactor(t *testing.T) {
+	var store memory.Memory
+	m := metrics.New(redact.Default(&store))
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer abcdef")
+	header.Set("Accept", "application/json")
+
+	envs := map[string]string{"AWS_SECRET_ACCESS_KEY": "xyz", "HOME": "/root"}
+
+	m.Emit(metrics.Info("Login by bob@example.com"), metrics.WithFields(metrics.Field{
+		"header": header,
+		"envs":   envs,
+		"nested": map[string]interface{}{
+			"password": "hunter2",
+			"card":     "card 4111 1111 1111 1111 used",
+		},
+	}))
+
+	if len(store.Data) != 1 {
+		tests.Failed("Should have delivered entry to processor")
+	}
+	tests.Passed("Should have delivered entry to processor")
+
+	en := store.Data[0]
+	if strings.Contains(en.Message, "bob@example.com") {
+		tests.Failed("Should have redacted email from message: %q", en.Message)
+	}
+	tests.Passed("Should have redacted email from message")
+
+	redactedHeader := en.Field["header"].(http.Header)
+	if redactedHeader.Get("Authorization") != redact.Mask || redactedHeader.Get("Accept") != "application/json" {
+		tests.Failed("Should have redacted only authorization header: %#v", redactedHeader)
+	}
+	tests.Passed("Should have redacted only authorization header")
+
+	if header.Get("Authorization") != "Bearer abcdef" {
+		tests.Failed("Should not have modified original header")
+	}
+	tests.Passed("Should not have modified original header")
+
+	redactedEnvs := en.Field["envs"].(map[string]string)
+	if redactedEnvs["AWS_SECRET_ACCESS_KEY"] != redact.Mask || redactedEnvs["HOME"] != "/root" {
+		tests.Failed("Should have redacted secret environment value: %#v", redactedEnvs)
+	}
+	tests.Passed("Should have redacted secret environment value")
+
+	nested := en.Field["nested"].(map[string]interface{})
+	if nested["password"] != redact.Mask || strings.Contains(nested["card"].(string), "4111") {
+		tests.Failed("Should have redacted nested values: %#v", nested)
+	}
+	tests.Passed("Should have redacted nested values")
+}
+
+func TestRedactorCardNumbers(t *testing.T) {
+	redactor := redact.Default()
+
+	en := redactor.Entry(metrics.Entry{Message: "paid with 4111-1111-1111-1111 for order 1234567890123"})
+	if en.Message != "paid with "+redact.Mask+" for order 1234567890123" {
+		tests.Failed("Should have masked only numbers passing the Luhn checksum: %q", en.Message)
+	}
+	tests.Passed("Should have masked only numbers passing the Luhn checksum")
+}
+
+func TestRedactorTagsAndTrace(t *testing.T) {
+	redactor := redact.Default()
+
+	tags := []string{"user:bob@example.com", "api"}
+	en := redactor.Entry(metrics.Entry{
+		Tags: tags,
+		Trace: metrics.Trace{
+			Comments: []string{"sent Bearer eWXdMcNiC0pA8sTzQ1kV"},
+			Stack:    []byte("main.login(bob@example.com)"),
+		},
+	})
+
+	if en.Tags[0] != "user:"+redact.Mask || en.Tags[1] != "api" {
+		tests.Failed("Should have redacted tags: %+q", en.Tags)
+	}
+
+	if tags[0] != "user:bob@example.com" {
+		tests.Failed("Should not have modified original tags")
+	}
+	tests.Passed("Should have redacted tags")
+
+	if en.Trace.Comments[0] != "sent "+redact.Mask || strings.Contains(string(en.Trace.Stack), "bob@example.com") {
+		tests.Failed("Should have redacted trace: %+v", en.Trace)
+	}
+	tests.Passed("Should have redacted trace")
+}
+
+func TestRedactorCredentials(t *testing.T) {
+	redactor := redact.Default()
+
+	messages := map[string]string{
+		"basic auth failed":                                "basic auth failed",
+		"bearer token missing":                             "bearer token missing",
+		"sent Authorization: Basic YTpi":                   "sent " + redact.Mask,
+		"retried with bearer eWXdMcNiC0pA8sTzQ1kV== again": "retried with " + redact.Mask + " again",
+	}
+
+	for message, expected := range messages {
+		if en := redactor.Entry(metrics.Entry{Message: message}); en.Message != expected {
+			tests.Failed("Should have redacted %q into %q but got %q", message, expected, en.Message)
+		}
+	}
+	tests.Passed("Should have redacted only credential shaped values")
+}
@@ -0,0 +1,357 @@
+package metrics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors.
+var (
+	ErrInvalidTraceParent = errors.New("invalid traceparent value")
+)
+
+// constants for span propagation.
+const (
+	// SpanType defines the Entry.Type set on all Entry emitted by a finished Span.
+	SpanType = "span"
+
+	// TraceParentHeader defines the W3C trace context header used to propagate
+	// spans across http requests.
+	TraceParentHeader = "traceparent"
+
+	// TraceParentEnv defines the environment variable used to propagate spans
+	// into child processes.
+	TraceParentEnv = "TRACEPARENT"
+
+	// traceParentVersion defines the only version of the traceparent format supported.
+	traceParentVersion = "00"
+
+	// sampledFlag defines the trace flag marking a trace as sampled.
+	sampledFlag byte = 0x01
+)
+
+// SpanContext defines the identity of a Span which is propagated to child spans
+// both within and across process boundaries.
+type SpanContext struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+	Flags   byte   `json:"flags"`
+}
+
+// IsValid returns true/false if the giving SpanContext has a valid trace and span id.
+func (sc SpanContext) IsValid() bool {
+	return isHexID(sc.TraceID, 32) && isHexID(sc.SpanID, 16)
+}
+
+// Sampled returns true/false if the sampled flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&sampledFlag != 0
+}
+
+// TraceParent returns the W3C traceparent representation of the SpanContext.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent returns the SpanContext for the giving W3C traceparent value.
+func ParseTraceParent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != traceParentVersion {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	sc := SpanContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Flags:   flags[0],
+	}
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	return sc, nil
+}
+
+// isHexID returns true/false if the giving id is a lowercase hex string of the
+// provided length which is not all zeros.
+func isHexID(id string, size int) bool {
+	if len(id) != size || strings.Trim(id, "0") == "" {
+		return false
+	}
+
+	for _, ch := range id {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newID returns a new random hex id of the giving size in bytes.
+func newID(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+//=====================================================================================
+
+type spanKey struct{}
+type remoteSpanKey struct{}
+
+// ContextWithSpan returns a new context.Context carrying the giving Span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the Span stored in the context if any.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpan returns a new context.Context carrying the giving SpanContext
+// as the parent of the next span started, it is used to join a trace started in
+// another process.
+func ContextWithRemoteSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanKey{}, sc)
+}
+
+// RemoteSpanFromContext returns the remote SpanContext stored in the context if any.
+func RemoteSpanFromContext(ctx context.Context) (SpanContext, bool) {
+	if ctx == nil {
+		return SpanContext{}, false
+	}
+
+	sc, ok := ctx.Value(remoteSpanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// InjectTraceParent sets the traceparent header of the giving http.Header to the
+// SpanContext of the Span stored in the context if any.
+func InjectTraceParent(ctx context.Context, h http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		h.Set(TraceParentHeader, span.Context().TraceParent())
+	}
+}
+
+// ExtractTraceParent returns a new context.Context carrying the SpanContext
+// found within the traceparent header of the giving http.Header, if the
+// header is missing or invalid the context is returned as is.
+func ExtractTraceParent(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceParent(h.Get(TraceParentHeader))
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithRemoteSpan(ctx, sc)
+}
+
+//=====================================================================================
+
+// Span defines a single timed operation within a trace which may have a parent
+// and child spans. It is emitted as an Entry into its Metrics when ended.
+type Span struct {
+	name     string
+	parentID string
+	context  SpanContext
+	metrics  Metrics
+	start    time.Time
+	function string
+	file     string
+	line     int
+
+	ml     sync.Mutex
+	end    time.Time
+	err    error
+	ended  bool
+	attrs  Field
+	events []Timelapse
+}
+
+// StartSpan returns a new Span with the giving name and a context.Context carrying
+// it. The span becomes a child of any Span or remote SpanContext found in the
+// provided context, else a new trace is started. If the provided Metrics is nil
+// the Metrics of the parent Span is used.
+func StartSpan(ctx context.Context, m Metrics, name string) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	function, file, line := getFunctionName(3)
+
+	span := &Span{
+		name:     name,
+		metrics:  m,
+		start:    time.Now(),
+		attrs:    make(Field),
+		function: function,
+		file:     file,
+		line:     line,
+	}
+
+	span.context.SpanID = newID(8)
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.parentID = parent.context.SpanID
+		span.context.TraceID = parent.context.TraceID
+		span.context.Flags = parent.context.Flags
+
+		if span.metrics == nil {
+			span.metrics = parent.metrics
+		}
+	} else if remote, ok := RemoteSpanFromContext(ctx); ok {
+		span.parentID = remote.SpanID
+		span.context.TraceID = remote.TraceID
+		span.context.Flags = remote.Flags
+	} else {
+		span.context.TraceID = newID(16)
+		span.context.Flags = sampledFlag
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Name returns the name of the span.
+func (s *Span) Name() string {
+	return s.name
+}
+
+// Context returns the SpanContext of the span.
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+// ParentID returns the span id of the parent of the span if any.
+func (s *Span) ParentID() string {
+	return s.parentID
+}
+
+// SetAttribute sets the giving key-value pair as an attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	s.attrs[key] = value
+}
+
+// SetAttributes sets all key-value pair of the giving Field as attributes of the span.
+func (s *Span) SetAttributes(f Field) {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	for key, value := range f {
+		s.attrs[key] = value
+	}
+}
+
+// AddEvent records a timestamped event with the giving message and fields on the span.
+func (s *Span) AddEvent(message string, f Field) {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	s.events = append(s.events, Timelapse{
+		Field:   f,
+		Message: message,
+		Time:    time.Now(),
+	})
+}
+
+// SetError marks the span as failed with the giving error.
+func (s *Span) SetError(err error) {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	s.err = err
+}
+
+// Duration returns the duration of the span, if the span has not ended
+// then the time elapsed since its start is returned.
+func (s *Span) Duration() time.Duration {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	if !s.ended {
+		return time.Since(s.start)
+	}
+
+	return s.end.Sub(s.start)
+}
+
+// End ends the span, emitting it as an Entry into its Metrics if any.
+// Calling End more than once does nothing.
+func (s *Span) End() error {
+	s.ml.Lock()
+	if s.ended {
+		s.ml.Unlock()
+		return nil
+	}
+
+	s.ended = true
+	s.end = time.Now()
+	en := s.entry()
+	s.ml.Unlock()
+
+	if s.metrics == nil {
+		return nil
+	}
+
+	return s.metrics.Send(en)
+}
+
+// entry returns the Entry representing the span. It expects the lock to be held.
+func (s *Span) entry() Entry {
+	fields := make(Field, len(s.attrs)+6)
+	for key, value := range s.attrs {
+		fields[key] = value
+	}
+
+	fields["trace_id"] = s.context.TraceID
+	fields["span_id"] = s.context.SpanID
+	fields["start"] = s.start
+	fields["end"] = s.end
+	fields["duration"] = s.end.Sub(s.start)
+
+	if s.parentID != "" {
+		fields["parent_span_id"] = s.parentID
+	}
+
+	level := InfoLvl
+	if s.err != nil {
+		level = ErrorLvl
+		fields["error"] = s.err
+	}
+
+	return Entry{
+		ID:        s.name,
+		Type:      SpanType,
+		Level:     level,
+		Field:     fields,
+		Time:      s.end,
+		Message:   s.name,
+		Function:  s.function,
+		File:      s.file,
+		Line:      s.line,
+		Timelapse: append([]Timelapse{}, s.events...),
+	}
+}
@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/memory"
+	"github.com/influx6/faux/tests"
+)
+
+func TestSpanNesting(t *testing.T) {
+	var store memory.Memory
+	m := metrics.New(&store)
+
+	ctx, parent := metrics.StartSpan(context.Background(), m, "parent")
+	_, child := metrics.StartSpan(ctx, nil, "child")
+	child.SetAttribute("rows", 20)
+	child.AddEvent("fetched rows", nil)
+
+	if err := child.End(); err != nil {
+		tests.Failed("Should have ended child span: %+q", err)
+	}
+	if err := parent.End(); err != nil {
+		tests.Failed("Should have ended parent span: %+q", err)
+	}
+	tests.Passed("Should have ended spans")
+
+	if len(store.Data) != 2 {
+		tests.Failed("Should have emitted 2 entries but got %d", len(store.Data))
+	}
+	tests.Passed("Should have emitted 2 entries")
+
+	childEntry, parentEntry := store.Data[0], store.Data[1]
+	if childEntry.Type != metrics.SpanType || childEntry.ID != "child" {
+		tests.Failed("Should have emitted child span entry: %#v", childEntry)
+	}
+	tests.Passed("Should have emitted child span entry")
+
+	if childEntry.Field["trace_id"] != parentEntry.Field["trace_id"] {
+		tests.Failed("Should have shared trace id between spans")
+	}
+	tests.Passed("Should have shared trace id between spans")
+
+	if childEntry.Field["parent_span_id"] != parentEntry.Field["span_id"] {
+		tests.Failed("Should have set parent span id on child")
+	}
+	tests.Passed("Should have set parent span id on child")
+
+	if len(childEntry.Timelapse) != 1 || childEntry.Field["rows"] != 20 {
+		tests.Failed("Should have recorded events and attributes on child")
+	}
+	tests.Passed("Should have recorded events and attributes on child")
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	ctx, span := metrics.StartSpan(context.Background(), nil, "client")
+
+	header := make(http.Header)
+	metrics.InjectTraceParent(ctx, header)
+
+	remote, err := metrics.ParseTraceParent(header.Get(metrics.TraceParentHeader))
+	if err != nil {
+		tests.Failed("Should have parsed injected traceparent: %+q", err)
+	}
+	tests.Passed("Should have parsed injected traceparent")
+
+	if remote != span.Context() {
+		tests.Failed("Should have matched span context: %#v", remote)
+	}
+	tests.Passed("Should have matched span context")
+
+	_, server := metrics.StartSpan(metrics.ExtractTraceParent(context.Background(), header), nil, "server")
+	if server.Context().TraceID != span.Context().TraceID || server.ParentID() != span.Context().SpanID {
+		tests.Failed("Should have joined remote trace")
+	}
+	tests.Passed("Should have joined remote trace")
+
+	if _, err := metrics.ParseTraceParent("00-00000000000000000000000000000000-0000000000000000-01"); err == nil {
+		tests.Failed("Should have rejected all zero traceparent")
+	}
+	tests.Passed("Should have rejected all zero traceparent")
+}
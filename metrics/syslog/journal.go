@@ -0,0 +1,195 @@
+package syslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// JournalSocket defines the default path of the journald native protocol socket.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// JournalConfig defines the configuration used by a Journal.
+type JournalConfig struct {
+	// Socket sets the path of the journald socket, defaults to JournalSocket.
+	Socket string
+
+	// Identifier sets the SYSLOG_IDENTIFIER of every entry, defaults to the program name.
+	Identifier string
+}
+
+// journal keys set from the fixed parts of an Entry.
+var journalKeys = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+	"ENTRY_ID":          true,
+	"ENTRY_TYPE":        true,
+	"TAG":               true,
+}
+
+// FormatJournal returns the journald native protocol datagram for the giving Entry.
+//
+// The Entry is written as MESSAGE, PRIORITY mapped from its Level through Severity,
+// SYSLOG_IDENTIFIER, CODE_FILE, CODE_LINE, CODE_FUNC, ENTRY_ID, ENTRY_TYPE and a
+// TAG for each tag, followed by its fields sorted by key. Field keys are turned
+// into upper case with all other characters replaced by underscores, keys which
+// clash with the above are prefixed with FIELD_.
+func FormatJournal(config JournalConfig, en metrics.Entry) []byte {
+	if config.Identifier == "" {
+		config.Identifier = filepath.Base(os.Args[0])
+	}
+
+	var bu bytes.Buffer
+	writeJournalField(&bu, "MESSAGE", en.Message)
+	writeJournalField(&bu, "PRIORITY", strconv.Itoa(Severity(en.Level)))
+	writeJournalField(&bu, "SYSLOG_IDENTIFIER", config.Identifier)
+
+	if en.File != "" {
+		writeJournalField(&bu, "CODE_FILE", en.File)
+		writeJournalField(&bu, "CODE_LINE", strconv.Itoa(en.Line))
+	}
+
+	if en.Function != "" {
+		writeJournalField(&bu, "CODE_FUNC", en.Function)
+	}
+
+	if en.ID != "" {
+		writeJournalField(&bu, "ENTRY_ID", en.ID)
+	}
+
+	if en.Type != "" {
+		writeJournalField(&bu, "ENTRY_TYPE", en.Type)
+	}
+
+	for _, tag := range en.Tags {
+		writeJournalField(&bu, "TAG", tag)
+	}
+
+	keys := make([]string, 0, len(en.Field))
+	for key := range en.Field {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		writeJournalField(&bu, journalKey(key), paramValue(en.Field[key]))
+	}
+
+	return bu.Bytes()
+}
+
+// journalKey returns the giving field key as a valid journal field name.
+func journalKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+
+	if key == "" || journalKeys[key] || key[0] == '_' || (key[0] >= '0' && key[0] <= '9') || strings.HasPrefix(key, "FIELD_") {
+		key = "FIELD_" + key
+	}
+
+	if len(key) > 64 {
+		key = key[:64]
+	}
+
+	return key
+}
+
+// writeJournalField writes the giving field, using the binary form for values
+// with line feeds.
+func writeJournalField(bu *bytes.Buffer, key string, value string) {
+	bu.WriteString(key)
+
+	if !strings.Contains(value, "\n") {
+		bu.WriteByte('=')
+		bu.WriteString(value)
+		bu.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+
+	bu.WriteByte('\n')
+	bu.Write(size[:])
+	bu.WriteString(value)
+	bu.WriteByte('\n')
+}
+
+//=====================================================================================
+
+// Journal implements the metrics.Processors interface, delivering every Entry
+// into journald through its native protocol.
+type Journal struct {
+	config JournalConfig
+
+	ml     sync.Mutex
+	conn   *net.UnixConn
+	closed bool
+}
+
+// NewJournal returns a new Journal connected to the journald socket.
+func NewJournal(config JournalConfig) (*Journal, error) {
+	if config.Socket == "" {
+		config.Socket = JournalSocket
+	}
+
+	if config.Identifier == "" {
+		config.Identifier = filepath.Base(os.Args[0])
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: config.Socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{config: config, conn: conn}, nil
+}
+
+// Handle implements the metrics.Processors interface. Entries above the maximum
+// datagram size of the socket are rejected with the error of the write.
+func (j *Journal) Handle(en metrics.Entry) error {
+	data := FormatJournal(j.config, en)
+
+	j.ml.Lock()
+	defer j.ml.Unlock()
+
+	if j.closed {
+		return ErrWriterClosed
+	}
+
+	_, err := j.conn.Write(data)
+	return err
+}
+
+// Close closes the connection of the Journal.
+func (j *Journal) Close() error {
+	j.ml.Lock()
+	defer j.ml.Unlock()
+
+	if j.closed {
+		return nil
+	}
+
+	j.closed = true
+	return j.conn.Close()
+}
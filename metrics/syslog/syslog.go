@@ -0,0 +1,400 @@
+// Package syslog provides metrics processors which deliver entries as RFC 5424
+// syslog messages over udp, tcp and unix sockets, and into journald through its
+// native protocol.
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// errors.
+var (
+	ErrWriterClosed = errors.New("syslog writer already closed")
+)
+
+// facilities.
+const (
+	Kern     = 0
+	User     = 1
+	Mail     = 2
+	Daemon   = 3
+	Auth     = 4
+	Syslog   = 5
+	Lpr      = 6
+	News     = 7
+	Uucp     = 8
+	Cron     = 9
+	AuthPriv = 10
+	Ftp      = 11
+	Local0   = 16
+	Local1   = 17
+	Local2   = 18
+	Local3   = 19
+	Local4   = 20
+	Local5   = 21
+	Local6   = 22
+	Local7   = 23
+)
+
+// Framing defines how messages are delimited on stream connections.
+type Framing int
+
+// framing types, see RFC 6587.
+const (
+	// OctetCounted prefixes every message with its length and a space.
+	OctetCounted Framing = iota
+
+	// NonTransparent terminates every message with a line feed.
+	NonTransparent
+)
+
+// timeFormat defines the RFC 5424 timestamp layout, which allows at most
+// microsecond precision.
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Config defines the configuration used to build syslog messages and the
+// connection they are delivered over.
+type Config struct {
+	// Network sets the network of the syslog server: udp, tcp, unix or unixgram.
+	Network string
+
+	// Address sets the address of the syslog server, or the path of its socket.
+	Address string
+
+	// Facility sets the facility of every message, defaults to User as Kern
+	// is reserved for the kernel.
+	Facility int
+
+	// Hostname sets the hostname of every message, defaults to os.Hostname().
+	Hostname string
+
+	// AppName sets the app name of every message, defaults to the program name.
+	AppName string
+
+	// ProcID sets the process id of every message, defaults to os.Getpid().
+	ProcID string
+
+	// Framing sets the framing used on stream connections.
+	Framing Framing
+
+	// EnterpriseID sets the private enterprise number used for the structured
+	// data ids, defaults to 32473, which is reserved for documentation.
+	EnterpriseID int
+
+	// DialTimeout sets the timeout for connecting to the server.
+	DialTimeout time.Duration
+
+	// WriteTimeout sets the timeout for writing a message.
+	WriteTimeout time.Duration
+}
+
+func (c *Config) defaults() {
+	if c.Facility == 0 {
+		c.Facility = User
+	}
+
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+
+	if c.AppName == "" {
+		c.AppName = filepath.Base(os.Args[0])
+	}
+
+	if c.ProcID == "" {
+		c.ProcID = strconv.Itoa(os.Getpid())
+	}
+
+	if c.EnterpriseID == 0 {
+		c.EnterpriseID = 32473
+	}
+}
+
+// Severity returns the syslog severity for the giving Level.
+func Severity(lvl metrics.Level) int {
+	switch lvl {
+	case metrics.RedAlertLvl:
+		return 1
+	case metrics.YellowAlertLvl:
+		return 2
+	case metrics.ErrorLvl:
+		return 3
+	}
+
+	return 6
+}
+
+// Format returns the RFC 5424 message for the giving Entry.
+//
+// The ID of the Entry is used as the MSGID, while the structured data holds an
+// entry element with its type, function, file, line and tags, and a fields
+// element with its fields sorted by key.
+func Format(config Config, en metrics.Entry) []byte {
+	config.defaults()
+
+	var bu bytes.Buffer
+	bu.WriteByte('<')
+	bu.WriteString(strconv.Itoa(config.Facility*8 + Severity(en.Level)))
+	bu.WriteString(">1 ")
+
+	if en.Time.IsZero() {
+		bu.WriteString("-")
+	} else {
+		bu.WriteString(en.Time.Format(timeFormat))
+	}
+
+	bu.WriteByte(' ')
+	bu.WriteString(headerField(config.Hostname, 255))
+	bu.WriteByte(' ')
+	bu.WriteString(headerField(config.AppName, 48))
+	bu.WriteByte(' ')
+	bu.WriteString(headerField(config.ProcID, 128))
+	bu.WriteByte(' ')
+	bu.WriteString(headerField(en.ID, 32))
+	bu.WriteByte(' ')
+
+	enterprise := "@" + strconv.Itoa(config.EnterpriseID)
+
+	var params []param
+	if en.Type != "" {
+		params = append(params, param{"type", en.Type})
+	}
+
+	if en.Function != "" {
+		params = append(params, param{"function", en.Function})
+	}
+
+	if en.File != "" {
+		params = append(params, param{"file", en.File}, param{"line", strconv.Itoa(en.Line)})
+	}
+
+	for _, tag := range en.Tags {
+		params = append(params, param{"tag", tag})
+	}
+
+	var fields []param
+	keys := make([]string, 0, len(en.Field))
+	for key := range en.Field {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fields = append(fields, param{key, paramValue(en.Field[key])})
+	}
+
+	if len(params) == 0 && len(fields) == 0 {
+		bu.WriteByte('-')
+	}
+
+	writeElement(&bu, "entry"+enterprise, params)
+	writeElement(&bu, "fields"+enterprise, fields)
+
+	if en.Message != "" {
+		bu.WriteByte(' ')
+		bu.WriteString(en.Message)
+	}
+
+	return bu.Bytes()
+}
+
+type param struct {
+	name  string
+	value string
+}
+
+// writeElement writes a structured data element with the giving params, nothing
+// is written if there are no params.
+func writeElement(bu *bytes.Buffer, id string, params []param) {
+	if len(params) == 0 {
+		return
+	}
+
+	bu.WriteByte('[')
+	bu.WriteString(id)
+
+	for _, p := range params {
+		bu.WriteByte(' ')
+		bu.WriteString(paramName(p.name))
+		bu.WriteString(`="`)
+
+		for _, r := range p.value {
+			switch r {
+			case '"', '\\', ']':
+				bu.WriteByte('\\')
+			}
+			bu.WriteRune(r)
+		}
+
+		bu.WriteByte('"')
+	}
+
+	bu.WriteByte(']')
+}
+
+// headerField returns the giving value as a header field, keeping only printable
+// ascii characters up to the max length, or "-" if empty.
+func headerField(value string, max int) string {
+	var bu bytes.Buffer
+	for i := 0; i < len(value) && bu.Len() < max; i++ {
+		if value[i] > 32 && value[i] < 127 {
+			bu.WriteByte(value[i])
+		}
+	}
+
+	if bu.Len() == 0 {
+		return "-"
+	}
+
+	return bu.String()
+}
+
+// paramName returns the giving value as a structured data param name, which must
+// be at most 32 printable ascii characters without '=', ' ', ']' and '"'.
+func paramName(value string) string {
+	var bu bytes.Buffer
+	for i := 0; i < len(value) && bu.Len() < 32; i++ {
+		switch c := value[i]; {
+		case c == '=' || c == ']' || c == '"' || c <= 32 || c >= 127:
+			bu.WriteByte('_')
+		default:
+			bu.WriteByte(c)
+		}
+	}
+
+	if bu.Len() == 0 {
+		return "_"
+	}
+
+	return bu.String()
+}
+
+// paramValue returns the string form of a field value.
+func paramValue(item interface{}) string {
+	switch val := item.(type) {
+	case string:
+		return val
+	case error:
+		return val.Error()
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(item)
+}
+
+//=====================================================================================
+
+// Writer implements the metrics.Processors interface, delivering every Entry
+// as a syslog message to a server. A failed write is retried once on a new
+// connection.
+type Writer struct {
+	config Config
+
+	ml     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// New returns a new Writer connected to the server of the giving Config.
+func New(config Config) (*Writer, error) {
+	config.defaults()
+
+	w := &Writer{config: config}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Handle implements the metrics.Processors interface.
+func (w *Writer) Handle(en metrics.Entry) error {
+	msg := w.frame(Format(w.config, en))
+
+	w.ml.Lock()
+	defer w.ml.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if w.conn != nil {
+		if err := w.write(msg); err == nil {
+			return nil
+		}
+
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return err
+	}
+
+	return w.write(msg)
+}
+
+// Close closes the connection of the Writer.
+func (w *Writer) Close() error {
+	w.ml.Lock()
+	defer w.ml.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	return w.conn.Close()
+}
+
+// frame returns the giving message framed for the network of the Writer.
+func (w *Writer) frame(msg []byte) []byte {
+	switch w.config.Network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return msg
+	}
+
+	if w.config.Framing == NonTransparent {
+		return append(msg, '\n')
+	}
+
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// connect dials the server. It expects the lock to be held.
+func (w *Writer) connect() error {
+	conn, err := net.DialTimeout(w.config.Network, w.config.Address, w.config.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	return nil
+}
+
+// write writes the giving message into the connection. It expects the lock to be held.
+func (w *Writer) write(msg []byte) error {
+	if w.config.WriteTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+	}
+
+	_, err := w.conn.Write(msg)
+	return err
+}
@@ -0,0 +1,198 @@
+package syslog_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/syslog"
+	"github.com/influx6/faux/tests"
+)
+
+func sampleEntry() metrics.Entry {
+	return metrics.Entry{
+		ID:      "db:query",
+		Level:   metrics.ErrorLvl,
+		Message: "query failed",
+		Time:    time.Date(2017, 6, 12, 10, 30, 15, 123456789, time.UTC),
+		Tags:    []string{"db"},
+		Field: metrics.Field{
+			"table": "users",
+			"query": `select "x" [1]`,
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	config := syslog.Config{Facility: syslog.Local0, Hostname: "box-1", AppName: "api", ProcID: "42"}
+
+	expected := `<131>1 2017-06-12T10:30:15.123456Z box-1 api 42 db:query [entry@32473 tag="db"][fields@32473 query="select \"x\" [1\]" table="users"] query failed`
+	if msg := string(syslog.Format(config, sampleEntry())); msg != expected {
+		tests.Failed("Should have formatted RFC 5424 message: %q", msg)
+	}
+	tests.Passed("Should have formatted RFC 5424 message")
+
+	if msg := string(syslog.Format(config, metrics.Entry{Level: metrics.InfoLvl})); msg != "<134>1 - box-1 api 42 - -" {
+		tests.Failed("Should have formatted empty message with nil values: %q", msg)
+	}
+	tests.Passed("Should have formatted empty message with nil values")
+}
+
+func TestWriterTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tests.Failed("Should have started tcp listener: %+q", err)
+	}
+	tests.Passed("Should have started tcp listener")
+
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for {
+			size, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+
+			length, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, length)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+
+			received <- string(msg)
+		}
+	}()
+
+	writer, err := syslog.New(syslog.Config{Network: "tcp", Address: listener.Addr().String(), Hostname: "box-1"})
+	if err != nil {
+		tests.Failed("Should have connected to tcp listener: %+q", err)
+	}
+	tests.Passed("Should have connected to tcp listener")
+
+	defer writer.Close()
+
+	en := sampleEntry()
+	en.Message = "line one\nline two"
+	if err := writer.Handle(en); err != nil {
+		tests.Failed("Should have written message: %+q", err)
+	}
+	tests.Passed("Should have written message")
+
+	select {
+	case msg := <-received:
+		if !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, " line one\nline two") {
+			tests.Failed("Should have received octet counted message: %q", msg)
+		}
+	case <-time.After(time.Second):
+		tests.Failed("Should have received octet counted message")
+	}
+	tests.Passed("Should have received octet counted message")
+}
+
+func TestWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tests.Failed("Should have started udp listener: %+q", err)
+	}
+	tests.Passed("Should have started udp listener")
+
+	defer conn.Close()
+
+	writer, err := syslog.New(syslog.Config{Network: "udp", Address: conn.LocalAddr().String()})
+	if err != nil {
+		tests.Failed("Should have dialed udp listener: %+q", err)
+	}
+	tests.Passed("Should have dialed udp listener")
+
+	defer writer.Close()
+
+	if err := writer.Handle(sampleEntry()); err != nil {
+		tests.Failed("Should have written message: %+q", err)
+	}
+	tests.Passed("Should have written message")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || !bytes.HasPrefix(buf[:n], []byte("<11>1 ")) || !bytes.HasSuffix(buf[:n], []byte("] query failed")) {
+		tests.Failed("Should have received message as a single datagram: %q", buf[:n])
+	}
+	tests.Passed("Should have received message as a single datagram")
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	tests.Passed("Should have created temp dir")
+
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		tests.Failed("Should have started unixgram listener: %+q", err)
+	}
+	tests.Passed("Should have started unixgram listener")
+
+	defer conn.Close()
+
+	journal, err := syslog.NewJournal(syslog.JournalConfig{Socket: socket, Identifier: "api"})
+	if err != nil {
+		tests.Failed("Should have connected to journal socket: %+q", err)
+	}
+	tests.Passed("Should have connected to journal socket")
+
+	defer journal.Close()
+
+	en := sampleEntry()
+	en.Level = metrics.RedAlertLvl
+	en.Field["message"] = "multi\nline"
+
+	if err := journal.Handle(en); err != nil {
+		tests.Failed("Should have written journal entry: %+q", err)
+	}
+	tests.Passed("Should have written journal entry")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		tests.Failed("Should have received journal entry: %+q", err)
+	}
+	tests.Passed("Should have received journal entry")
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len("multi\nline")))
+
+	data := buf[:n]
+	for _, part := range []string{"MESSAGE=query failed\n", "PRIORITY=1\n", "SYSLOG_IDENTIFIER=api\n", "ENTRY_ID=db:query\n", "TAG=db\n", "TABLE=users\n", "FIELD_MESSAGE\n" + string(size[:]) + "multi\nline\n"} {
+		if !bytes.Contains(data, []byte(part)) {
+			tests.Failed("Should have written journal field %q: %q", part, data)
+		}
+	}
+	tests.Passed("Should have written journal fields")
+}
@@ -0,0 +1,374 @@
+package metrics
+
+import (
+	"container/list"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// KeyFn defines a function type which returns the key a giving Entry is grouped by.
+type KeyFn func(Entry) string
+
+// ByID returns the ID of the giving Entry as its key.
+func ByID(en Entry) string {
+	return en.ID
+}
+
+// ByMessage returns the Message of the giving Entry as its key.
+func ByMessage(en Entry) string {
+	return en.Message
+}
+
+// ByOrigin returns a key made of the level, id, message and the source
+// location of the giving Entry, which identifies repeated emissions of the
+// same entry.
+func ByOrigin(en Entry) string {
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%s\x00%s:%d", en.Level, en.ID, en.Type, en.Message, en.File, en.Line)
+}
+
+//=====================================================================================
+
+type sampleProcessor struct {
+	ml    sync.Mutex
+	rand  *rand.Rand
+	rates map[Level]float64
+	procs []Processors
+}
+
+// Sample returns a Processor which delivers entries into the provided processors
+// based on the probability set for their level, where 0 drops all and 1 keeps all
+// entries. Entries with a level not within the rates are always delivered.
+func Sample(rates map[Level]float64, procs ...Processors) Processors {
+	return &sampleProcessor{
+		rates: rates,
+		procs: procs,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// pick returns true/false if the giving Entry was picked by sampling.
+func (sp *sampleProcessor) pick(en Entry) bool {
+	rate, ok := sp.rates[en.Level]
+	if !ok || rate >= 1 {
+		return true
+	}
+
+	if rate <= 0 {
+		return false
+	}
+
+	sp.ml.Lock()
+	defer sp.ml.Unlock()
+	return sp.rand.Float64() < rate
+}
+
+// Handle implements the Processors interface and delivers Entry
+// to undeline metrics if picked by sampling.
+func (sp *sampleProcessor) Handle(en Entry) error {
+	if !sp.pick(en) {
+		return nil
+	}
+
+	for _, proc := range sp.procs {
+		if err := proc.Handle(en); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//=====================================================================================
+
+// maxIdleBuckets defines the total buckets held by a rate limiter, beyond which
+// the least recently used bucket is evicted.
+const maxIdleBuckets = 1024
+
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+type rateLimitProcessor struct {
+	ml      sync.Mutex
+	rate    float64
+	burst   float64
+	key     KeyFn
+	buckets map[string]*list.Element
+	recent  *list.List
+	procs   []Processors
+}
+
+// RateLimit returns a Processor which uses a token bucket for every key returned
+// by the KeyFn, allowing up to burst entries at once refilled at rate entries per
+// second. Entries above the limit are dropped.
+func RateLimit(rate float64, burst int, key KeyFn, procs ...Processors) Processors {
+	if key == nil {
+		key = ByID
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimitProcessor{
+		rate:    rate,
+		key:     key,
+		procs:   procs,
+		burst:   float64(burst),
+		buckets: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// allow returns true/false if the giving Entry is within the rate limit, it
+// consumes a token from the bucket of the entry's key if so.
+func (rl *rateLimitProcessor) allow(en Entry) bool {
+	key := rl.key(en)
+	now := time.Now()
+
+	rl.ml.Lock()
+	defer rl.ml.Unlock()
+
+	var bucket *tokenBucket
+	if elem, ok := rl.buckets[key]; ok {
+		rl.recent.MoveToFront(elem)
+		bucket = elem.Value.(*tokenBucket)
+	} else {
+		if len(rl.buckets) >= maxIdleBuckets {
+			rl.evict()
+		}
+
+		bucket = &tokenBucket{key: key, tokens: rl.burst, last: now}
+		rl.buckets[key] = rl.recent.PushFront(bucket)
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * rl.rate
+	if bucket.tokens > rl.burst {
+		bucket.tokens = rl.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// evict removes the least recently used bucket. It expects the lock to be held.
+func (rl *rateLimitProcessor) evict() {
+	if oldest := rl.recent.Back(); oldest != nil {
+		rl.recent.Remove(oldest)
+		delete(rl.buckets, oldest.Value.(*tokenBucket).key)
+	}
+}
+
+// Handle implements the Processors interface and delivers Entry
+// to undeline metrics if within the rate limit.
+func (rl *rateLimitProcessor) Handle(en Entry) error {
+	if !rl.allow(en) {
+		return nil
+	}
+
+	for _, proc := range rl.procs {
+		if err := proc.Handle(en); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//=====================================================================================
+
+// dedup field keys set on the summary entry of repeated entries.
+const (
+	RepeatCountKey = "repeat_count"
+	FirstSeenKey   = "first_seen"
+	LastSeenKey    = "last_seen"
+)
+
+type dedupRecord struct {
+	key     string
+	entry   Entry
+	repeats int
+	first   time.Time
+	last    time.Time
+	expires time.Time
+}
+
+// Deduplicator implements the MetricConsumer interface, it delivers the first of
+// a series of identical entries immediately, suppressing all repeats within it's
+// window. Once the window elapses, a single entry carrying the total repeats and
+// the first and last time seen is delivered in place of the suppressed entries.
+type Deduplicator struct {
+	window time.Duration
+	key    KeyFn
+	procs  []Processors
+
+	ml      sync.Mutex
+	records map[string]*dedupRecord
+	windows *list.List
+	closer  chan struct{}
+	closed  sync.Once
+}
+
+// Dedup returns a new Deduplicator using the giving window, defaulting to a second.
+// If the KeyFn is nil, ByOrigin is used to identify identical entries.
+func Dedup(window time.Duration, key KeyFn, procs ...Processors) *Deduplicator {
+	if key == nil {
+		key = ByOrigin
+	}
+
+	if window <= 0 {
+		window = time.Second
+	}
+
+	return &Deduplicator{
+		window:  window,
+		key:     key,
+		procs:   procs,
+		records: make(map[string]*dedupRecord),
+		windows: list.New(),
+		closer:  make(chan struct{}),
+	}
+}
+
+// Handle implements the Processors interface.
+func (d *Deduplicator) Handle(en Entry) error {
+	now := time.Now()
+	key := d.key(en)
+
+	d.ml.Lock()
+	expired := d.expired(now)
+
+	record, ok := d.records[key]
+	if ok {
+		record.repeats++
+		record.entry = en
+		record.last = now
+		d.ml.Unlock()
+		return d.deliver(expired...)
+	}
+
+	record = &dedupRecord{
+		key:     key,
+		entry:   en,
+		first:   now,
+		last:    now,
+		expires: now.Add(d.window),
+	}
+	d.records[key] = record
+	d.windows.PushBack(record)
+	d.ml.Unlock()
+
+	if err := d.deliver(expired...); err != nil {
+		return err
+	}
+
+	return d.deliver(en)
+}
+
+// Flush delivers the summary entries of all pending repeats regardless of
+// their window.
+func (d *Deduplicator) Flush() error {
+	d.ml.Lock()
+	var summaries []Entry
+	for elem := d.windows.Front(); elem != nil; elem = elem.Next() {
+		if record := elem.Value.(*dedupRecord); record.repeats > 0 {
+			summaries = append(summaries, record.summary())
+		}
+	}
+	d.records = make(map[string]*dedupRecord)
+	d.windows.Init()
+	d.ml.Unlock()
+
+	return d.deliver(summaries...)
+}
+
+// Close stops Run if running and delivers the summary entries of all pending
+// repeats.
+func (d *Deduplicator) Close() error {
+	d.closed.Do(func() {
+		close(d.closer)
+	})
+
+	return d.Flush()
+}
+
+// Run delivers the summary of expired windows periodically till the close
+// channel is closed or Close is called, where all pending summaries are flushed.
+func (d *Deduplicator) Run(closeChan <-chan struct{}) {
+	ticker := time.NewTicker(d.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.ml.Lock()
+			expired := d.expired(time.Now())
+			d.ml.Unlock()
+
+			d.deliver(expired...)
+		case <-closeChan:
+			d.Flush()
+			return
+		case <-d.closer:
+			return
+		}
+	}
+}
+
+// expired removes all records whoes window has elapsed, returning the summary
+// entries of those with repeats. Windows all share the same length, so records
+// are kept in order of expiry and only the expired ones are visited. It expects
+// the lock to be held.
+func (d *Deduplicator) expired(now time.Time) []Entry {
+	var summaries []Entry
+	for elem := d.windows.Front(); elem != nil; elem = d.windows.Front() {
+		record := elem.Value.(*dedupRecord)
+		if now.Before(record.expires) {
+			break
+		}
+
+		d.windows.Remove(elem)
+		delete(d.records, record.key)
+		if record.repeats > 0 {
+			summaries = append(summaries, record.summary())
+		}
+	}
+
+	return summaries
+}
+
+func (d *Deduplicator) deliver(entries ...Entry) error {
+	for _, en := range entries {
+		for _, proc := range d.procs {
+			if err := proc.Handle(en); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// summary returns the last suppressed entry with the repeat details added to
+// a copy of its fields.
+func (r *dedupRecord) summary() Entry {
+	en := r.entry
+
+	fields := make(Field, len(en.Field)+3)
+	for key, value := range en.Field {
+		fields[key] = value
+	}
+
+	fields[RepeatCountKey] = r.repeats
+	fields[FirstSeenKey] = r.first
+	fields[LastSeenKey] = r.last
+
+	en.Field = fields
+	return en
+}
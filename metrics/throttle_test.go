@@ -0,0 +1,160 @@
+package metrics_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/metricstest"
+	"github.com/influx6/faux/tests"
+)
+
+func TestSample(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	sampler := metrics.Sample(map[metrics.Level]float64{
+		metrics.YellowAlertLvl: 0,
+		metrics.InfoLvl:        1,
+		metrics.ErrorLvl:       0.5,
+	}, recorder)
+
+	for i := 0; i < 1000; i++ {
+		sampler.Handle(metrics.Entry{Level: metrics.YellowAlertLvl})
+		sampler.Handle(metrics.Entry{Level: metrics.InfoLvl})
+		sampler.Handle(metrics.Entry{Level: metrics.ErrorLvl})
+		sampler.Handle(metrics.Entry{Level: metrics.RedAlertLvl})
+	}
+
+	counts := make(map[metrics.Level]int)
+	for _, en := range recorder.Entries() {
+		counts[en.Level]++
+	}
+
+	if counts[metrics.YellowAlertLvl] != 0 {
+		tests.Failed("Should have dropped all yellow alert entries: %d", counts[metrics.YellowAlertLvl])
+	}
+
+	if counts[metrics.InfoLvl] != 1000 || counts[metrics.RedAlertLvl] != 1000 {
+		tests.Failed("Should have kept all info and unlisted entries: %+v", counts)
+	}
+
+	if counts[metrics.ErrorLvl] < 350 || counts[metrics.ErrorLvl] > 650 {
+		tests.Failed("Should have kept about half of error entries: %d", counts[metrics.ErrorLvl])
+	}
+	tests.Passed("Should have sampled entries by level")
+}
+
+func TestRateLimit(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	limiter := metrics.RateLimit(0, 2, metrics.ByID, recorder)
+
+	for i := 0; i < 5; i++ {
+		limiter.Handle(metrics.Entry{ID: "a"})
+		limiter.Handle(metrics.Entry{ID: "b"})
+	}
+
+	if len(recorder.Entries()) != 4 {
+		tests.Failed("Should have allowed burst per key: %d", len(recorder.Entries()))
+	}
+	tests.Passed("Should have allowed burst per key")
+
+	// Exhausted buckets stay exhausted while recently used, yet the least
+	// recently used ones are evicted once too many keys are held.
+	for i := 0; i < 2000; i++ {
+		limiter.Handle(metrics.Entry{ID: strconv.Itoa(i)})
+		limiter.Handle(metrics.Entry{ID: "b"})
+	}
+
+	recorder.Reset()
+	limiter.Handle(metrics.Entry{ID: "a"})
+	limiter.Handle(metrics.Entry{ID: "b"})
+
+	entries := recorder.Entries()
+	if len(entries) != 1 || entries[0].ID != "a" {
+		tests.Failed("Should have evicted only least recently used bucket: %+v", entries)
+	}
+	tests.Passed("Should have evicted least recently used bucket")
+
+	refilling := metrics.RateLimit(100, 1, nil, recorder)
+	recorder.Reset()
+
+	refilling.Handle(metrics.Entry{ID: "c"})
+	refilling.Handle(metrics.Entry{ID: "c"})
+	time.Sleep(20 * time.Millisecond)
+	refilling.Handle(metrics.Entry{ID: "c"})
+
+	if len(recorder.Entries()) != 2 {
+		tests.Failed("Should have refilled bucket over time: %d", len(recorder.Entries()))
+	}
+	tests.Passed("Should have refilled bucket over time")
+}
+
+func TestDedup(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	dedup := metrics.Dedup(20*time.Millisecond, metrics.ByMessage, recorder)
+
+	for i := 0; i < 3; i++ {
+		dedup.Handle(metrics.Entry{Message: "repeated"})
+	}
+	dedup.Handle(metrics.Entry{Message: "single"})
+
+	if len(recorder.Entries()) != 2 {
+		tests.Failed("Should have delivered only first of repeated entries: %+v", recorder.Entries())
+	}
+	tests.Passed("Should have suppressed repeated entries")
+
+	time.Sleep(30 * time.Millisecond)
+	dedup.Handle(metrics.Entry{Message: "later"})
+
+	entries := recorder.Entries()
+	if len(entries) != 4 {
+		tests.Failed("Should have delivered summary once window elapsed: %+v", entries)
+	}
+
+	summary := entries[2]
+	if summary.Message != "repeated" || summary.Field[metrics.RepeatCountKey] != 2 {
+		tests.Failed("Should have delivered summary with repeat count: %+v", summary)
+	}
+
+	first, _ := summary.Field[metrics.FirstSeenKey].(time.Time)
+	last, _ := summary.Field[metrics.LastSeenKey].(time.Time)
+	if first.IsZero() || last.Before(first) {
+		tests.Failed("Should have set first and last seen: %+v", summary.Field)
+	}
+	tests.Passed("Should have delivered summary once window elapsed")
+
+	if entries[3].Message != "later" {
+		tests.Failed("Should have delivered new entry after summary: %+v", entries[3])
+	}
+	tests.Passed("Should have delivered new entry after summary")
+}
+
+func TestDedupClose(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+	dedup := metrics.Dedup(time.Hour, nil, recorder)
+
+	done := make(chan struct{})
+	go func() {
+		dedup.Run(make(chan struct{}))
+		close(done)
+	}()
+
+	dedup.Handle(metrics.Entry{Message: "repeated"})
+	dedup.Handle(metrics.Entry{Message: "repeated"})
+
+	if err := dedup.Close(); err != nil {
+		tests.Failed("Should have closed deduplicator: %+q", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		tests.Failed("Should have stopped running once closed")
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 2 || entries[1].Field[metrics.RepeatCountKey] != 1 {
+		tests.Failed("Should have flushed pending summary on close: %+v", entries)
+	}
+	tests.Passed("Should have flushed pending summary on close")
+}
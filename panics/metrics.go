@@ -0,0 +1,322 @@
+package panics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/faux/metrics"
+)
+
+// PanicType defines the type set on entries emitted for recovered panics.
+const PanicType = "panic"
+
+// field keys set on entries emitted for recovered panics.
+const (
+	PanicKey     = "panic"
+	PanicTypeKey = "panic_type"
+	GoroutineKey = "goroutine"
+	FramesKey    = "frames"
+)
+
+// Frame defines a single call of a goroutine stack.
+type Frame struct {
+	Function  string `json:"function"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	CreatedBy bool   `json:"created_by,omitempty"`
+}
+
+// String returns the frame in the form of function (file:line).
+func (f Frame) String() string {
+	if f.CreatedBy {
+		return fmt.Sprintf("created by %s (%s:%d)", f.Function, f.File, f.Line)
+	}
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// Stack returns the stack of the calling goroutine as written by runtime.Stack.
+func Stack() []byte {
+	size := 1 << 14
+	for {
+		trace := make([]byte, size)
+		if n := runtime.Stack(trace, false); n < size {
+			return trace[:n]
+		}
+		size *= 2
+	}
+}
+
+// ParseStack parses the first goroutine of the stack written by runtime.Stack,
+// returning its id and call frames from the innermost call.
+func ParseStack(stack []byte) (int, []Frame) {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	if len(lines) == 0 {
+		return 0, nil
+	}
+
+	var id int
+	if header := strings.Fields(lines[0]); len(header) > 1 && header[0] == "goroutine" {
+		id, _ = strconv.Atoi(header[1])
+		lines = lines[1:]
+	}
+
+	var frames []Frame
+	for index := 0; index < len(lines); index++ {
+		call := strings.TrimSpace(lines[index])
+		if call == "" {
+			// A blank line ends the first goroutine.
+			break
+		}
+
+		// Calls are followed by their location indented by a tab, all other
+		// lines such as the notes of elided frames are skipped.
+		if strings.HasPrefix(lines[index], "\t") || index+1 == len(lines) || !strings.HasPrefix(lines[index+1], "\t") {
+			continue
+		}
+
+		var frame Frame
+		if strings.HasPrefix(call, "created by ") {
+			frame.CreatedBy = true
+			call = strings.TrimPrefix(call, "created by ")
+
+			// Newer runtimes append the creating goroutine to the function.
+			if at := strings.Index(call, " in goroutine "); at != -1 {
+				call = call[:at]
+			}
+		} else if strings.HasSuffix(call, ")") {
+			if at := strings.LastIndex(call, "("); at > 0 {
+				call = call[:at]
+			}
+		}
+
+		frame.Function = call
+
+		index++
+		location := strings.TrimSpace(lines[index])
+		if at := strings.LastIndex(location, " +0x"); at != -1 {
+			location = location[:at]
+		}
+
+		if at := strings.LastIndex(location, ":"); at != -1 {
+			frame.File = location[:at]
+			frame.Line, _ = strconv.Atoi(location[at+1:])
+		} else {
+			frame.File = location
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return id, frames
+}
+
+// isPanicFrame returns true/false if the frame belongs to the runtime's panic machinery.
+func isPanicFrame(frame Frame) bool {
+	switch {
+	case frame.Function == "panic",
+		strings.HasPrefix(frame.Function, "runtime.gopanic"),
+		strings.HasPrefix(frame.Function, "runtime.goPanic"),
+		strings.HasPrefix(frame.Function, "runtime.panic"),
+		strings.HasPrefix(frame.Function, "runtime.sigpanic"):
+		return true
+	}
+	return false
+}
+
+// panicFrames returns the frames starting from the call which panicked, dropping
+// the recovery and runtime frames above it. All frames are returned if none
+// belong to the panic machinery.
+func panicFrames(frames []Frame) []Frame {
+	for index := len(frames) - 1; index >= 0; index-- {
+		if isPanicFrame(frames[index]) {
+			return frames[index+1:]
+		}
+	}
+	return frames
+}
+
+// PanicEntry returns a function which sets up an Entry for the giving recovered
+// value and goroutine stack. The Entry is set to RedAlertLvl with the tag as its
+// ID and PanicType as its type. Its origin is set to the call which panicked,
+// while its fields carry the recovered value, its type, the goroutine id, the
+// parsed frames and the provided fields. The raw stack is kept in its Trace.
+func PanicEntry(tag string, recovered interface{}, stack []byte, fields metrics.Field) func(*metrics.Entry) {
+	return func(en *metrics.Entry) {
+		id, frames := ParseStack(stack)
+		frames = panicFrames(frames)
+
+		if tag == "" {
+			tag = PanicType
+		}
+
+		en.ID = tag
+		en.Type = PanicType
+		en.Level = metrics.RedAlertLvl
+		en.Time = time.Now()
+		en.Message = fmt.Sprintf("panic: %v", recovered)
+
+		if en.Field == nil {
+			en.Field = make(metrics.Field)
+		}
+
+		for key, value := range fields {
+			en.Field[key] = value
+		}
+
+		en.Field[PanicKey] = recovered
+		en.Field[PanicTypeKey] = fmt.Sprintf("%T", recovered)
+		en.Field[GoroutineKey] = id
+		en.Field[FramesKey] = frames
+
+		if len(frames) != 0 {
+			en.Function = frames[0].Function
+			en.File = frames[0].File
+			en.Line = frames[0].Line
+		}
+
+		en.Trace = metrics.Trace{
+			Function:   en.Function,
+			File:       en.File,
+			LineNumber: en.Line,
+			Stack:      stack,
+			Time:       en.Time,
+		}
+	}
+}
+
+// Report emits an Entry for the giving recovered value into the Metrics, using
+// the stack of the calling goroutine. It must be called within the deferred
+// function which recovered the panic for the stack to include the panicking call.
+func Report(m metrics.Metrics, tag string, recovered interface{}, fields metrics.Field) error {
+	return m.Emit(PanicEntry(tag, recovered, Stack(), fields))
+}
+
+// DeferMetrics provides a recovery handler like Defer, which emits an Entry
+// into the Metrics for any panic that occurs.
+func DeferMetrics(op func(), m metrics.Metrics, fields metrics.Field) {
+	defer func() {
+		if err := recover(); err != nil {
+			Report(m, "", err, fields)
+		}
+	}()
+
+	op()
+}
+
+// MetricsRecoverHandler provides a recovery handler like LogRecoverHandler, which
+// emits an Entry with the tag as its ID into the Metrics for any panic that occurs.
+func MetricsRecoverHandler(tag string, m metrics.Metrics, fields metrics.Field, opFunc func() error) error {
+	return RecoverHandler(tag, opFunc, func(err interface{}) {
+		Report(m, tag, err, fields)
+	})
+}
+
+// GoDeferMetrics lets you run a function inside a goroutine that gets a defer
+// recovery like GoDefer, and emits an Entry into the Metrics if a panic occurs.
+func GoDeferMetrics(title string, m metrics.Metrics, fields metrics.Field, fx func()) {
+	go MetricsRecoverHandler(title, m, fields, func() error {
+		fx()
+		return nil
+	})
+}
+
+//=====================================================================================
+
+// CrashDump returns a metrics.Processors which writes every panic Entry into its
+// own file within the giving directory, named after the time and process id.
+// Each file holds the entry message, origin, frames, fields and the raw goroutine stack.
+func CrashDump(dir string) metrics.Processors {
+	return &crashDumper{dir: dir}
+}
+
+type crashDumper struct {
+	ml  sync.Mutex
+	dir string
+}
+
+// Handle implements the metrics.Processors interface.
+func (c *crashDumper) Handle(en metrics.Entry) error {
+	if en.Type != PanicType {
+		return nil
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	var bu bytes.Buffer
+	fmt.Fprintf(&bu, "%s\n\n", en.Message)
+	fmt.Fprintf(&bu, "Time: %s\n", en.Time.Format(time.RFC3339Nano))
+	fmt.Fprintf(&bu, "Tag: %s\n", en.ID)
+	fmt.Fprintf(&bu, "Origin: %s (%s:%d)\n", en.Function, en.File, en.Line)
+
+	if frames, ok := en.Field[FramesKey].([]Frame); ok && len(frames) != 0 {
+		bu.WriteString("Frames:\n")
+		for _, frame := range frames {
+			fmt.Fprintf(&bu, "  %s\n", frame)
+		}
+	}
+
+	fields := make(metrics.Field, len(en.Field))
+	for key, value := range en.Field {
+		if key == FramesKey {
+			continue
+		}
+
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		fields[key] = value
+	}
+
+	if len(fields) != 0 {
+		bu.WriteString("Fields:\n")
+
+		data, err := json.MarshalIndent(fields, "", "  ")
+		if err != nil {
+			data = []byte(fmt.Sprintf("%#v", fields))
+		}
+
+		bu.Write(data)
+		bu.WriteByte('\n')
+	}
+
+	if len(en.Trace.Stack) != 0 {
+		bu.WriteString("\nStack:\n")
+		bu.Write(en.Trace.Stack)
+		bu.WriteByte('\n')
+	}
+
+	name := fmt.Sprintf("crash-%s-%d.txt", en.Time.UTC().Format("20060102T150405.000000000"), os.Getpid())
+	return writeFile(filepath.Join(c.dir, name), bu.Bytes())
+}
+
+func writeFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
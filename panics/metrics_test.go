@@ -0,0 +1,167 @@
+package panics_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/metrics"
+	"github.com/influx6/faux/metrics/metricstest"
+	"github.com/influx6/faux/panics"
+	"github.com/influx6/faux/tests"
+)
+
+const elidedStack = `goroutine 7 [running]:
+main.inner(0x1, 0x2)
+	/app/main.go:10 +0x1d
+...2 frames elided...
+main.outer(...)
+	/app/main.go:20
+created by main.start in goroutine 1
+	/app/main.go:30 +0x3b
+
+goroutine 1 [chan receive]:
+main.main()
+	/app/main.go:5 +0x10
+`
+
+func TestParseStack(t *testing.T) {
+	id, frames := panics.ParseStack([]byte(elidedStack))
+	if id != 7 {
+		tests.Failed("Should have parsed goroutine id but got %d", id)
+	}
+	tests.Passed("Should have parsed goroutine id")
+
+	expected := []panics.Frame{
+		{Function: "main.inner", File: "/app/main.go", Line: 10},
+		{Function: "main.outer", File: "/app/main.go", Line: 20},
+		{Function: "main.start", File: "/app/main.go", Line: 30, CreatedBy: true},
+	}
+
+	if len(frames) != len(expected) {
+		tests.Failed("Should have parsed %d frames but got %+v", len(expected), frames)
+	}
+
+	for index, frame := range expected {
+		if frames[index] != frame {
+			tests.Failed("Should have parsed frame %+v but got %+v", frame, frames[index])
+		}
+	}
+	tests.Passed("Should have skipped note of elided frames")
+
+	if _, frames := panics.ParseStack(panics.Stack()); len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "panics.Stack") {
+		tests.Failed("Should have parsed stack of current goroutine: %+v", frames)
+	}
+	tests.Passed("Should have parsed stack of current goroutine")
+}
+
+func explode() {
+	panic(errors.New("boom"))
+}
+
+func TestPanicEntry(t *testing.T) {
+	var en metrics.Entry
+
+	func() {
+		defer func() {
+			recovered := recover()
+			panics.PanicEntry("worker", recovered, panics.Stack(), metrics.Field{"job": 4})(&en)
+		}()
+
+		explode()
+	}()
+
+	if en.ID != "worker" || en.Type != panics.PanicType || en.Level != metrics.RedAlertLvl || en.Message != "panic: boom" {
+		tests.Failed("Should have set up panic entry: %+v", en)
+	}
+	tests.Passed("Should have set up panic entry")
+
+	if !strings.HasSuffix(en.Function, "panics_test.explode") || en.Line == 0 {
+		tests.Failed("Should have set origin to panicking call: %s:%d", en.Function, en.Line)
+	}
+	tests.Passed("Should have set origin to panicking call")
+
+	if en.Field["job"] != 4 || en.Field[panics.PanicTypeKey] != "*errors.errorString" || en.Field[panics.GoroutineKey].(int) == 0 {
+		tests.Failed("Should have set panic fields: %+v", en.Field)
+	}
+
+	if frames := en.Field[panics.FramesKey].([]panics.Frame); frames[0].Function != en.Function {
+		tests.Failed("Should have dropped recovery frames: %+v", frames)
+	}
+
+	if len(en.Trace.Stack) == 0 || en.Trace.Function != en.Function {
+		tests.Failed("Should have kept stack in trace: %+v", en.Trace)
+	}
+	tests.Passed("Should have set panic fields and trace")
+}
+
+func TestReport(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+
+	func() {
+		defer func() {
+			if err := panics.Report(recorder.Metrics(), "", recover(), nil); err != nil {
+				tests.Failed("Should have reported panic: %+q", err)
+			}
+		}()
+
+		explode()
+	}()
+
+	recorder.Assert(t).Emitted(metricstest.Match().ID(panics.PanicType).Type(panics.PanicType).Message("panic: boom"))
+	tests.Passed("Should have reported panic with default tag")
+}
+
+func TestDeferMetrics(t *testing.T) {
+	recorder := metricstest.NewRecorder()
+
+	panics.DeferMetrics(explode, recorder.Metrics(), metrics.Field{"op": "explode"})
+
+	entries := recorder.Entries()
+	if len(entries) != 1 || entries[0].Field["op"] != "explode" || !strings.HasSuffix(entries[0].Function, "panics_test.explode") {
+		tests.Failed("Should have emitted panic entry: %+v", entries)
+	}
+	tests.Passed("Should have emitted panic entry")
+
+	panics.DeferMetrics(func() {}, recorder.Metrics(), nil)
+	if len(recorder.Entries()) != 1 {
+		tests.Failed("Should have emitted nothing without panic")
+	}
+	tests.Passed("Should have emitted nothing without panic")
+}
+
+func TestCrashDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "panics")
+	if err != nil {
+		tests.Failed("Should have created temp dir: %+q", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dumps := filepath.Join(dir, "dumps")
+	m := metrics.New(panics.CrashDump(dumps))
+
+	m.Emit(metrics.Info("not a panic"))
+	panics.DeferMetrics(explode, m, metrics.Field{"cause": errors.New("disk full")})
+
+	files, err := ioutil.ReadDir(dumps)
+	if err != nil || len(files) != 1 {
+		tests.Failed("Should have written one crash dump: %+v %+q", files, err)
+	}
+	tests.Passed("Should have written crash dump only for panic")
+
+	data, err := ioutil.ReadFile(filepath.Join(dumps, files[0].Name()))
+	if err != nil {
+		tests.Failed("Should have read crash dump: %+q", err)
+	}
+
+	dump := string(data)
+	for _, part := range []string{"panic: boom\n", "Frames:\n", "panics_test.explode", `"cause": "disk full"`, "\nStack:\ngoroutine "} {
+		if !strings.Contains(dump, part) {
+			tests.Failed("Should have written %q into crash dump: %s", part, dump)
+		}
+	}
+	tests.Passed("Should have written details into crash dump")
+}
@@ -194,6 +194,15 @@ func Segment(segment string) Matchable {
 	}
 
 	id, rx, b := YankSpecial(segment)
+
+	// Parameter expressions must match the whole segment, while plain segments
+	// are matched literally.
+	if b {
+		rx = "^(?:" + rx + ")$"
+	} else {
+		rx = "^" + regexp.QuoteMeta(rx) + "$"
+	}
+
 	mrk := regexp.MustCompile(rx)
 
 	sm := SegmentMatcher{
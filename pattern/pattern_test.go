@@ -149,3 +149,31 @@ func TestRegExpPattern(t *testing.T) {
 		t.Fatalf("incorrect pattern: %+s %t", param, state)
 	}
 }
+
+func TestRootPattern(t *testing.T) {
+	r := pattern.New(`/`)
+
+	if _, _, state := r.Validate(`/`); !state {
+		t.Fatalf("Failed: Should have matched root path: %s", r.Pattern())
+	}
+
+	if _, _, state := r.Validate(`/users`); state {
+		t.Fatalf("Failed: Should not have matched path: %s", r.Pattern())
+	}
+}
+
+func TestSegmentsMatchWhole(t *testing.T) {
+	r := pattern.New(`/users/{id:[\d+]}`)
+
+	if _, _, state := r.Validate(`/superusers/12`); state {
+		t.Fatalf("Failed: Should not have matched partial segment: %s", r.Pattern())
+	}
+
+	if _, _, state := r.Validate(`/users/12a`); state {
+		t.Fatalf("Failed: Should not have matched partial parameter: %s", r.Pattern())
+	}
+
+	if _, _, state := r.Validate(`/users/12`); !state {
+		t.Fatalf("Failed: Should have matched path: %s", r.Pattern())
+	}
+}
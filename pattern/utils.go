@@ -202,6 +202,11 @@ func splitPattern(c string) []string {
 		parts[0] = "/"
 	}
 
+	// Drop the empty segment left by the root path.
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+
 	return parts
 }
 